@@ -0,0 +1,103 @@
+package filter
+
+import (
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+)
+
+// applyColumns applies column rules to message passing filters, so dropped and masked
+// columns never reach senders
+func (f *Filter) applyColumns(msg *message2.Message) {
+	if len(f.columnRules) == 0 {
+		return
+	}
+	head := &msg.Content.Head
+	if trx, ok := msg.Content.Data.(message2.Transaction); ok {
+		for _, v := range trx.Changes {
+			if f.matchColumns(v.Database, v.Table) {
+				// statement of rows may contain values of the columns
+				head.Query = ""
+			}
+			v.PK = f.columnsPK(v.Database, v.Table, v.PK)
+			v.Data = f.columnsData(v.Database, v.Table, v.Data)
+		}
+		return
+	}
+	if f.matchColumns(head.Database, head.Table) {
+		head.Query = ""
+	}
+	head.PK = f.columnsPK(head.Database, head.Table, head.PK)
+	msg.Content.Data = f.columnsData(head.Database, head.Table, msg.Content.Data)
+}
+
+// matchColumns returns true if any column rule applies to table
+func (f *Filter) matchColumns(database string, table string) bool {
+	for _, r := range f.columnRules {
+		if r.MatchTable(database, table) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) columnsData(database string, table string, data interface{}) interface{} {
+	for _, r := range f.columnRules {
+		if !r.MatchTable(database, table) {
+			continue
+		}
+		switch val := data.(type) {
+		case message2.Insert:
+			{
+				val.New = r.Apply(val.New)
+				val.Missing = r.Names(val.Missing)
+				data = val
+			}
+		case message2.Update:
+			{
+				val.Old = r.Apply(val.Old)
+				val.New = r.Apply(val.New)
+				val.Changed = r.Names(val.Changed)
+				val.OldMissing = r.Names(val.OldMissing)
+				val.NewMissing = r.Names(val.NewMissing)
+				data = val
+			}
+		case message2.Delete:
+			{
+				val.Old = r.Apply(val.Old)
+				val.Missing = r.Names(val.Missing)
+				data = val
+			}
+		case message2.Snapshot:
+			{
+				val.New = r.Apply(val.New)
+				data = val
+			}
+		}
+	}
+	return data
+}
+
+// columnsPK returns primary key after column rules, pk is copied since it may be shared with other messages
+func (f *Filter) columnsPK(database string, table string, pk *message2.PK) *message2.PK {
+	if pk == nil {
+		return nil
+	}
+	for _, r := range f.columnRules {
+		if !r.MatchTable(database, table) {
+			continue
+		}
+		res := &message2.PK{Columns: []string{}, Values: []interface{}{}}
+		for i, c := range pk.Columns {
+			if r.Drop(c) {
+				continue
+			}
+			var val interface{}
+			if i < len(pk.Values) {
+				val = pk.Values[i]
+			}
+			res.Columns = append(res.Columns, c)
+			res.Values = append(res.Values, r.Value(c, val))
+		}
+		pk = res
+	}
+	return pk
+}
@@ -2,8 +2,11 @@ package filter
 
 import (
 	"context"
+	"encoding/json"
+
 	message2 "github.com/jin06/binlogo/app/pipeline/message"
 	"github.com/jin06/binlogo/configs"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
 	"github.com/jin06/binlogo/pkg/promeths"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
@@ -16,6 +19,10 @@ type Filter struct {
 	Options   *Options
 	ctx       context.Context
 	rulesTree tree
+	// rowRules event and row filters
+	rowRules []*tool.RowRule
+	// columnRules rules of columns of messages passing filters
+	columnRules []*tool.ColumnRule
 }
 
 // New returns a new Filter
@@ -35,15 +42,41 @@ func (f *Filter) init() (err error) {
 		f.rulesTree = newTree(nil)
 	}
 	f.rulesTree = newTree(f.Options.Pipe.Filters)
+	f.rowRules = tool.NewRowRules(f.Options.Pipe.Filters)
+	f.columnRules, err = tool.NewColumnRules(f.Options.Pipe.Columns)
 	return
 }
 
 func (f *Filter) filer(msg *message2.Message) (err error) {
-	msg.Filter = f.rulesTree.isFilter(msg)
+	if trx, ok := msg.Content.Data.(message2.Transaction); ok {
+		msg.Filter = f.filterTransaction(msg, trx)
+		return
+	}
+	head := msg.Content.Head
+	msg.Filter = f.rulesTree.isFilter(msg) ||
+		f.isFilterRow(head.Database, head.Table, tool.MessageEvent(head.Type), msg.Content.Data)
 	return
 }
 
+// filterTransaction removes filtered changes of transaction message,
+// returns true if all changes are filtered
+func (f *Filter) filterTransaction(msg *message2.Message, trx message2.Transaction) bool {
+	changes := make([]*message2.Change, 0, len(trx.Changes))
+	for _, v := range trx.Changes {
+		if !f.rulesTree.isFilterTable(v.Database, v.Table) && !f.isFilterRow(v.Database, v.Table, tool.MessageEvent(v.Type), v.Data) {
+			changes = append(changes, v)
+		}
+	}
+	trx.Changes = changes
+	msg.Content.Data = trx
+	return len(changes) == 0
+}
+
 func (f *Filter) handle(msg *message2.Message) {
+	// message marked by input such as snapshot progress marker, just pass it
+	if msg.Filter {
+		return
+	}
 	err := f.filer(msg)
 	if err != nil {
 		logrus.Error(err)
@@ -51,7 +84,44 @@ func (f *Filter) handle(msg *message2.Message) {
 	}
 	if msg.Filter == true {
 		promeths.MessageFilterCounter.With(prometheus.Labels{"pipeline": f.Options.Pipe.Name, "node": configs.NodeName}).Inc()
+		return
+	}
+	f.rowKey(msg)
+	f.applyColumns(msg)
+}
+
+// rowKey keys row change by its physical table and primary key before column rules change the key,
+// so output coalesces changes of the same row only. Updates changing the primary key are not keyed
+func (f *Filter) rowKey(msg *message2.Message) {
+	if f.Options.Pipe.Coalesce == nil {
+		return
+	}
+	head := msg.Content.Head
+	if head.PK == nil {
+		return
+	}
+	switch data := msg.Content.Data.(type) {
+	case message2.Insert, message2.Delete:
+	case message2.Update:
+		{
+			for _, c := range data.Changed {
+				for _, v := range head.PK.Columns {
+					if c == v {
+						return
+					}
+				}
+			}
+		}
+	default:
+		{
+			return
+		}
+	}
+	b, err := json.Marshal(head.PK.Values)
+	if err != nil {
+		return
 	}
+	msg.RowKey = head.Database + "." + head.Table + ":" + string(b)
 }
 
 // Run Filter start working
@@ -5,10 +5,12 @@ import (
 	"testing"
 
 	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/promeths"
 	"github.com/jin06/binlogo/pkg/store/model/pipeline"
 )
 
 func TestRun(t *testing.T) {
+	promeths.Init()
 	pipe := pipeline.Pipeline{
 		Name: "test", Filters: []*pipeline.Filter{
 			{
@@ -32,8 +34,8 @@ func TestRun(t *testing.T) {
 		t.Error(err)
 	}
 	inMsg := &message2.Message{
-		Content: &message2.Content{
-			Head: &message2.Head{
+		Content: message2.Content{
+			Head: message2.Head{
 				Database: "mysql",
 				Table:    "user",
 			},
@@ -48,3 +50,185 @@ func TestRun(t *testing.T) {
 	}
 	cancel()
 }
+
+func TestFilterTransaction(t *testing.T) {
+	promeths.Init()
+	pipe := pipeline.Pipeline{
+		Name: "test", Filters: []*pipeline.Filter{
+			{
+				Type: pipeline.FILTER_BLACK,
+				Rule: "mysql",
+			},
+		},
+	}
+	f, err := New(WithPipe(&pipe))
+	if err != nil {
+		t.Error(err)
+	}
+	if err = f.init(); err != nil {
+		t.Error(err)
+	}
+	msg := message2.New()
+	msg.Content.Data = message2.Transaction{
+		Changes: []*message2.Change{
+			{Database: "mysql", Table: "user"},
+			{Database: "database1", Table: "table1"},
+		},
+	}
+	f.handle(msg)
+	trx := msg.Content.Data.(message2.Transaction)
+	if msg.Filter || len(trx.Changes) != 1 || trx.Changes[0].Database != "database1" {
+		t.Fail()
+	}
+	msg.Content.Data = message2.Transaction{
+		Changes: []*message2.Change{
+			{Database: "mysql", Table: "user"},
+		},
+	}
+	f.handle(msg)
+	if !msg.Filter {
+		t.Fail()
+	}
+}
+
+func TestFilterRows(t *testing.T) {
+	promeths.Init()
+	pipe := pipeline.Pipeline{
+		Name: "test", Filters: []*pipeline.Filter{
+			{Type: pipeline.FILTER_BLACK, Kind: pipeline.FILTER_KIND_EVENT, Events: []string{pipeline.FILTER_EVENT_DELETE}},
+			{Type: pipeline.FILTER_WHITE, Kind: pipeline.FILTER_KIND_EVENT, Rule: "mall.log", Events: []string{pipeline.FILTER_EVENT_INSERT}},
+			{Type: pipeline.FILTER_WHITE, Kind: pipeline.FILTER_KIND_ROW, Rule: "mall.order", Condition: "status = 'paid'"},
+			{Type: pipeline.FILTER_BLACK, Kind: pipeline.FILTER_KIND_ROW, Rule: "mall.*", Syntax: pipeline.FILTER_SYNTAX_GLOB, Condition: "tenant_id IN (3, 7)"},
+		},
+	}
+	f, err := New(WithPipe(&pipe))
+	if err != nil {
+		t.Error(err)
+	}
+	if err = f.init(); err != nil {
+		t.Error(err)
+	}
+	newMsg := func(db string, table string, mt message2.MessageType, data interface{}) *message2.Message {
+		msg := message2.New()
+		msg.Content.Head.Database = db
+		msg.Content.Head.Table = table
+		msg.Content.Head.Type = mt.String()
+		msg.Content.Data = data
+		return msg
+	}
+	cases := []struct {
+		msg    *message2.Message
+		filter bool
+	}{
+		{newMsg("user", "user", message2.TYPE_DELETE, message2.Delete{Old: map[string]interface{}{"id": 1}}), true},
+		{newMsg("user", "user", message2.TYPE_INSERT, message2.Insert{New: map[string]interface{}{"id": 1}}), false},
+		{newMsg("mall", "log", message2.TYPE_UPDATE, message2.Update{New: map[string]interface{}{"id": 1}}), true},
+		{newMsg("mall", "log", message2.TYPE_INSERT, message2.Insert{New: map[string]interface{}{"id": 1}}), false},
+		{newMsg("mall", "log", message2.TYPE_ALTER_TABLE, message2.AlterTable{}), true},
+		{newMsg("mall", "order", message2.TYPE_UPDATE, message2.Update{
+			Old: map[string]interface{}{"status": "new"}, New: map[string]interface{}{"status": "paid"}}), false},
+		{newMsg("mall", "order", message2.TYPE_INSERT, message2.Insert{New: map[string]interface{}{"status": "new"}}), true},
+		{newMsg("mall", "order", message2.TYPE_INSERT, message2.Insert{New: map[string]interface{}{"status": "paid", "tenant_id": int64(7)}}), true},
+		{newMsg("mall", "order", message2.TYPE_ALTER_TABLE, message2.AlterTable{}), false},
+	}
+	for i, v := range cases {
+		f.handle(v.msg)
+		if v.msg.Filter != v.filter {
+			t.Error(i, v.msg.Filter)
+		}
+	}
+	msg := newMsg("mall", "order", message2.TYPE_TRANSACTION, message2.Transaction{
+		Changes: []*message2.Change{
+			{Type: "insert", Database: "mall", Table: "order", Data: message2.Insert{New: map[string]interface{}{"status": "new"}}},
+			{Type: "insert", Database: "mall", Table: "order", Data: message2.Insert{New: map[string]interface{}{"status": "paid"}}},
+		},
+	})
+	f.handle(msg)
+	if trx := msg.Content.Data.(message2.Transaction); msg.Filter || len(trx.Changes) != 1 {
+		t.Fail()
+	}
+}
+
+func TestApplyColumns(t *testing.T) {
+	promeths.Init()
+	pipe := pipeline.Pipeline{
+		Name: "test",
+		Columns: []*pipeline.ColumnRule{
+			{Rule: "user.account", Action: pipeline.COLUMN_EXCLUDE, Columns: []string{"password_hash"}},
+			{Rule: "user.account", Action: pipeline.COLUMN_MASK, Columns: []string{"id", "phone"}, KeepPrefix: 1},
+		},
+	}
+	f, err := New(WithPipe(&pipe))
+	if err != nil {
+		t.Error(err)
+	}
+	if err = f.init(); err != nil {
+		t.Error(err)
+	}
+	msg := message2.New()
+	msg.Content.Head.Database = "user"
+	msg.Content.Head.Table = "account"
+	msg.Content.Head.Type = message2.TYPE_UPDATE.String()
+	msg.Content.Head.Query = "update account set phone = '13900000000'"
+	msg.Content.Head.PK = &message2.PK{Columns: []string{"id"}, Values: []interface{}{int64(123)}}
+	msg.Content.Data = message2.Update{
+		Old:     map[string]interface{}{"id": int64(123), "phone": "13800000000", "password_hash": "a"},
+		New:     map[string]interface{}{"id": int64(123), "phone": "13900000000", "password_hash": "b"},
+		Changed: []string{"phone", "password_hash"},
+	}
+	f.handle(msg)
+	data := msg.Content.Data.(message2.Update)
+	if _, ok := data.New["password_hash"]; ok || len(data.Changed) != 1 {
+		t.Error(data)
+	}
+	if data.Old["phone"] != "1**********" || data.New["phone"] != "1**********" {
+		t.Error(data)
+	}
+	if msg.Content.Head.PK.Values[0] != "1**" || data.New["id"] != "1**" || msg.Content.Head.Query != "" {
+		t.Error(msg.Content.Head)
+	}
+}
+
+func TestRowKey(t *testing.T) {
+	promeths.Init()
+	pipe := pipeline.Pipeline{
+		Name:     "test",
+		Coalesce: &pipeline.Coalesce{},
+		Columns: []*pipeline.ColumnRule{
+			{Rule: "user.account", Action: pipeline.COLUMN_MASK, Columns: []string{"id"}, KeepPrefix: 1},
+		},
+	}
+	f, err := New(WithPipe(&pipe))
+	if err != nil {
+		t.Error(err)
+	}
+	if err = f.init(); err != nil {
+		t.Error(err)
+	}
+	update := func(old int64, new int64, changed ...string) *message2.Message {
+		msg := message2.New()
+		msg.Content.Head.Database = "user"
+		msg.Content.Head.Table = "account"
+		msg.Content.Head.Type = message2.TYPE_UPDATE.String()
+		msg.Content.Head.PK = &message2.PK{Columns: []string{"id"}, Values: []interface{}{new}}
+		msg.Content.Data = message2.Update{
+			Old:     map[string]interface{}{"id": old, "n": 0},
+			New:     map[string]interface{}{"id": new, "n": 1},
+			Changed: changed,
+		}
+		f.handle(msg)
+		return msg
+	}
+	// masked keys are the same, keys before column rules are not
+	a, b := update(123, 123, "n"), update(145, 145, "n")
+	if a.Content.Head.PK.Values[0] != b.Content.Head.PK.Values[0] || a.RowKey == "" || a.RowKey == b.RowKey {
+		t.Error(a.RowKey, b.RowKey)
+	}
+	if msg := update(123, 124, "id", "n"); msg.RowKey != "" {
+		t.Error(msg.RowKey)
+	}
+	pipe.Coalesce = nil
+	if msg := update(123, 123, "n"); msg.RowKey != "" {
+		t.Error(msg.RowKey)
+	}
+}
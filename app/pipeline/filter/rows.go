@@ -0,0 +1,105 @@
+package filter
+
+import (
+	"strconv"
+
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/configs"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
+	"github.com/jin06/binlogo/pkg/promeths"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// isFilterRow applies event and row filters to one event of table, returns true if it is dropped.
+// Black filters drop matched events and rows. Where white filters of a kind apply to the table,
+// events and rows matched by none of them are dropped
+func (f *Filter) isFilterRow(database string, table string, event string, data interface{}) bool {
+	if event == "" || len(f.rowRules) == 0 {
+		return false
+	}
+	row, old, new, isRow := rowImages(data)
+	var whiteEvent, whiteRow *tool.RowRule
+	keepEvent, keepRow := false, false
+	for _, r := range f.rowRules {
+		if !r.MatchTable(database, table) {
+			continue
+		}
+		if r.IsEvent() {
+			matched := r.MatchEvent(event)
+			if r.Black() && matched {
+				f.countRule(r)
+				return true
+			}
+			if !r.Black() {
+				keepEvent = keepEvent || matched
+				if whiteEvent == nil {
+					whiteEvent = r
+				}
+			}
+			continue
+		}
+		if !isRow {
+			continue
+		}
+		matched := r.MatchRow(row, old, new)
+		if r.Black() && matched {
+			f.countRule(r)
+			return true
+		}
+		if !r.Black() {
+			keepRow = keepRow || matched
+			if whiteRow == nil {
+				whiteRow = r
+			}
+		}
+	}
+	if whiteEvent != nil && !keepEvent {
+		f.countRule(whiteEvent)
+		return true
+	}
+	if whiteRow != nil && !keepRow {
+		f.countRule(whiteRow)
+		return true
+	}
+	return false
+}
+
+func (f *Filter) countRule(r *tool.RowRule) {
+	if promeths.FilterRuleCounter == nil {
+		return
+	}
+	promeths.FilterRuleCounter.With(prometheus.Labels{
+		"pipeline": f.Options.Pipe.Name,
+		"node":     configs.NodeName,
+		"rule":     strconv.Itoa(r.Index),
+		"kind":     string(r.Filter.Kind),
+	}).Inc()
+}
+
+// rowImages returns values of row for conditions, row is the after image except deletes
+func rowImages(data interface{}) (row map[string]interface{}, old map[string]interface{}, new map[string]interface{}, ok bool) {
+	ok = true
+	switch val := data.(type) {
+	case message2.Insert:
+		{
+			row, new = val.New, val.New
+		}
+	case message2.Update:
+		{
+			row, old, new = val.New, val.Old, val.New
+		}
+	case message2.Delete:
+		{
+			row, old = val.Old, val.Old
+		}
+	case message2.Snapshot:
+		{
+			row, new = val.New, val.New
+		}
+	default:
+		{
+			ok = false
+		}
+	}
+	return
+}
@@ -1,68 +1,25 @@
 package filter
 
 import (
-	"fmt"
-	"strings"
-
 	message2 "github.com/jin06/binlogo/app/pipeline/message"
 	"github.com/jin06/binlogo/pkg/pipeline/tool"
 	"github.com/jin06/binlogo/pkg/store/model/pipeline"
 )
 
+// tree rules of pipeline filters, shares the rule engine with console so previews match runtime
 type tree struct {
-	DBBlack    map[string]bool
-	TableBlack map[string]bool
-	DBWhite    map[string]bool
-	TableWhite map[string]bool
+	*tool.Filter
 }
 
 func (t *tree) isFilter(msg *message2.Message) bool {
-	if _, ok := t.DBWhite[msg.Content.Head.Database]; ok {
-		return false
-	}
-	table := fmt.Sprintf("%s.%s", msg.Content.Head.Database, msg.Content.Head.Table)
-	if _, ok := t.TableWhite[table]; ok {
-		return false
-	}
-	if _, ok := t.DBBlack[msg.Content.Head.Database]; ok {
-		return true
-	}
-	if _, ok := t.TableBlack[table]; ok {
-		return true
-	}
+	return t.IsFilter(msg)
+}
 
-	return false
+func (t *tree) isFilterTable(database string, tableName string) bool {
+	return t.IsFilterTable(database, tableName)
 }
 
 func newTree(filters []*pipeline.Filter) (res tree) {
-	res = tree{
-		DBBlack:    map[string]bool{},
-		TableBlack: map[string]bool{},
-		DBWhite:    map[string]bool{},
-		TableWhite: map[string]bool{},
-	}
-	if filters == nil {
-		return
-	}
-	for _, v := range filters {
-		if !tool.FilterVerify(v) {
-			continue
-		}
-		arr := strings.Split(v.Rule, ".")
-		if len(arr) == 1 {
-			if v.Type == pipeline.FILTER_BLACK {
-				res.DBBlack[v.Rule] = true
-			} else {
-				res.DBWhite[v.Rule] = true
-			}
-		} else {
-			if v.Type == pipeline.FILTER_BLACK {
-				res.TableBlack[v.Rule] = true
-			} else {
-				res.TableWhite[v.Rule] = true
-			}
-		}
-
-	}
+	res = tree{tool.NewFilter(filters)}
 	return
 }
@@ -4,19 +4,20 @@ import (
 	"testing"
 
 	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
 	"github.com/jin06/binlogo/pkg/store/model/pipeline"
 )
 
 func TestIsFilter(t *testing.T) {
-	tr := &tree{
+	tr := &tree{&tool.Filter{
 		DBBlack:    map[string]bool{"mysql": true},
 		TableBlack: map[string]bool{"mall.order": true},
 		DBWhite:    map[string]bool{"pass": true},
 		TableWhite: map[string]bool{"mysql.pass": true},
-	}
+	}}
 	testMsg := &message2.Message{
-		Content: &message2.Content{
-			Head: &message2.Head{
+		Content: message2.Content{
+			Head: message2.Head{
 				Database: "mysql",
 				Table:    "user",
 			},
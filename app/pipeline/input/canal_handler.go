@@ -1,6 +1,9 @@
 package input
 
 import (
+	"time"
+	"unicode/utf8"
+
 	"github.com/go-mysql-org/go-mysql/canal"
 	"github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/go-mysql-org/go-mysql/replication"
@@ -8,7 +11,9 @@ import (
 	"github.com/jin06/binlogo/configs"
 	"github.com/jin06/binlogo/pkg/promeths"
 	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+	"github.com/pingcap/parser"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 )
 
 type canalHandler struct {
@@ -16,57 +21,267 @@ type canalHandler struct {
 	ch       chan *message.Message
 	pipe     *pipeline.Pipeline
 	messages []*message.Message
+	canal    *canal.Canal
+	parser   *parser.Parser
+	lastDDL  *replication.QueryEvent
+	// schema not nil if column schema is added to message head
+	schema *tableSchema
+	conv   *converter
+	// transaction mode, gtid and xid of the transaction being handled
+	transaction pipeline.TransactionMode
+	gtid        string
+	gtidSet     mysql.GTIDSet
+	xid         bool
+	// end not nil if the pipeline stops at end condition, finished is true after it is reached
+	end      *endCondition
+	finished bool
+	// timestamp of the event being handled, set by file source which has no canal
+	timestamp uint32
+	// spillRows rows of a transaction buffered in memory, rows after them are spilled to disk, 0 means never
+	spillRows int
+	spill     *spill
+	// rowsQuery max length of statement added to message head, 0 if not enabled. query is statement of rows being handled
+	rowsQuery int
+	query     string
+	// image marks absent and changed columns, nil if not needed
+	image *rowImage
+	// history maps rows to schema version valid at their position, nil for file source
+	history *schemaHistory
+	// reader reads events instead of canal, nil if canal reads them
+	reader *eventReader
 }
 
+// OnRow is called by canal, which reads binlog only if row image of mysql is FULL, all columns are present
 func (h *canalHandler) OnRow(e *canal.RowsEvent) error {
+	return h.onRows(e, nil)
+}
+
+// onRows handles rows event, skipped are indexes of columns absent in each row, nil if not known
+func (h *canalHandler) onRows(e *canal.RowsEvent, skipped [][]int) error {
 	// fmt.Println(e.Rows)
 	if h.messages == nil {
 		h.messages = []*message.Message{}
 	}
 	// fmt.Println("---> ", len(e.Rows))
 	// fmt.Println(e.Header.LogPos)
-	msgs := rowsMessage(e)
+	if h.history != nil && h.canal != nil {
+		synced, set := h.synced()
+		pos := mysql.Position{Name: synced.Name, Pos: e.Header.LogPos}
+		if table := h.history.table(e.Table, pos, set); table != e.Table {
+			unsignedRows(table, e.Rows)
+			e.Table = table
+		}
+	}
+	msgs := rowsMessage(e, h.conv)
+	if h.image != nil {
+		h.image.apply(e.Table, msgs, skipped)
+	}
+	if h.query != "" {
+		for _, msg := range msgs {
+			msg.Content.Head.Query = h.query
+		}
+	}
+	if h.schema != nil {
+		for _, msg := range msgs {
+			h.schema.fill(e.Table, msg, rowData(msg))
+		}
+	}
 	// h.msg = msg
+	if h.spillRows > 0 && h.transaction != pipeline.TRANSACTION_GROUP && len(h.messages)+len(msgs) > h.spillRows {
+		return h.spillMessages(msgs)
+	}
 	h.messages = append(h.messages, msgs...)
+	if h.spillRows > 0 {
+		promeths.InputBufferGauge.With(prometheus.Labels{"pipeline": h.pipe.Name, "node": configs.NodeName}).Set(float64(len(h.messages)))
+	}
+	return nil
+}
+
+// spillMessages keeps messages in memory up to spillRows, the rest are written to the spill file.
+// grouped transaction is one message, it is never spilled
+func (h *canalHandler) spillMessages(msgs []*message.Message) (err error) {
+	if h.spill == nil {
+		room := h.spillRows - len(h.messages)
+		h.messages = append(h.messages, msgs[:room]...)
+		msgs = msgs[room:]
+		if h.spill, err = newSpill(); err != nil {
+			return
+		}
+		promeths.InputSpillCounter.With(prometheus.Labels{"pipeline": h.pipe.Name, "node": configs.NodeName}).Inc()
+	}
+	for _, msg := range msgs {
+		if err = h.spill.write(msg); err != nil {
+			return
+		}
+	}
+	promeths.InputSpillRowsCounter.With(prometheus.Labels{"pipeline": h.pipe.Name, "node": configs.NodeName}).Add(float64(len(msgs)))
+	return
+}
+
+// synced returns position and gtid set synced by canal, or by reader if it reads events instead
+func (h *canalHandler) synced() (mysql.Position, mysql.GTIDSet) {
+	if h.reader != nil {
+		return h.reader.position()
+	}
+	return h.canal.SyncedPosition(), h.canal.SyncedGTIDSet()
+}
 
+// OnRowsQueryEvent keeps the statement written before row events when binlog_rows_query_log_events is on,
+// it is added to head of the following rows. It is called by event reader, canal of go-mysql v1.3.0 skips
+// rows query events, so binlog of mysql is read by event reader when rows_query is enabled
+func (h *canalHandler) OnRowsQueryEvent(e *replication.RowsQueryEvent) error {
+	if h.rowsQuery > 0 {
+		h.query = truncateQuery(string(e.Query), h.rowsQuery)
+	}
 	return nil
 }
+
+// rowsQueryLength returns max length of statement in message head, 0 if not enabled
+func rowsQueryLength(m *pipeline.Mysql) int {
+	if !m.RowsQuery {
+		return 0
+	}
+	if m.RowsQueryLength > 0 {
+		return m.RowsQueryLength
+	}
+	return pipeline.DEFAULT_ROWS_QUERY_LENGTH
+}
+
+// truncateQuery cuts query to at most max bytes without breaking a multibyte character
+func truncateQuery(query string, max int) string {
+	if len(query) <= max {
+		return query
+	}
+	for max > 0 && !utf8.RuneStart(query[max]) {
+		max--
+	}
+	return query[:max]
+}
+
 func (h *canalHandler) OnTableChanged(schema string, table string) error {
 	//fmt.Println(schema)
 	//fmt.Println(table)
+	if h.schema != nil {
+		h.schema.invalidate(schema, table)
+	}
+	if h.history != nil {
+		h.history.changed(schema, table)
+	}
 	return nil
 }
 func (h *canalHandler) OnPosSynced(pos mysql.Position, set mysql.GTIDSet, force bool) error {
 	defer func() {
 		h.messages = nil
+		h.gtid = ""
+		h.gtidSet = nil
+		h.xid = false
+		h.query = ""
+		if h.spill != nil {
+			h.spill.close()
+			h.spill = nil
+		}
+		if h.spillRows > 0 {
+			promeths.InputBufferGauge.With(prometheus.Labels{"pipeline": h.pipe.Name, "node": configs.NodeName}).Set(0)
+		}
 	}()
 	// fmt.Println("on pos synced", set)
+	if force && h.history != nil && h.canal != nil {
+		h.history.synced(h.canal.GetTable, pos, set)
+	}
+	if h.finished {
+		for _, msg := range h.messages {
+			message.Put(msg)
+		}
+		return nil
+	}
+	if h.end != nil && h.end.beyond(pos, h.gtidSet, h.firstTime()) {
+		for _, msg := range h.messages {
+			message.Put(msg)
+		}
+		h.finish()
+		return nil
+	}
+	if h.end != nil {
+		defer func() {
+			if h.end.reached(pos, set) {
+				h.finish()
+			}
+		}()
+	}
 	if h.messages == nil {
 		return nil
 	}
+	if h.spill != nil {
+		return h.sendSpilled(pos, set)
+	}
+	if h.xid {
+		h.messages = transactionMessages(h.transaction, h.messages, h.gtid)
+	}
 	total := len(h.messages)
 	for i := 0; i < total; i++ {
-		msg := h.messages[i]
-		msg.Content.Head.Position.BinlogPosition = pos.Pos
-		msg.Content.Head.Position.BinlogFile = pos.Name
-		msg.Content.Head.Position.PipelineName = h.pipe.Name
-		msg.Content.Head.Position.TotalRows = total
-		msg.Content.Head.Position.ConsumeRows = i + 1
-		//fmt.Println("on pos synced" ,set)
-		if set != nil {
-			msg.Content.Head.Position.GTIDSet = set.String()
-		}
-		h.ch <- msg
-		promeths.MessageTotalCounter.With(prometheus.Labels{"pipeline": h.pipe.Name, "node": configs.NodeName}).Inc()
+		h.send(h.messages[i], pos, set, total, i+1)
 	}
 	return nil
 }
 
+// sendSpilled sends messages in memory, then messages read back from the spill file
+func (h *canalHandler) sendSpilled(pos mysql.Position, set mysql.GTIDSet) (err error) {
+	total := len(h.messages) + h.spill.rows
+	marker := h.xid && h.transaction == pipeline.TRANSACTION_MARKER
+	var same *sameTable
+	if marker {
+		total += 2
+		same = &sameTable{}
+		for _, v := range h.messages {
+			same.add(v.Content.Head.Database, v.Content.Head.Table)
+		}
+		same.merge(&h.spill.same)
+	}
+	first := h.messages[0].Content.Head
+	consume := 0
+	if marker {
+		consume++
+		h.send(transactionMarker(message.TYPE_BEGIN, &first, same, h.gtid), pos, set, total, consume)
+	}
+	for _, msg := range h.messages {
+		consume++
+		h.send(msg, pos, set, total, consume)
+	}
+	err = h.spill.each(func(msg *message.Message) {
+		consume++
+		h.send(msg, pos, set, total, consume)
+	})
+	if err != nil {
+		return
+	}
+	if marker {
+		consume++
+		h.send(transactionMarker(message.TYPE_COMMIT, &first, same, h.gtid), pos, set, total, consume)
+	}
+	return
+}
+
+// send sets position of the message and sends it, consume is the index of message in the transaction from 1
+func (h *canalHandler) send(msg *message.Message, pos mysql.Position, set mysql.GTIDSet, total int, consume int) {
+	msg.Content.Head.Position.BinlogPosition = pos.Pos
+	msg.Content.Head.Position.BinlogFile = pos.Name
+	msg.Content.Head.Position.PipelineName = h.pipe.Name
+	msg.Content.Head.Position.TotalRows = total
+	msg.Content.Head.Position.ConsumeRows = consume
+	//fmt.Println("on pos synced" ,set)
+	if set != nil {
+		msg.Content.Head.Position.GTIDSet = set.String()
+	}
+	h.ch <- msg
+	promeths.MessageTotalCounter.With(prometheus.Labels{"pipeline": h.pipe.Name, "node": configs.NodeName}).Inc()
+}
+
 func (h *canalHandler) OnRotate(e *replication.RotateEvent) error {
 	return nil
 }
 
 func (h *canalHandler) OnXID(p mysql.Position) error {
+	h.xid = true
 	// fmt.Println("-------> on xid")
 	//if h.msg != nil {
 	//	fmt.Println("on xid ", p)
@@ -79,12 +294,61 @@ func (h *canalHandler) String() string {
 }
 
 func (h *canalHandler) OnGTID(set mysql.GTIDSet) (err error) {
+	if set != nil {
+		h.gtid = set.String()
+		h.gtidSet = set
+	}
 	return
 }
 
+// finish sends the finished marker, events after it are ignored until the pipeline is stopped
+func (h *canalHandler) finish() {
+	h.finished = true
+	msg := message.Get()
+	msg.Filter = true
+	msg.Finished = true
+	msg.Content.Head.Time = h.eventTime()
+	msg.Content.Head.Position.PipelineName = h.pipe.Name
+	h.ch <- msg
+	logrus.Infoln("End condition of pipeline reached", h.pipe.Name)
+}
+
+// firstTime returns time of the first buffered message, or time of the current event if there is none
+func (h *canalHandler) firstTime() uint32 {
+	if len(h.messages) > 0 {
+		return h.messages[0].Content.Head.Time
+	}
+	return h.eventTime()
+}
+
+// OnDDL buffers ddl messages, they are sent in OnPosSynced like rows
 func (h *canalHandler) OnDDL(nextPos mysql.Position, queryEvent *replication.QueryEvent) error {
-	//fmt.Println(nextPos, queryEvent)
-	//fmt.Println(string(queryEvent.Query))
-	//queryEvent.Dump(os.Stdout)
+	// canal calls OnDDL for every table changing statement of a query event,
+	// all statements are handled at the first call.
+	if h.lastDDL == queryEvent {
+		return nil
+	}
+	h.lastDDL = queryEvent
+	if h.parser == nil {
+		h.parser = parser.New()
+	}
+	msgs, err := ddlMessage(h.parser, queryEvent, h.eventTime())
+	if err != nil {
+		logrus.Warnln("Parse ddl error, it is sent as ddl of unknown type: ", err)
+	}
+	h.messages = append(h.messages, msgs...)
 	return nil
 }
+
+// eventTime returns timestamp of the event being handled,
+// for events whose header is not passed to handler
+func (h *canalHandler) eventTime() uint32 {
+	if h.timestamp > 0 {
+		return h.timestamp
+	}
+	now := uint32(time.Now().Unix())
+	if h.canal != nil {
+		return now - h.canal.GetDelay()
+	}
+	return now
+}
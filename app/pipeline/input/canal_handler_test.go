@@ -73,3 +73,39 @@ func TestCanalHandler(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHandlerRowsQuery(t *testing.T) {
+	handler := &canalHandler{
+		DummyEventHandler: canal.DummyEventHandler{},
+		ch:                make(chan *message.Message, 10),
+		pipe:              &pipeline.Pipeline{Name: "go_test_pipeline"},
+		rowsQuery:         rowsQueryLength(&pipeline.Mysql{RowsQuery: true, RowsQueryLength: 20}),
+	}
+	query := "update table1 set name = 'roy' where id = 10001"
+	if err := handler.OnRowsQueryEvent(&replication.RowsQueryEvent{Query: []byte(query)}); err != nil {
+		t.Fatal(err)
+	}
+	rowsEvent := &canal.RowsEvent{
+		Header: &replication.EventHeader{},
+		Action: canal.InsertAction,
+		Table: &schema.Table{
+			Schema:  "database1",
+			Name:    "table1",
+			Columns: []schema.TableColumn{{Name: "id", Type: schema.TYPE_NUMBER}},
+		},
+		Rows: [][]interface{}{{10001}},
+	}
+	if err := handler.OnRow(rowsEvent); err != nil {
+		t.Fatal(err)
+	}
+	if handler.messages[0].Content.Head.Query != query[:20] {
+		t.Fatal(handler.messages[0].Content.Head.Query)
+	}
+
+	if truncateQuery("insert '中文'", 10) != "insert '" || truncateQuery("insert", 10) != "insert" {
+		t.Fail()
+	}
+	if rowsQueryLength(&pipeline.Mysql{}) != 0 || rowsQueryLength(&pipeline.Mysql{RowsQuery: true}) != pipeline.DEFAULT_ROWS_QUERY_LENGTH {
+		t.Fail()
+	}
+}
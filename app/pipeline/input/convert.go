@@ -0,0 +1,288 @@
+package input
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/shopspring/decimal"
+)
+
+// mysqlTimeLayout layout of mysql datetime and timestamp string, without fraction
+const mysqlTimeLayout = "2006-01-02 15:04:05"
+
+// converter converts row values decoded by go-mysql to stable json representations.
+// decimal as string, binary as base64, enum and set as labels, json as nested object,
+// datetime and timestamp in ISO-8601 of the configured time zone
+type converter struct {
+	loc *time.Location
+}
+
+// newConverter returns a converter, empty zone means UTC
+func newConverter(zone string) (c *converter, err error) {
+	c = &converter{loc: time.UTC}
+	if zone == "" {
+		return
+	}
+	c.loc, err = time.LoadLocation(zone)
+	return
+}
+
+// row converts values of a row to map keyed by column name
+func (c *converter) row(table *schema.Table, row []interface{}) (res map[string]interface{}) {
+	res = map[string]interface{}{}
+	for key, val := range row {
+		if len(table.Columns) > key {
+			res[table.Columns[key].Name] = c.value(&table.Columns[key], val)
+		} else {
+			res[strconv.Itoa(key)] = val
+		}
+	}
+	return
+}
+
+// value converts a value by column type
+func (c *converter) value(column *schema.TableColumn, val interface{}) interface{} {
+	if val == nil || c == nil || column == nil {
+		return val
+	}
+	switch column.Type {
+	case schema.TYPE_DECIMAL:
+		{
+			return c.decimal(column, val)
+		}
+	case schema.TYPE_BINARY:
+		{
+			return c.binary(val)
+		}
+	case schema.TYPE_ENUM:
+		{
+			return c.enum(column, val)
+		}
+	case schema.TYPE_SET:
+		{
+			return c.set(column, val)
+		}
+	case schema.TYPE_BIT:
+		{
+			return c.bit(column, val)
+		}
+	case schema.TYPE_JSON:
+		{
+			return c.json(val)
+		}
+	case schema.TYPE_DATETIME:
+		{
+			return c.time(val, c.loc)
+		}
+	case schema.TYPE_TIMESTAMP:
+		{
+			// timestamp is decoded in UTC, see prepareCanal
+			return c.time(val, time.UTC)
+		}
+	}
+	if b, ok := val.([]byte); ok {
+		if strings.Contains(column.RawType, "blob") {
+			return base64.StdEncoding.EncodeToString(b)
+		}
+		return string(b)
+	}
+	return val
+}
+
+// decimal renders decimal as string with the scale of column, e.g. 1.50 of decimal(10,2)
+func (c *converter) decimal(column *schema.TableColumn, val interface{}) interface{} {
+	switch v := val.(type) {
+	case decimal.Decimal:
+		{
+			return v.StringFixed(decimalScale(column.RawType))
+		}
+	case float64:
+		{
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	case []byte:
+		{
+			return string(v)
+		}
+	}
+	return val
+}
+
+func (c *converter) binary(val interface{}) interface{} {
+	switch v := val.(type) {
+	case string:
+		{
+			return base64.StdEncoding.EncodeToString([]byte(v))
+		}
+	case []byte:
+		{
+			return base64.StdEncoding.EncodeToString(v)
+		}
+	}
+	return val
+}
+
+// enum converts index of enum to label, index 0 is the empty value of invalid input
+func (c *converter) enum(column *schema.TableColumn, val interface{}) interface{} {
+	switch v := val.(type) {
+	case int64:
+		{
+			if v <= 0 || int(v) > len(column.EnumValues) {
+				return ""
+			}
+			return column.EnumValues[v-1]
+		}
+	case []byte:
+		{
+			return string(v)
+		}
+	}
+	return val
+}
+
+// set converts bitmap of set to labels joined by comma, the same as mysql shows it
+func (c *converter) set(column *schema.TableColumn, val interface{}) interface{} {
+	switch v := val.(type) {
+	case int64:
+		{
+			labels := []string{}
+			for i, label := range column.SetValues {
+				if v&(1<<uint(i)) != 0 {
+					labels = append(labels, label)
+				}
+			}
+			return strings.Join(labels, ",")
+		}
+	case []byte:
+		{
+			return string(v)
+		}
+	}
+	return val
+}
+
+// bit converts bit(1) to bool, wider bit to string of binary digits
+func (c *converter) bit(column *schema.TableColumn, val interface{}) interface{} {
+	var n uint64
+	switch v := val.(type) {
+	case int64:
+		{
+			n = uint64(v)
+		}
+	case []byte:
+		{
+			for _, b := range v {
+				n = n<<8 | uint64(b)
+			}
+		}
+	default:
+		return val
+	}
+	width := bitWidth(column.RawType)
+	if width == 1 {
+		return n == 1
+	}
+	s := strconv.FormatUint(n, 2)
+	if len(s) < width {
+		s = strings.Repeat("0", width-len(s)) + s
+	}
+	return s
+}
+
+// bitWidth returns width of bit(n) column
+func bitWidth(rawType string) int {
+	args := typeArgs(rawType)
+	if len(args) == 0 {
+		return 1
+	}
+	return args[0]
+}
+
+// decimalScale returns scale of decimal(m,d) column
+func decimalScale(rawType string) int32 {
+	args := typeArgs(rawType)
+	if len(args) < 2 {
+		return 0
+	}
+	return int32(args[1])
+}
+
+// typeArgs returns numbers in parentheses of column type, e.g. 10, 2 of decimal(10,2) unsigned
+func typeArgs(rawType string) (args []int) {
+	start := strings.Index(rawType, "(")
+	end := strings.Index(rawType, ")")
+	if start < 0 || end < start {
+		return
+	}
+	for _, v := range strings.Split(rawType[start+1:end], ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil
+		}
+		args = append(args, n)
+	}
+	return
+}
+
+// json unmarshal json column, numbers are kept as json.Number to avoid losing precision
+func (c *converter) json(val interface{}) interface{} {
+	var b []byte
+	switch v := val.(type) {
+	case []byte:
+		{
+			b = v
+		}
+	case string:
+		{
+			b = []byte(v)
+		}
+	default:
+		return val
+	}
+	var res interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&res); err != nil {
+		return string(b)
+	}
+	return res
+}
+
+// time converts mysql time string read in loc to ISO-8601 of the configured time zone,
+// zero value such as 0000-00-00 00:00:00 is kept
+func (c *converter) time(val interface{}, loc *time.Location) interface{} {
+	var s string
+	switch v := val.(type) {
+	case string:
+		{
+			s = v
+		}
+	case []byte:
+		{
+			s = string(v)
+		}
+	case time.Time:
+		{
+			return v.In(c.loc).Format(time.RFC3339Nano)
+		}
+	default:
+		return val
+	}
+	layout := mysqlTimeLayout
+	outLayout := "2006-01-02T15:04:05"
+	if i := strings.Index(s, "."); i >= 0 {
+		frac := "." + strings.Repeat("0", len(s)-i-1)
+		layout += frac
+		outLayout += frac
+	}
+	t, err := time.ParseInLocation(layout, s, loc)
+	if err != nil {
+		return s
+	}
+	return t.In(c.loc).Format(outLayout + "Z07:00")
+}
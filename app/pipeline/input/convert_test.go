@@ -0,0 +1,47 @@
+package input
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/shopspring/decimal"
+)
+
+func TestConverter(t *testing.T) {
+	conv, err := newConverter("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		column schema.TableColumn
+		val    interface{}
+		want   interface{}
+	}{
+		{schema.TableColumn{Type: schema.TYPE_DECIMAL, RawType: "decimal(10,2)"}, decimal.RequireFromString("1.50"), "1.50"},
+		{schema.TableColumn{Type: schema.TYPE_DECIMAL, RawType: "decimal(10,0)"}, decimal.RequireFromString("15"), "15"},
+		{schema.TableColumn{Type: schema.TYPE_BINARY}, "\x01\x02", "AQI="},
+		{schema.TableColumn{Type: schema.TYPE_STRING, RawType: "blob"}, []byte{1, 2}, "AQI="},
+		{schema.TableColumn{Type: schema.TYPE_STRING, RawType: "text"}, []byte("roy"), "roy"},
+		{schema.TableColumn{Type: schema.TYPE_ENUM, EnumValues: []string{"a", "b"}}, int64(2), "b"},
+		{schema.TableColumn{Type: schema.TYPE_SET, SetValues: []string{"a", "b", "c"}}, int64(5), "a,c"},
+		{schema.TableColumn{Type: schema.TYPE_BIT, RawType: "bit(1)"}, int64(1), true},
+		{schema.TableColumn{Type: schema.TYPE_BIT, RawType: "bit(4)"}, int64(5), "0101"},
+		{schema.TableColumn{Type: schema.TYPE_DATETIME}, "2021-11-22 10:00:00.50", "2021-11-22T10:00:00.50+08:00"},
+		{schema.TableColumn{Type: schema.TYPE_TIMESTAMP}, "2021-11-22 02:00:00", "2021-11-22T10:00:00+08:00"},
+		{schema.TableColumn{Type: schema.TYPE_DATETIME}, "0000-00-00 00:00:00", "0000-00-00 00:00:00"},
+		{schema.TableColumn{Type: schema.TYPE_NUMBER}, int32(10), int32(10)},
+	}
+	for _, v := range cases {
+		if got := conv.value(&v.column, v.val); got != v.want {
+			t.Errorf("%v: want %v, got %v", v.val, v.want, got)
+		}
+	}
+	obj, ok := conv.value(&schema.TableColumn{Type: schema.TYPE_JSON}, []byte(`{"id":10}`)).(map[string]interface{})
+	if !ok || obj["id"] != json.Number("10") {
+		t.Fail()
+	}
+	if _, err = newConverter("Wrong/Zone"); err == nil {
+		t.Fail()
+	}
+}
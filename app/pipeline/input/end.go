@@ -0,0 +1,59 @@
+package input
+
+import (
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// endCondition end condition of bounded replay, parsed from pipeline.End
+type endCondition struct {
+	pos  *mysql.Position
+	gset mysql.GTIDSet
+	time uint32
+}
+
+// newEndCondition returns end condition of the pipeline, nil if the pipeline runs forever
+func newEndCondition(end *pipeline.End, flavor string) (c *endCondition, err error) {
+	if end.IsEmpty() {
+		return
+	}
+	c = &endCondition{}
+	if end.BinlogFile != "" {
+		c.pos = &mysql.Position{Name: end.BinlogFile, Pos: end.BinlogPosition}
+	}
+	if end.GTIDSet != "" {
+		if c.gset, err = mysql.ParseGTIDSet(flavor, end.GTIDSet); err != nil {
+			return nil, err
+		}
+	}
+	if !end.Time.IsZero() {
+		c.time = uint32(end.Time.Unix())
+	}
+	return
+}
+
+// beyond returns true if the transaction is after the end and should not be sent.
+// pos is the position after the transaction, gtid is gtid of the transaction, t is the time of its first event
+func (c *endCondition) beyond(pos mysql.Position, gtid mysql.GTIDSet, t uint32) bool {
+	if c.time > 0 && t >= c.time {
+		return true
+	}
+	if c.pos != nil && pos.Name != "" && pos.Compare(*c.pos) > 0 {
+		return true
+	}
+	if c.gset != nil && gtid != nil && !c.gset.Contain(gtid) {
+		return true
+	}
+	return false
+}
+
+// reached returns true if all events up to the end have been sent
+func (c *endCondition) reached(pos mysql.Position, set mysql.GTIDSet) bool {
+	if c.pos != nil && pos.Name != "" && pos.Compare(*c.pos) >= 0 {
+		return true
+	}
+	if c.gset != nil && set != nil && set.Contain(c.gset) {
+		return true
+	}
+	return false
+}
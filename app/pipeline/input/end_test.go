@@ -0,0 +1,96 @@
+package input
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/promeths"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestEndCondition(t *testing.T) {
+	c, err := newEndCondition(nil, mysql.MySQLFlavor)
+	if err != nil || c != nil {
+		t.Fail()
+	}
+	c, err = newEndCondition(&pipeline.End{BinlogFile: "mysql-bin.000002", BinlogPosition: 1000}, mysql.MySQLFlavor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.beyond(mysql.Position{Name: "mysql-bin.000002", Pos: 1000}, nil, 0) {
+		t.Fail()
+	}
+	if !c.beyond(mysql.Position{Name: "mysql-bin.000002", Pos: 1200}, nil, 0) {
+		t.Fail()
+	}
+	if c.reached(mysql.Position{Name: "mysql-bin.000001", Pos: 2000}, nil) {
+		t.Fail()
+	}
+	if !c.reached(mysql.Position{Name: "mysql-bin.000002", Pos: 1000}, nil) {
+		t.Fail()
+	}
+
+	end := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	c, _ = newEndCondition(&pipeline.End{Time: end}, mysql.MySQLFlavor)
+	if c.beyond(mysql.Position{}, nil, uint32(end.Unix())-1) || !c.beyond(mysql.Position{}, nil, uint32(end.Unix())) {
+		t.Fail()
+	}
+
+	c, err = newEndCondition(&pipeline.End{GTIDSet: "045c649a-408d-11ec-ae21-0242ac110006:1-64"}, mysql.MySQLFlavor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, _ := mysql.ParseGTIDSet(mysql.MySQLFlavor, "045c649a-408d-11ec-ae21-0242ac110006:64")
+	out, _ := mysql.ParseGTIDSet(mysql.MySQLFlavor, "045c649a-408d-11ec-ae21-0242ac110006:65")
+	if c.beyond(mysql.Position{}, in, 0) || !c.beyond(mysql.Position{}, out, 0) {
+		t.Fail()
+	}
+	set, _ := mysql.ParseGTIDSet(mysql.MySQLFlavor, "045c649a-408d-11ec-ae21-0242ac110006:1-63")
+	if c.reached(mysql.Position{}, set) {
+		t.Fail()
+	}
+	set, _ = mysql.ParseGTIDSet(mysql.MySQLFlavor, "045c649a-408d-11ec-ae21-0242ac110006:1-64")
+	if !c.reached(mysql.Position{}, set) {
+		t.Fail()
+	}
+
+	if _, err = newEndCondition(&pipeline.End{GTIDSet: "invalid"}, mysql.MySQLFlavor); err == nil {
+		t.Fail()
+	}
+}
+
+func TestHandlerFinish(t *testing.T) {
+	promeths.Init()
+	end, _ := newEndCondition(&pipeline.End{BinlogFile: "mysql-bin.000001", BinlogPosition: 1000}, mysql.MySQLFlavor)
+	handler := &canalHandler{
+		DummyEventHandler: canal.DummyEventHandler{},
+		ch:                make(chan *message2.Message, 10),
+		pipe:              &pipeline.Pipeline{Name: "go_test_pipeline"},
+		end:               end,
+	}
+	handler.messages = transactionRows()
+	if err := handler.OnPosSynced(mysql.Position{Name: "mysql-bin.000001", Pos: 1000}, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(handler.ch) != 3 || !handler.finished {
+		t.Fatal(len(handler.ch))
+	}
+	for i := 0; i < 2; i++ {
+		if msg := <-handler.ch; msg.Finished {
+			t.Fail()
+		}
+	}
+	if msg := <-handler.ch; !msg.Finished || !msg.Filter {
+		t.Fail()
+	}
+	handler.messages = transactionRows()
+	if err := handler.OnPosSynced(mysql.Position{Name: "mysql-bin.000001", Pos: 2000}, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(handler.ch) != 0 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,308 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/jin06/binlogo/pkg/store/dao/dao_pipe"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+	"github.com/sirupsen/logrus"
+)
+
+// runFile reads binlog files of file source, the pipeline is finished after all files are read
+func (r *Input) runFile() (err error) {
+	files, err := binlogFileNames(r.pipe.Mysql)
+	if err != nil {
+		return
+	}
+	if len(files) == 0 {
+		err = errors.New("no binlog file found")
+		return
+	}
+	reader := &eventReader{
+		handler: &canalHandler{
+			ch:          r.OutChan,
+			pipe:        r.pipe,
+			schema:      r.schema,
+			conv:        r.conv,
+			transaction: r.pipe.Mysql.Transaction,
+			spillRows:   r.pipe.Mysql.SpillRows,
+			rowsQuery:   rowsQueryLength(r.pipe.Mysql),
+			image:       newRowImage(r.pipe.Mysql),
+			end:         r.end,
+		},
+		flavor: r.pipe.Mysql.Flavor.YaString(),
+	}
+	start := 0
+	var offset int64
+	record, err := dao_pipe.GetRecord(r.Options.PipeName)
+	if err != nil {
+		return
+	}
+	if record != nil && record.Pre != nil && record.Pre.BinlogFile != "" {
+		start = -1
+		for i, v := range files {
+			if filepath.Base(v) == record.Pre.BinlogFile {
+				start = i
+				offset = int64(record.Pre.BinlogPosition)
+				break
+			}
+		}
+		if start < 0 {
+			err = fmt.Errorf("binlog file %s of position not found", record.Pre.BinlogFile)
+			return
+		}
+		if record.Pre.GTIDSet != "" {
+			if reader.gset, err = mysql.ParseGTIDSet(reader.flavor, record.Pre.GTIDSet); err != nil {
+				return
+			}
+		}
+	}
+	logrus.Infoln("Read binlog files of pipeline", r.Options.PipeName, files[start:])
+	for i := start; i < len(files); i++ {
+		if err = reader.parse(r.ctx, files[i], offset); err != nil || reader.stopped {
+			return
+		}
+		offset = 0
+	}
+	if !reader.handler.finished {
+		reader.handler.finish()
+	}
+	return
+}
+
+// parse reads events of the binlog file from offset, offset less than 4 means the start of file
+func (f *eventReader) parse(ctx context.Context, file string, offset int64) (err error) {
+	parser := replication.NewBinlogParser()
+	parser.SetFlavor(f.flavor)
+	// the same as canal config, see prepareCanal
+	parser.SetUseDecimal(true)
+	parser.SetTimestampStringLocation(time.UTC)
+	name := filepath.Base(file)
+	err = parser.ParseFile(file, offset, func(ev *replication.BinlogEvent) error {
+		return f.onEvent(ctx, name, ev)
+	})
+	if f.stopped {
+		err = nil
+	}
+	return
+}
+
+// binlogFileNames returns binlog files read by file source in order.
+// without configured files, files in directory with numeric extension such as mysql-bin.000001 are read in name order
+func binlogFileNames(m *pipeline.Mysql) (files []string, err error) {
+	if len(m.BinlogFiles) > 0 {
+		files = make([]string, len(m.BinlogFiles))
+		for i, v := range m.BinlogFiles {
+			if m.BinlogDir != "" && !filepath.IsAbs(v) {
+				v = filepath.Join(m.BinlogDir, v)
+			}
+			// files are checked before reading, so a missing file does not stop the pipeline halfway
+			if _, err = os.Stat(v); err != nil {
+				return
+			}
+			files[i] = v
+		}
+		return
+	}
+	if m.BinlogDir == "" {
+		err = errors.New("binlog directory is empty")
+		return
+	}
+	infos, err := os.ReadDir(m.BinlogDir)
+	if err != nil {
+		return
+	}
+	files = []string{}
+	for _, v := range infos {
+		if v.IsDir() {
+			continue
+		}
+		ext := strings.TrimPrefix(filepath.Ext(v.Name()), ".")
+		if _, errExt := strconv.ParseUint(ext, 10, 64); errExt != nil {
+			continue
+		}
+		files = append(files, filepath.Join(m.BinlogDir, v.Name()))
+	}
+	sort.Strings(files)
+	return
+}
+
+// fileAction returns action of rows event, empty if it is not a row change
+func fileAction(t replication.EventType) string {
+	switch t {
+	case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
+		{
+			return canal.InsertAction
+		}
+	case replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+		{
+			return canal.UpdateAction
+		}
+	case replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
+		{
+			return canal.DeleteAction
+		}
+	}
+	return ""
+}
+
+// fileTable builds table schema from table map event without mysql server.
+// column names, signedness, enum and set values, charset and primary key are only written
+// when binlog_row_metadata is FULL, otherwise columns are named by index
+func fileTable(e *replication.TableMapEvent) (table *schema.Table) {
+	table = &schema.Table{
+		Schema:  string(e.Schema),
+		Name:    string(e.Table),
+		Columns: make([]schema.TableColumn, e.ColumnCount),
+	}
+	names := e.ColumnNameString()
+	unsigned := e.UnsignedMap()
+	collations := e.CollationMap()
+	enums := e.EnumStrValueMap()
+	sets := e.SetStrValueMap()
+	for i := range table.Columns {
+		column := &table.Columns[i]
+		column.Name = strconv.Itoa(i)
+		if i < len(names) {
+			column.Name = names[i]
+		}
+		column.IsUnsigned = unsigned[i]
+		if column.IsUnsigned {
+			table.UnsignedColumns = append(table.UnsignedColumns, i)
+		}
+		// collation 63 is binary
+		collation, hasCollation := collations[i]
+		binary := hasCollation && collation == 63
+		meta := e.ColumnMeta[i]
+		switch {
+		case e.IsEnumColumn(i):
+			{
+				column.Type = schema.TYPE_ENUM
+				column.RawType = "enum"
+				column.EnumValues = enums[i]
+			}
+		case e.IsSetColumn(i):
+			{
+				column.Type = schema.TYPE_SET
+				column.RawType = "set"
+				column.SetValues = sets[i]
+			}
+		default:
+			column.Type, column.RawType = fileColumnType(e.ColumnType[i], meta, binary)
+		}
+		if column.IsUnsigned {
+			column.RawType += " unsigned"
+		}
+	}
+	for _, v := range e.PrimaryKey {
+		table.PKColumns = append(table.PKColumns, int(v))
+	}
+	return
+}
+
+// fileColumnType returns schema type and raw type of binlog column type, raw type carries arguments converter needs
+func fileColumnType(t byte, meta uint16, binary bool) (int, string) {
+	switch t {
+	case mysql.MYSQL_TYPE_TINY, mysql.MYSQL_TYPE_SHORT, mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_LONGLONG, mysql.MYSQL_TYPE_YEAR:
+		{
+			return schema.TYPE_NUMBER, "int"
+		}
+	case mysql.MYSQL_TYPE_INT24:
+		{
+			return schema.TYPE_MEDIUM_INT, "mediumint"
+		}
+	case mysql.MYSQL_TYPE_FLOAT, mysql.MYSQL_TYPE_DOUBLE:
+		{
+			return schema.TYPE_FLOAT, "double"
+		}
+	case mysql.MYSQL_TYPE_NEWDECIMAL:
+		{
+			return schema.TYPE_DECIMAL, fmt.Sprintf("decimal(%d,%d)", meta>>8, meta&0xff)
+		}
+	case mysql.MYSQL_TYPE_BIT:
+		{
+			return schema.TYPE_BIT, fmt.Sprintf("bit(%d)", (meta>>8)*8+meta&0xff)
+		}
+	case mysql.MYSQL_TYPE_DATETIME, mysql.MYSQL_TYPE_DATETIME2:
+		{
+			return schema.TYPE_DATETIME, "datetime"
+		}
+	case mysql.MYSQL_TYPE_TIMESTAMP, mysql.MYSQL_TYPE_TIMESTAMP2:
+		{
+			return schema.TYPE_TIMESTAMP, "timestamp"
+		}
+	case mysql.MYSQL_TYPE_DATE, mysql.MYSQL_TYPE_NEWDATE:
+		{
+			return schema.TYPE_DATE, "date"
+		}
+	case mysql.MYSQL_TYPE_TIME, mysql.MYSQL_TYPE_TIME2:
+		{
+			return schema.TYPE_TIME, "time"
+		}
+	case mysql.MYSQL_TYPE_JSON:
+		{
+			return schema.TYPE_JSON, "json"
+		}
+	case mysql.MYSQL_TYPE_GEOMETRY:
+		{
+			return schema.TYPE_BINARY, "geometry"
+		}
+	case mysql.MYSQL_TYPE_BLOB, mysql.MYSQL_TYPE_TINY_BLOB, mysql.MYSQL_TYPE_MEDIUM_BLOB, mysql.MYSQL_TYPE_LONG_BLOB:
+		{
+			if binary {
+				return schema.TYPE_STRING, "blob"
+			}
+			return schema.TYPE_STRING, "text"
+		}
+	}
+	if binary {
+		return schema.TYPE_BINARY, "varbinary"
+	}
+	return schema.TYPE_STRING, "varchar"
+}
+
+// unsignedRows converts values of unsigned columns, the same as canal does for rows of binlog
+func unsignedRows(table *schema.Table, rows [][]interface{}) {
+	for _, row := range rows {
+		for _, idx := range table.UnsignedColumns {
+			if idx >= len(row) {
+				continue
+			}
+			switch v := row[idx].(type) {
+			case int8:
+				{
+					row[idx] = uint8(v)
+				}
+			case int16:
+				{
+					row[idx] = uint16(v)
+				}
+			case int32:
+				{
+					// mediumint is decoded as int32 of 3 bytes
+					if v < 0 && table.Columns[idx].Type == schema.TYPE_MEDIUM_INT {
+						row[idx] = uint32(v + 1<<24)
+					} else {
+						row[idx] = uint32(v)
+					}
+				}
+			case int64:
+				{
+					row[idx] = uint64(v)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package input
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestBinlogFileNames(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"mysql-bin.000002", "mysql-bin.000001", "mysql-bin.index", "readme.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := binlogFileNames(&pipeline.Mysql{BinlogDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(files[0]) != "mysql-bin.000001" || filepath.Base(files[1]) != "mysql-bin.000002" {
+		t.Fatal(files)
+	}
+	other := filepath.Join(t.TempDir(), "mysql-bin.000009")
+	if err = os.WriteFile(other, []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err = binlogFileNames(&pipeline.Mysql{BinlogDir: dir, BinlogFiles: []string{"mysql-bin.000002", other}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files[0] != filepath.Join(dir, "mysql-bin.000002") || files[1] != other {
+		t.Fatal(files)
+	}
+	if _, err = binlogFileNames(&pipeline.Mysql{BinlogDir: dir, BinlogFiles: []string{"mysql-bin.000003"}}); err == nil {
+		t.Fail()
+	}
+	if _, err = binlogFileNames(&pipeline.Mysql{}); err == nil {
+		t.Fail()
+	}
+}
+
+func fileTableEvent() *replication.TableMapEvent {
+	return &replication.TableMapEvent{
+		Schema:      []byte("database1"),
+		Table:       []byte("table1"),
+		ColumnCount: 4,
+		ColumnType:  []byte{mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_NEWDECIMAL, mysql.MYSQL_TYPE_BIT, mysql.MYSQL_TYPE_VARCHAR},
+		ColumnMeta:  []uint16{0, 10<<8 | 2, 1, 255},
+		ColumnName:  [][]byte{[]byte("id"), []byte("price"), []byte("flag"), []byte("name")},
+		// id is unsigned, price is signed
+		SignednessBitmap: []byte{0x80},
+		PrimaryKey:       []uint64{0},
+	}
+}
+
+func TestFileTable(t *testing.T) {
+	table := fileTable(fileTableEvent())
+	if table.Schema != "database1" || table.Name != "table1" || len(table.Columns) != 4 {
+		t.Fatal(table)
+	}
+	if table.Columns[0].Name != "id" || !table.Columns[0].IsUnsigned || len(table.UnsignedColumns) != 1 {
+		t.Fail()
+	}
+	if table.Columns[1].Type != schema.TYPE_DECIMAL || decimalScale(table.Columns[1].RawType) != 2 {
+		t.Fail()
+	}
+	if table.Columns[2].Type != schema.TYPE_BIT || bitWidth(table.Columns[2].RawType) != 1 {
+		t.Fail()
+	}
+	if table.Columns[3].Type != schema.TYPE_STRING {
+		t.Fail()
+	}
+	if len(table.PKColumns) != 1 || table.GetPKColumn(0).Name != "id" {
+		t.Fail()
+	}
+	rows := [][]interface{}{{int32(-1), nil, int64(1), "roy"}}
+	unsignedRows(table, rows)
+	if rows[0][0] != uint32(4294967295) {
+		t.Fail()
+	}
+
+	// without full row metadata columns are named by index
+	e := fileTableEvent()
+	e.ColumnName = nil
+	table = fileTable(e)
+	if table.Columns[0].Name != "0" || table.Columns[3].Name != "3" {
+		t.Fail()
+	}
+}
+
+func TestFileAction(t *testing.T) {
+	if fileAction(replication.WRITE_ROWS_EVENTv2) != "insert" || fileAction(replication.UPDATE_ROWS_EVENTv1) != "update" ||
+		fileAction(replication.DELETE_ROWS_EVENTv2) != "delete" || fileAction(replication.QUERY_EVENT) != "" {
+		t.Fail()
+	}
+}
+
+func TestFileRowsQuery(t *testing.T) {
+	m := &pipeline.Mysql{Source: pipeline.SOURCE_FILE, RowsQuery: true}
+	reader := &eventReader{
+		handler: &canalHandler{
+			ch:        make(chan *message2.Message, 10),
+			pipe:      &pipeline.Pipeline{Name: "go_test_pipeline", Mysql: m},
+			rowsQuery: rowsQueryLength(m),
+			image:     newRowImage(m),
+		},
+	}
+	query := "insert into table1 values (1, 1.50, 1, 'roy')"
+	events := []*replication.BinlogEvent{
+		{
+			Header: &replication.EventHeader{EventType: replication.ROWS_QUERY_EVENT, LogPos: 100},
+			Event:  &replication.RowsQueryEvent{Query: []byte(query)},
+		},
+		{
+			Header: &replication.EventHeader{EventType: replication.WRITE_ROWS_EVENTv2, LogPos: 200},
+			Event:  &replication.RowsEvent{Table: fileTableEvent(), Rows: [][]interface{}{{int32(1), nil, int64(1), "roy"}}},
+		},
+	}
+	for _, ev := range events {
+		if err := reader.onEvent(context.Background(), "mysql-bin.000001", ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(reader.handler.messages) != 1 || reader.handler.messages[0].Content.Head.Query != query {
+		t.Fatal(reader.handler.messages)
+	}
+}
@@ -0,0 +1,239 @@
+package input
+
+import (
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/jin06/binlogo/pkg/store/dao/dao_pipe"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+	"github.com/sirupsen/logrus"
+)
+
+// schemaHistory keeps versions of table schema in etcd. canal looks up the current schema of mysql,
+// rows of binlog replayed after ddl are mapped to columns of the version valid at their position instead
+type schemaHistory struct {
+	pipeName string
+	flavor   string
+	// gtid compares positions by gtid set, binlog files differ between servers after failover
+	gtid   bool
+	tables map[string]*historyTable
+	// pending tables changed by ddl, versions are recorded when position of the ddl is synced
+	pending [][2]string
+	load    func(database string, table string) (*pipeline.SchemaHistory, error)
+	save    func(h *pipeline.SchemaHistory) error
+}
+
+// historyTable schema history of a table, with tables and gtid sets of versions built from it
+type historyTable struct {
+	history *pipeline.SchemaHistory
+	tables  []*schema.Table
+	sets    []mysql.GTIDSet
+}
+
+func newSchemaHistory(p *pipeline.Pipeline) *schemaHistory {
+	return &schemaHistory{
+		pipeName: p.Name,
+		flavor:   p.Mysql.Flavor.YaString(),
+		gtid:     p.Mysql.Mode == pipeline.MODE_GTID,
+		tables:   map[string]*historyTable{},
+		load: func(database string, table string) (*pipeline.SchemaHistory, error) {
+			return dao_pipe.GetSchemaHistory(p.Name, database, table)
+		},
+		save: dao_pipe.UpdateSchemaHistory,
+	}
+}
+
+// table returns table of the schema version valid at pos and set, current is the current schema of mysql.
+// schema of a table seen for the first time is recorded at pos and set, schema of rows before the first version
+// is unknown, the current schema is used for them
+func (s *schemaHistory) table(current *schema.Table, pos mysql.Position, set mysql.GTIDSet) *schema.Table {
+	t, err := s.get(current.Schema, current.Name)
+	if err != nil {
+		logrus.Errorln("Get schema history error: ", err)
+		return current
+	}
+	if t == nil {
+		s.add(current, historyPosition(pos, set))
+		return current
+	}
+	// versions are in order of position
+	idx := -1
+	for i := len(t.tables) - 1; i >= 0; i-- {
+		if s.valid(t, i, pos, set) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 || sameSchema(t.tables[idx], current) {
+		return current
+	}
+	return t.tables[idx]
+}
+
+// changed is called when table is changed by ddl
+func (s *schemaHistory) changed(database string, table string) {
+	s.pending = append(s.pending, [2]string{database, table})
+}
+
+// synced records schema of tables changed by ddl at pos and set. a ddl replayed after rewinding
+// is not newer than the latest version, its schema is kept
+func (s *schemaHistory) synced(getTable func(database string, table string) (*schema.Table, error), pos mysql.Position, set mysql.GTIDSet) {
+	pending := s.pending
+	s.pending = nil
+	for _, v := range pending {
+		current, err := getTable(v[0], v[1])
+		if err != nil {
+			// dropped table
+			continue
+		}
+		position := historyPosition(pos, set)
+		t, err := s.get(v[0], v[1])
+		if err != nil {
+			logrus.Errorln("Get schema history error: ", err)
+			continue
+		}
+		if t == nil {
+			s.add(current, position)
+			continue
+		}
+		last := len(t.tables) - 1
+		if !s.newer(t, last, pos, set) || sameSchema(t.tables[last], current) {
+			continue
+		}
+		s.add(current, position)
+	}
+}
+
+func (s *schemaHistory) get(database string, table string) (t *historyTable, err error) {
+	key := database + "." + table
+	if t = s.tables[key]; t != nil {
+		return
+	}
+	h, err := s.load(database, table)
+	if err != nil || h == nil {
+		return
+	}
+	t = &historyTable{history: h}
+	for _, v := range h.Versions {
+		t.append(v, s.flavor)
+	}
+	s.tables[key] = t
+	return
+}
+
+// historyPosition returns position of schema version at pos and set
+func historyPosition(pos mysql.Position, set mysql.GTIDSet) (position *pipeline.Position) {
+	position = &pipeline.Position{BinlogFile: pos.Name, BinlogPosition: pos.Pos}
+	if set != nil {
+		position.GTIDSet = set.String()
+	}
+	return
+}
+
+// add appends version of table valid from position and saves history, nil position of versions
+// recorded by earlier releases is valid from the beginning
+func (s *schemaHistory) add(table *schema.Table, position *pipeline.Position) {
+	key := table.Schema + "." + table.Name
+	t := s.tables[key]
+	if t == nil {
+		t = &historyTable{history: &pipeline.SchemaHistory{
+			PipelineName: s.pipeName,
+			Database:     table.Schema,
+			Table:        table.Name,
+		}}
+		s.tables[key] = t
+	}
+	v := schemaVersion(table, position)
+	t.history.Versions = append(t.history.Versions, v)
+	t.append(v, s.flavor)
+	if err := s.save(t.history); err != nil {
+		logrus.Errorln("Save schema history error: ", err)
+	}
+}
+
+// valid returns true if version at idx is valid at pos and set
+func (s *schemaHistory) valid(t *historyTable, idx int, pos mysql.Position, set mysql.GTIDSet) bool {
+	v := t.history.Versions[idx]
+	if v.Position == nil {
+		return true
+	}
+	if s.gtid && t.sets[idx] != nil {
+		return set != nil && set.Contain(t.sets[idx])
+	}
+	return pos.Compare(mysql.Position{Name: v.Position.BinlogFile, Pos: v.Position.BinlogPosition}) >= 0
+}
+
+// newer returns true if pos and set are after the version at idx
+func (s *schemaHistory) newer(t *historyTable, idx int, pos mysql.Position, set mysql.GTIDSet) bool {
+	v := t.history.Versions[idx]
+	if v.Position == nil {
+		return true
+	}
+	if s.gtid && t.sets[idx] != nil {
+		return set != nil && !t.sets[idx].Contain(set)
+	}
+	return pos.Compare(mysql.Position{Name: v.Position.BinlogFile, Pos: v.Position.BinlogPosition}) > 0
+}
+
+func (t *historyTable) append(v *pipeline.SchemaVersion, flavor string) {
+	t.tables = append(t.tables, versionTable(t.history.Database, t.history.Table, v))
+	var set mysql.GTIDSet
+	if v.Position != nil && v.Position.GTIDSet != "" {
+		set, _ = mysql.ParseGTIDSet(flavor, v.Position.GTIDSet)
+	}
+	t.sets = append(t.sets, set)
+}
+
+// schemaVersion returns version of table schema
+func schemaVersion(table *schema.Table, position *pipeline.Position) *pipeline.SchemaVersion {
+	v := &pipeline.SchemaVersion{
+		Position:   position,
+		Columns:    make([]*pipeline.SchemaColumn, len(table.Columns)),
+		PKColumns:  append([]int{}, table.PKColumns...),
+		CreateTime: time.Now(),
+	}
+	for i, c := range table.Columns {
+		column := &pipeline.SchemaColumn{Name: c.Name, Type: c.RawType, Collation: c.Collation}
+		if c.IsAuto {
+			column.Extra = "auto_increment"
+		} else if c.IsVirtual {
+			column.Extra = "VIRTUAL GENERATED"
+		}
+		v.Columns[i] = column
+	}
+	return v
+}
+
+// versionTable builds table of schema version, the same as canal builds table from columns of mysql
+func versionTable(database string, name string, v *pipeline.SchemaVersion) *schema.Table {
+	table := &schema.Table{
+		Schema:    database,
+		Name:      name,
+		Columns:   []schema.TableColumn{},
+		Indexes:   []*schema.Index{},
+		PKColumns: append([]int{}, v.PKColumns...),
+	}
+	for _, c := range v.Columns {
+		table.AddColumn(c.Name, c.Type, c.Collation, c.Extra)
+	}
+	return table
+}
+
+// sameSchema returns true if tables have the same columns and primary key
+func sameSchema(a *schema.Table, b *schema.Table) bool {
+	if len(a.Columns) != len(b.Columns) || len(a.PKColumns) != len(b.PKColumns) {
+		return false
+	}
+	for i := range a.Columns {
+		if a.Columns[i].Name != b.Columns[i].Name || a.Columns[i].RawType != b.Columns[i].RawType {
+			return false
+		}
+	}
+	for i := range a.PKColumns {
+		if a.PKColumns[i] != b.PKColumns[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func historyTestTable(columns ...string) *schema.Table {
+	table := &schema.Table{Schema: "database1", Name: "table1", PKColumns: []int{0}}
+	table.AddColumn("id", "int(10) unsigned", "", "auto_increment")
+	for _, v := range columns {
+		table.AddColumn(v, "varchar(64)", "utf8mb4_general_ci", "")
+	}
+	return table
+}
+
+func newTestSchemaHistory(gtid bool) (s *schemaHistory, saved map[string]*pipeline.SchemaHistory) {
+	saved = map[string]*pipeline.SchemaHistory{}
+	s = newSchemaHistory(&pipeline.Pipeline{Name: "go_test_pipeline", Mysql: &pipeline.Mysql{}})
+	s.gtid = gtid
+	s.load = func(database string, table string) (*pipeline.SchemaHistory, error) {
+		return saved[database+"."+table], nil
+	}
+	s.save = func(h *pipeline.SchemaHistory) error {
+		saved[h.Database+"."+h.Table] = h
+		return nil
+	}
+	return
+}
+
+func TestSchemaHistory(t *testing.T) {
+	s, saved := newTestSchemaHistory(false)
+	before := historyTestTable("name")
+	after := historyTestTable("nick", "name")
+	getTable := func(string, string) (*schema.Table, error) { return after, nil }
+
+	// first sight records the current schema
+	if s.table(before, mysql.Position{Name: "mysql-bin.000001", Pos: 100}, nil) != before {
+		t.Fail()
+	}
+	if len(saved["database1.table1"].Versions) != 1 || saved["database1.table1"].Versions[0].Position.BinlogPosition != 100 {
+		t.Fatal(saved)
+	}
+	s.changed("database1", "table1")
+	s.synced(getTable, mysql.Position{Name: "mysql-bin.000001", Pos: 1000}, nil)
+	if len(saved["database1.table1"].Versions) != 2 || s.pending != nil {
+		t.Fatal(saved)
+	}
+
+	// rows replayed before the ddl are mapped to the old schema
+	table := s.table(after, mysql.Position{Name: "mysql-bin.000001", Pos: 500}, nil)
+	if len(table.Columns) != 2 || table.Columns[1].Name != "name" || !table.Columns[0].IsUnsigned || !table.Columns[0].IsAuto {
+		t.Fatal(table)
+	}
+	if s.table(after, mysql.Position{Name: "mysql-bin.000002", Pos: 4}, nil) != after {
+		t.Fail()
+	}
+	// schema before the first sight is unknown, the current schema is used
+	if s.table(after, mysql.Position{Name: "mysql-bin.000001", Pos: 50}, nil) != after {
+		t.Fail()
+	}
+
+	// replayed ddl keeps the history
+	s.changed("database1", "table1")
+	s.synced(func(string, string) (*schema.Table, error) { return historyTestTable("other"), nil }, mysql.Position{Name: "mysql-bin.000001", Pos: 1000}, nil)
+	if len(saved["database1.table1"].Versions) != 2 {
+		t.Fail()
+	}
+
+	// history is loaded by a new pipeline run
+	s2, _ := newTestSchemaHistory(false)
+	s2.load = s.load
+	table = s2.table(after, mysql.Position{Name: "mysql-bin.000001", Pos: 500}, nil)
+	if len(table.Columns) != 2 || table.Columns[1].Name != "name" {
+		t.Fail()
+	}
+}
+
+func TestSchemaHistoryGTID(t *testing.T) {
+	s, saved := newTestSchemaHistory(true)
+	before := historyTestTable("name")
+	after := historyTestTable("nick", "name")
+	s.table(before, mysql.Position{}, nil)
+	ddlSet, _ := mysql.ParseGTIDSet(mysql.MySQLFlavor, "045c649a-408d-11ec-ae21-0242ac110006:1-64")
+	s.changed("database1", "table1")
+	s.synced(func(string, string) (*schema.Table, error) { return after, nil }, mysql.Position{Name: "other-bin.000001", Pos: 4}, ddlSet)
+	if len(saved["database1.table1"].Versions) != 2 || saved["database1.table1"].Versions[1].Position.GTIDSet != ddlSet.String() {
+		t.Fatal(saved)
+	}
+	old, _ := mysql.ParseGTIDSet(mysql.MySQLFlavor, "045c649a-408d-11ec-ae21-0242ac110006:1-63")
+	if table := s.table(after, mysql.Position{Name: "mysql-bin.000009", Pos: 4}, old); len(table.Columns) != 2 {
+		t.Fail()
+	}
+	newer, _ := mysql.ParseGTIDSet(mysql.MySQLFlavor, "045c649a-408d-11ec-ae21-0242ac110006:1-65")
+	if s.table(after, mysql.Position{}, newer) != after {
+		t.Fail()
+	}
+}
@@ -0,0 +1,128 @@
+package input
+
+import (
+	"reflect"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// rowImage marks columns absent in row image of mysql and changed columns of updates
+type rowImage struct {
+	// changedOnly update messages keep changed columns and primary key only
+	changedOnly bool
+}
+
+func newRowImage(m *pipeline.Mysql) *rowImage {
+	return &rowImage{changedOnly: m.ChangedColumnsOnly}
+}
+
+// apply is called with messages of one rows event. skipped are indexes of columns absent in each row,
+// nil if not known, then all columns are taken as present
+func (r *rowImage) apply(table *schema.Table, msgs []*message2.Message, skipped [][]int) {
+	for i, msg := range msgs {
+		switch data := msg.Content.Data.(type) {
+		case message2.Insert:
+			{
+				data.New, data.Missing = r.absent(table, data.New, skipped, i)
+				msg.Content.Data = data
+			}
+		case message2.Delete:
+			{
+				data.Old, data.Missing = r.absent(table, data.Old, skipped, i)
+				msg.Content.Data = data
+			}
+		case message2.Update:
+			{
+				msg.Content.Data = r.update(table, data, skipped, i)
+			}
+		}
+	}
+}
+
+func (r *rowImage) update(table *schema.Table, data message2.Update, skipped [][]int, i int) message2.Update {
+	data.Old, data.OldMissing = r.absent(table, data.Old, skipped, 2*i)
+	data.New, data.NewMissing = r.absent(table, data.New, skipped, 2*i+1)
+	// primary key absent in after image is not changed
+	for _, idx := range table.PKColumns {
+		name := table.Columns[idx].Name
+		if _, ok := data.New[name]; !ok {
+			if val, ok := data.Old[name]; ok {
+				data.New[name] = val
+				data.NewMissing = withoutName(data.NewMissing, name)
+			}
+		}
+	}
+	data.Changed = []string{}
+	for _, column := range table.Columns {
+		val, ok := data.New[column.Name]
+		if !ok {
+			continue
+		}
+		if old, ok := data.Old[column.Name]; !ok || !reflect.DeepEqual(old, val) {
+			data.Changed = append(data.Changed, column.Name)
+		}
+	}
+	if r.changedOnly {
+		keep := map[string]bool{}
+		for _, name := range data.Changed {
+			keep[name] = true
+		}
+		for _, idx := range table.PKColumns {
+			keep[table.Columns[idx].Name] = true
+		}
+		data.Old = only(data.Old, keep)
+		data.New = only(data.New, keep)
+	}
+	return data
+}
+
+// absent returns values without columns absent in the image of row, and names of the absent columns
+func (r *rowImage) absent(table *schema.Table, values map[string]interface{}, skipped [][]int, row int) (res map[string]interface{}, names []string) {
+	res = values
+	if skipped != nil {
+		if row >= len(skipped) {
+			return
+		}
+		for _, idx := range skipped[row] {
+			if idx < len(table.Columns) {
+				names = append(names, table.Columns[idx].Name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	// builtin delete is shadowed in this package, values are copied without absent columns
+	keep := make(map[string]bool, len(values))
+	for k := range values {
+		keep[k] = true
+	}
+	for _, name := range names {
+		keep[name] = false
+	}
+	res = only(values, keep)
+	return
+}
+
+// withoutName returns names without name
+func withoutName(names []string, name string) (res []string) {
+	for _, v := range names {
+		if v != name {
+			res = append(res, v)
+		}
+	}
+	return
+}
+
+// only returns values of keys in keep
+func only(values map[string]interface{}, keep map[string]bool) (res map[string]interface{}) {
+	res = make(map[string]interface{}, len(keep))
+	for k, v := range values {
+		if keep[k] {
+			res[k] = v
+		}
+	}
+	return
+}
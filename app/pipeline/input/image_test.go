@@ -0,0 +1,81 @@
+package input
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func imageRowsEvent(rows ...[]interface{}) *canal.RowsEvent {
+	return &canal.RowsEvent{
+		Header: &replication.EventHeader{},
+		Action: canal.UpdateAction,
+		Table: &schema.Table{
+			Schema: "database1",
+			Name:   "table1",
+			Columns: []schema.TableColumn{
+				{Name: "id", Type: schema.TYPE_NUMBER},
+				{Name: "name", Type: schema.TYPE_STRING},
+				{Name: "age", Type: schema.TYPE_NUMBER},
+				{Name: "remark", Type: schema.TYPE_STRING},
+			},
+			PKColumns: []int{0},
+		},
+		Rows: rows,
+	}
+}
+
+func TestRowImage(t *testing.T) {
+	// full image
+	e := imageRowsEvent([]interface{}{1, "roy", 18, nil}, []interface{}{1, "roy", 19, nil})
+	msgs := rowsMessage(e, nil)
+	newRowImage(&pipeline.Mysql{}).apply(e.Table, msgs, nil)
+	update := msgs[0].Content.Data.(message2.Update)
+	if !reflect.DeepEqual(update.Changed, []string{"age"}) || update.NewMissing != nil || len(update.New) != 4 {
+		t.Fatal(update)
+	}
+
+	// changed columns only
+	msgs = rowsMessage(e, nil)
+	newRowImage(&pipeline.Mysql{ChangedColumnsOnly: true}).apply(e.Table, msgs, nil)
+	update = msgs[0].Content.Data.(message2.Update)
+	if len(update.New) != 2 || update.New["id"] != 1 || update.New["age"] != 19 || len(update.Old) != 2 {
+		t.Fatal(update)
+	}
+
+	// minimal image with skipped columns, before image has primary key, after image has changed columns
+	e = imageRowsEvent([]interface{}{1, nil, nil, nil}, []interface{}{nil, nil, 19, nil})
+	msgs = rowsMessage(e, nil)
+	newRowImage(&pipeline.Mysql{}).apply(e.Table, msgs, [][]int{{1, 2, 3}, {0, 1}})
+	update = msgs[0].Content.Data.(message2.Update)
+	if !reflect.DeepEqual(update.OldMissing, []string{"name", "age", "remark"}) || !reflect.DeepEqual(update.NewMissing, []string{"name"}) {
+		t.Fatal(update)
+	}
+	// remark set to null is present, id is copied from before image
+	if !reflect.DeepEqual(update.Changed, []string{"age", "remark"}) || update.New["id"] != 1 || len(update.New) != 3 {
+		t.Fatal(update)
+	}
+
+	// without skipped columns, null values are kept as they may be written
+	e.Action = canal.DeleteAction
+	e.Rows = [][]interface{}{{1, nil, nil, nil}}
+	msgs = rowsMessage(e, nil)
+	newRowImage(&pipeline.Mysql{}).apply(e.Table, msgs, nil)
+	del := msgs[0].Content.Data.(message2.Delete)
+	if del.Missing != nil || len(del.Old) != 4 {
+		t.Fatal(del)
+	}
+	e.Action = canal.UpdateAction
+	e.Rows = [][]interface{}{{1, "roy", 18, "vip"}, {1, "roy", 18, nil}}
+	msgs = rowsMessage(e, nil)
+	newRowImage(&pipeline.Mysql{}).apply(e.Table, msgs, nil)
+	update = msgs[0].Content.Data.(message2.Update)
+	if update.NewMissing != nil || !reflect.DeepEqual(update.Changed, []string{"remark"}) || len(update.New) != 4 {
+		t.Fatal(update)
+	}
+}
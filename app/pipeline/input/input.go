@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
+	"sync"
+	"time"
 
 	"github.com/go-mysql-org/go-mysql/canal"
 	"github.com/go-mysql-org/go-mysql/mysql"
 	message2 "github.com/jin06/binlogo/app/pipeline/message"
 	"github.com/jin06/binlogo/pkg/event"
+	"github.com/jin06/binlogo/pkg/mysql/replication"
 	"github.com/jin06/binlogo/pkg/store/dao/dao_pipe"
 	event2 "github.com/jin06/binlogo/pkg/store/model/event"
 	"github.com/jin06/binlogo/pkg/store/model/node"
@@ -26,8 +28,25 @@ type Input struct {
 	ctx          context.Context
 	pipe         *pipeline.Pipeline
 	node         *node.Node
+	schema       *tableSchema
+	conv         *converter
+	end          *endCondition
+	// endpoint index of the mysql endpoint in candidates
+	endpoint int
+	// history schema versions of tables, kept across failover
+	history *schemaHistory
+	// reader reads binlog stream instead of canal when events canal drops are needed, nil if canal reads binlog
+	reader *eventReader
+	// canalMutex guards canal and reader replaced by failover against readers out of input
+	canalMutex sync.RWMutex
 }
 
+// failoverReconnectAttempts reconnect attempts before failing over to the next endpoint
+const failoverReconnectAttempts = 10
+
+// heartbeatPeriod period of heartbeat events sent by idle mysql
+const heartbeatPeriod = 10 * time.Second
+
 // Run Input start working
 func (r *Input) Run(ctx context.Context) (err error) {
 	myCtx, cancel := context.WithCancel(ctx)
@@ -47,19 +66,34 @@ func (r *Input) Run(ctx context.Context) (err error) {
 			if err != nil {
 				return
 			}
+			if r.pipe.Mysql.Source == pipeline.SOURCE_FILE {
+				if err = r.runFile(); err != nil {
+					return
+				}
+				// wait for the pipeline to be stopped after it is finished
+				<-ctx.Done()
+				return
+			}
 			err = r.runCanal()
 			if err != nil {
 				return
 			}
 		}
-		select {
-		case <-ctx.Done():
-			{
-				return
-			}
-		case <-r.canal.Ctx().Done():
-			{
-				break
+		for {
+			select {
+			case <-ctx.Done():
+				{
+					return
+				}
+			case <-r.stopped():
+				{
+					if !r.pipe.Mysql.Failover() {
+						return
+					}
+					if err = r.failover(); err != nil {
+						return
+					}
+				}
 			}
 		}
 	}()
@@ -88,20 +122,208 @@ func (r *Input) prepareCanal() (err error) {
 		return
 	}
 	r.pipe = pipe
+	r.conv, err = newConverter(pipe.Mysql.TimeZone)
+	if err != nil {
+		return
+	}
+	r.end, err = newEndCondition(pipe.End, pipe.Mysql.Flavor.YaString())
+	if err != nil {
+		return
+	}
+	if pipe.Mysql.Source == pipeline.SOURCE_FILE {
+		// file source reads binlog without mysql server
+		if pipe.Mysql.HeadSchema {
+			r.schema = newTableSchema(nil)
+		}
+		return
+	}
+	r.history = newSchemaHistory(pipe)
+	err = r.connect(0)
+	return
+}
+
+// connect creates canal of the first healthy endpoint, starting from the endpoint at index start.
+// only the primary endpoint is used in position mode
+func (r *Input) connect(start int) (err error) {
+	candidates := replication.Candidates(r.pipe.Mysql)
+	for i := 0; i < len(candidates); i++ {
+		idx := (start + i) % len(candidates)
+		var c *canal.Canal
+		c, err = r.newCanal(candidates[idx])
+		if err == nil {
+			if err = r.healthy(c); err != nil {
+				c.Close()
+			}
+		}
+		if err != nil {
+			logrus.Errorln("Connect mysql error: ", candidates[idx].Addr(), err)
+			continue
+		}
+		r.canalMutex.Lock()
+		r.canal, r.endpoint = c, idx
+		r.canalMutex.Unlock()
+		return
+	}
+	return
+}
+
+func (r *Input) newCanal(endpoint *pipeline.Endpoint) (c *canal.Canal, err error) {
+	cfg, err := r.canalConfig(endpoint)
+	if err != nil {
+		return
+	}
+	return canal.NewCanal(cfg)
+}
+
+func (r *Input) canalConfig(endpoint *pipeline.Endpoint) (cfg *canal.Config, err error) {
+	password, err := replication.Password(r.pipe.Mysql)
+	if err != nil {
+		return
+	}
+	tlsConfig, err := replication.TLSConfig(r.pipe.Mysql, endpoint.Addr())
+	if err != nil {
+		return
+	}
+	cfg = &canal.Config{
+		Addr:      endpoint.Addr(),
+		User:      r.pipe.Mysql.User,
+		Password:  password,
+		ServerID:  r.pipe.Mysql.ServerId,
+		Flavor:    r.pipe.Mysql.Flavor.YaString(),
+		TLSConfig: tlsConfig,
+		// decimal is kept exact and timestamp decoded in UTC for converter
+		UseDecimal:              true,
+		TimestampStringLocation: time.UTC,
+		// idle mysql sends heartbeat, so a broken connection is found instead of waiting for events
+		HeartbeatPeriod: heartbeatPeriod,
+	}
+	if r.pipe.Mysql.Failover() {
+		// give up a broken connection so that the pipeline can fail over
+		cfg.MaxReconnectAttempts = failoverReconnectAttempts
+	}
+	return
+}
+
+// healthy checks the endpoint has executed the checkpointed gtid set, so it can be resumed from
+func (r *Input) healthy(c *canal.Canal) (err error) {
+	if r.pipe.Mysql.Mode != pipeline.MODE_GTID {
+		return
+	}
+	record, err := dao_pipe.GetRecord(r.Options.PipeName)
+	if err != nil || record == nil || record.Pre == nil || record.Pre.GTIDSet == "" {
+		return
+	}
+	saved, err := mysql.ParseGTIDSet(r.pipe.Mysql.Flavor.YaString(), record.Pre.GTIDSet)
+	if err != nil {
+		return
+	}
+	executed, err := c.GetMasterGTIDSet()
+	if err != nil {
+		return
+	}
+	if !executed.Contain(saved) {
+		err = fmt.Errorf("checkpointed gtid set %s is not executed by the server", record.Pre.GTIDSet)
+	}
+	return
+}
 
-	addr := fmt.Sprintf("%s:%s", pipe.Mysql.Address, strconv.Itoa(int(pipe.Mysql.Port)))
-	cfg := &canal.Config{
-		Addr:     addr,
-		User:     pipe.Mysql.User,
-		Password: pipe.Mysql.Password,
-		ServerID: pipe.Mysql.ServerId,
-		Flavor:   pipe.Mysql.Flavor.YaString(),
+// failover reconnects to the next healthy endpoint after replication stopped, and resumes from the checkpointed gtid set.
+// it is refused in position mode since binlog file offsets are specific to a server
+func (r *Input) failover() (err error) {
+	if r.pipe.Mysql.Mode != pipeline.MODE_GTID {
+		err = errors.New("mysql failover is refused in position mode, binlog file offsets are specific to a server")
+		return
+	}
+	from := r.addr()
+	reader := r.reader
+	r.canal.Close()
+	r.setCanal(nil)
+	if reader != nil {
+		// wait for the reader to stop, so events of both endpoints are not handled at the same time
+		<-reader.done
+	}
+	if err = r.connect(r.endpoint + 1); err != nil {
+		return
+	}
+	if err = r.runCanal(); err != nil {
+		return
 	}
-	r.canal, err = canal.NewCanal(cfg)
+	event.Event(event2.NewWarnPipeline(r.Options.PipeName, fmt.Sprintf("Mysql failover from %s to %s", from, r.addr())))
 	return
 }
 
+func (r *Input) setCanal(c *canal.Canal) {
+	r.canalMutex.Lock()
+	defer r.canalMutex.Unlock()
+	r.canal = c
+	r.reader = nil
+}
+
+// stopped returns channel closed after binlog is not read any more
+func (r *Input) stopped() <-chan struct{} {
+	if r.reader != nil {
+		return r.reader.done
+	}
+	return r.canal.Ctx().Done()
+}
+
+// MasterStatus returns current binlog position of mysql the pipeline replicates from
+func (r *Input) MasterStatus() (status *replication.MasterStatus, err error) {
+	r.canalMutex.RLock()
+	defer r.canalMutex.RUnlock()
+	if r.canal == nil {
+		err = errors.New("not connected to mysql")
+		return
+	}
+	return replication.GetMasterStatus(r.canal, r.pipe.Mysql.Flavor.YaString())
+}
+
+// Synced returns position and gtid set of binlog read by input
+func (r *Input) Synced() (pos mysql.Position, set mysql.GTIDSet) {
+	r.canalMutex.RLock()
+	defer r.canalMutex.RUnlock()
+	if r.canal == nil {
+		return
+	}
+	if r.reader != nil {
+		return r.reader.position()
+	}
+	return r.canal.SyncedPosition(), r.canal.SyncedGTIDSet()
+}
+
+// Addr returns address of mysql the pipeline replicates from, empty if not connected
+func (r *Input) Addr() string {
+	r.canalMutex.RLock()
+	defer r.canalMutex.RUnlock()
+	if r.canal == nil {
+		return ""
+	}
+	return r.addr()
+}
+
+// addr returns address of the endpoint being replicated from
+func (r *Input) addr() string {
+	return r.pipe.Mysql.Candidates()[r.endpoint].Addr()
+}
+
 func (r *Input) runCanal() (err error) {
+	if r.pipe.Mysql.HeadSchema {
+		r.schema = newTableSchema(r.canal)
+	}
+	snap, conn, err := r.prepareSnapshot()
+	if err != nil {
+		return
+	}
+	if snap != nil {
+		err = r.runSnapshot(snap, conn)
+		if err == errSnapshotCanceled {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
 	record, err := dao_pipe.GetRecord(r.Options.PipeName)
 	if err != nil {
 		return
@@ -135,10 +357,11 @@ func (r *Input) runCanal() (err error) {
 				return
 			}
 		}
-		r.canal.SetEventHandler(&canalHandler{
-			ch:   r.OutChan,
-			pipe: r.pipe,
-		})
+		handler := r.newHandler()
+		if r.streamed() {
+			return r.runStream(handler, mysql.Position{}, canGTID)
+		}
+		r.canal.SetEventHandler(handler)
 		//go r.canal.StartFromGTID(canGTID)
 		go func() {
 			startErr := r.canal.StartFromGTID(canGTID)
@@ -180,10 +403,11 @@ func (r *Input) runCanal() (err error) {
 			}
 		}
 		//logrus.Debugln(pos)
-		r.canal.SetEventHandler(&canalHandler{
-			ch:   r.OutChan,
-			pipe: r.pipe,
-		})
+		handler := r.newHandler()
+		if r.streamed() {
+			return r.runStream(handler, *canPos, nil)
+		}
+		r.canal.SetEventHandler(handler)
 		//go r.canal.RunFrom(canPos)
 		go func() {
 			startErr := r.canal.RunFrom(*canPos)
@@ -202,6 +426,23 @@ func (r *Input) runCanal() (err error) {
 	return
 }
 
+// newHandler returns handler of events read from mysql
+func (r *Input) newHandler() *canalHandler {
+	return &canalHandler{
+		ch:          r.OutChan,
+		pipe:        r.pipe,
+		canal:       r.canal,
+		schema:      r.schema,
+		conv:        r.conv,
+		transaction: r.pipe.Mysql.Transaction,
+		spillRows:   r.pipe.Mysql.SpillRows,
+		rowsQuery:   rowsQueryLength(r.pipe.Mysql),
+		image:       newRowImage(r.pipe.Mysql),
+		history:     r.history,
+		end:         r.end,
+	}
+}
+
 // Context returns Input's context
 func (r *Input) Context() context.Context {
 	return r.ctx
@@ -56,7 +56,8 @@ func TestRun(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	ctx, _ := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	err = i.Run(ctx)
 	if err != nil {
 		t.Fail()
@@ -110,7 +111,8 @@ func TestRunCommon(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	ctx, _ := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	err = i.Run(ctx)
 	if err != nil {
 		t.Fail()
@@ -1,10 +1,11 @@
 package input
 
 import (
-	"strconv"
-
 	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
 	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
 )
 
 func emptyMessage() (msgs []*message2.Message) {
@@ -17,28 +18,28 @@ func emptyMessage() (msgs []*message2.Message) {
 	return
 }
 
-func rowsMessage(e *canal.RowsEvent) (msgs []*message2.Message) {
+func rowsMessage(e *canal.RowsEvent, conv *converter) (msgs []*message2.Message) {
 	// msg = message2.New()
 	// msgs = []*message2.Message{}
 	switch e.Action {
 	case canal.InsertAction:
 		{
-			return insert(e)
+			return insert(e, conv)
 		}
 	case canal.UpdateAction:
 		{
-			return update(e)
+			return update(e, conv)
 		}
 	case canal.DeleteAction:
 		{
-			return delete(e)
+			return delete(e, conv)
 		}
 	default:
 		return emptyMessage()
 	}
 }
 
-func insert(e *canal.RowsEvent) (msgs []*message2.Message) {
+func insert(e *canal.RowsEvent, conv *converter) (msgs []*message2.Message) {
 	lengthRows := len(e.Rows)
 	totalRows := lengthRows
 	msgs = make([]*message2.Message, lengthRows)
@@ -46,23 +47,13 @@ func insert(e *canal.RowsEvent) (msgs []*message2.Message) {
 		msg := toMessage(e)
 		//msg.Content.Head.Position.TotalRows = totalRows
 		//msg.Content.Head.Position.ConsumeRows = i + 1
-		newer := map[string]interface{}{}
-		for key, val := range e.Rows[i] {
-			var columnName string
-			if len(e.Table.Columns) > key {
-				columnName = e.Table.Columns[key].Name
-			} else {
-				columnName = strconv.Itoa(key)
-			}
-			newer[columnName] = val
-		}
-		msg.Content.Data = message2.Insert{New: newer}
+		msg.Content.Data = message2.Insert{New: conv.row(e.Table, e.Rows[i])}
 		msgs[i] = msg
 	}
 	return
 }
 
-func update(e *canal.RowsEvent) (msgs []*message2.Message) {
+func update(e *canal.RowsEvent, conv *converter) (msgs []*message2.Message) {
 	lengthRows := len(e.Rows)
 	totalRows := lengthRows / 2
 	msgs = make([]*message2.Message, totalRows)
@@ -70,33 +61,15 @@ func update(e *canal.RowsEvent) (msgs []*message2.Message) {
 		msg := toMessage(e)
 		//msg.Content.Head.Position.TotalRows = totalRows
 		//msg.Content.Head.Position.ConsumeRows = i + 1
-		old := map[string]interface{}{}
-		newer := map[string]interface{}{}
-		for key, val := range e.Rows[2*i] {
-			var columnName string
-			if len(e.Table.Columns) > key {
-				columnName = e.Table.Columns[key].Name
-			} else {
-				columnName = strconv.Itoa(key)
-			}
-			old[columnName] = val
-		}
-		for key, val := range e.Rows[2*i+1] {
-			var columnName string
-			if len(e.Table.Columns) > key {
-				columnName = e.Table.Columns[key].Name
-			} else {
-				columnName = strconv.Itoa(key)
-			}
-			newer[columnName] = val
-		}
+		old := conv.row(e.Table, e.Rows[2*i])
+		newer := conv.row(e.Table, e.Rows[2*i+1])
 		msg.Content.Data = message2.Update{Old: old, New: newer}
 		msgs[i] = msg
 	}
 	return
 }
 
-func delete(e *canal.RowsEvent) (msgs []*message2.Message) {
+func delete(e *canal.RowsEvent, conv *converter) (msgs []*message2.Message) {
 	lengthRows := len(e.Rows)
 	totalRows := lengthRows
 	msgs = make([]*message2.Message, totalRows)
@@ -104,17 +77,7 @@ func delete(e *canal.RowsEvent) (msgs []*message2.Message) {
 		msg := toMessage(e)
 		//msg.Content.Head.Position.TotalRows = totalRows
 		//msg.Content.Head.Position.ConsumeRows = i + 1
-		old := map[string]interface{}{}
-		for key, val := range e.Rows[i] {
-			var columnName string
-			if len(e.Table.Columns) > key {
-				columnName = e.Table.Columns[key].Name
-			} else {
-				columnName = strconv.Itoa(key)
-			}
-			old[columnName] = val
-		}
-		msg.Content.Data = message2.Delete{Old: old}
+		msg.Content.Data = message2.Delete{Old: conv.row(e.Table, e.Rows[i])}
 		msgs[i] = msg
 	}
 	return
@@ -137,6 +100,134 @@ func toMessage(e *canal.RowsEvent) (msg *message2.Message) {
 	return
 }
 
+// ddlMessage parses the query of ddl event, returns one message for each affected table
+func ddlMessage(p *parser.Parser, e *replication.QueryEvent, timestamp uint32) (msgs []*message2.Message, err error) {
+	stmts, _, err := p.Parse(string(e.Query), "", "")
+	if err != nil {
+		// ddl is still sent with raw query, table of it is not known
+		msg := message2.Get()
+		msg.Content.Head.Type = message2.TYPE_DDL.String()
+		msg.Content.Head.Database = string(e.Schema)
+		msg.Content.Head.Time = timestamp
+		msg.Content.Data = message2.DDL{Query: string(e.Query)}
+		msgs = []*message2.Message{msg}
+		return
+	}
+	msgs = []*message2.Message{}
+	for _, stmt := range stmts {
+		query := stmt.Text()
+		if query == "" {
+			query = string(e.Query)
+		}
+		ddl := message2.DDL{Query: query}
+		switch t := stmt.(type) {
+		case *ast.CreateTableStmt:
+			{
+				msg := ddlToMessage(message2.TYPE_CREATE_TABLE, t.Table, string(e.Schema), timestamp)
+				msg.Content.Data = message2.CreateTable{DDL: ddl}
+				msgs = append(msgs, msg)
+			}
+		case *ast.AlterTableStmt:
+			{
+				msg := ddlToMessage(message2.TYPE_ALTER_TABLE, t.Table, string(e.Schema), timestamp)
+				msg.Content.Data = message2.AlterTable{DDL: ddl}
+				msgs = append(msgs, msg)
+			}
+		case *ast.DropTableStmt:
+			{
+				if t.IsView {
+					continue
+				}
+				for _, table := range t.Tables {
+					msg := ddlToMessage(message2.TYPE_DROP_TABLE, table, string(e.Schema), timestamp)
+					msg.Content.Data = message2.DropTable{DDL: ddl}
+					msgs = append(msgs, msg)
+				}
+			}
+		case *ast.RenameTableStmt:
+			{
+				for _, tt := range t.TableToTables {
+					msg := ddlToMessage(message2.TYPE_RENAME_TABLE, tt.OldTable, string(e.Schema), timestamp)
+					newDatabase := tt.NewTable.Schema.String()
+					if newDatabase == "" {
+						newDatabase = string(e.Schema)
+					}
+					msg.Content.Data = message2.RenameTable{
+						DDL:         ddl,
+						NewDatabase: newDatabase,
+						NewTable:    tt.NewTable.Name.String(),
+					}
+					msgs = append(msgs, msg)
+				}
+			}
+		case *ast.TruncateTableStmt:
+			{
+				msg := ddlToMessage(message2.TYPE_TRUNCATE_TABLE, t.Table, string(e.Schema), timestamp)
+				msg.Content.Data = message2.TruncateTable{DDL: ddl}
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	return
+}
+
+// ddlTables returns database and table of tables changed by ddl event, nothing if it can not be parsed
+func ddlTables(p *parser.Parser, e *replication.QueryEvent) (tables [][2]string) {
+	stmts, _, err := p.Parse(string(e.Query), "", "")
+	if err != nil {
+		return
+	}
+	add := func(table *ast.TableName) {
+		database := table.Schema.String()
+		if database == "" {
+			database = string(e.Schema)
+		}
+		tables = append(tables, [2]string{database, table.Name.String()})
+	}
+	for _, stmt := range stmts {
+		switch t := stmt.(type) {
+		case *ast.CreateTableStmt:
+			{
+				add(t.Table)
+			}
+		case *ast.AlterTableStmt:
+			{
+				add(t.Table)
+			}
+		case *ast.DropTableStmt:
+			{
+				for _, table := range t.Tables {
+					add(table)
+				}
+			}
+		case *ast.RenameTableStmt:
+			{
+				for _, tt := range t.TableToTables {
+					add(tt.OldTable)
+					add(tt.NewTable)
+				}
+			}
+		case *ast.TruncateTableStmt:
+			{
+				add(t.Table)
+			}
+		}
+	}
+	return
+}
+
+func ddlToMessage(t message2.MessageType, table *ast.TableName, schema string, timestamp uint32) (msg *message2.Message) {
+	msg = message2.Get()
+	msg.Content.Head.Type = t.String()
+	msg.Content.Head.Database = table.Schema.String()
+	if msg.Content.Head.Database == "" {
+		msg.Content.Head.Database = schema
+	}
+	msg.Content.Head.Table = table.Name.String()
+	msg.Content.Head.Time = timestamp
+	return
+}
+
 func mapType(s string) (t string) {
 	switch s {
 	case canal.InsertAction:
@@ -145,7 +236,7 @@ func mapType(s string) (t string) {
 		}
 	case canal.UpdateAction:
 		{
-			t = message2.TYPE_INSERT.String()
+			t = message2.TYPE_UPDATE.String()
 		}
 	case canal.DeleteAction:
 		{
@@ -8,6 +8,7 @@ import (
 	"github.com/go-mysql-org/go-mysql/replication"
 	"github.com/go-mysql-org/go-mysql/schema"
 	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/pingcap/parser"
 )
 
 func TestRowsMessage(t *testing.T) {
@@ -35,7 +36,7 @@ func TestRowsMessage(t *testing.T) {
 			},
 		},
 	}
-	msg := rowsMessage(rowsEvent)
+	msg := rowsMessage(rowsEvent, nil)[0]
 	if msg.Content.Head.Type != "insert" {
 		t.Fail()
 	}
@@ -52,13 +53,93 @@ func TestRowsMessage(t *testing.T) {
 	}
 
 	rowsEvent.Action = canal.UpdateAction
-	msg = rowsMessage(rowsEvent)
-	if _, ok := msg.Content.Data.(message2.Update); !ok {
+	rowsEvent.Rows = append(rowsEvent.Rows, []interface{}{10002})
+	msg = rowsMessage(rowsEvent, nil)[0]
+	if _, ok := msg.Content.Data.(message2.Update); !ok || msg.Content.Head.Type != "update" {
 		t.Fail()
 	}
 	rowsEvent.Action = canal.DeleteAction
-	msg = rowsMessage(rowsEvent)
+	msg = rowsMessage(rowsEvent, nil)[0]
 	if _, ok := msg.Content.Data.(message2.Delete); !ok {
 		t.Fail()
 	}
 }
+
+func TestDDLMessage(t *testing.T) {
+	p := parser.New()
+	e := &replication.QueryEvent{
+		Schema: []byte("database1"),
+		Query:  []byte("ALTER TABLE table1 ADD COLUMN name varchar(20) NOT NULL DEFAULT ''"),
+	}
+	msgs, err := ddlMessage(p, e, uint32(time.Now().Unix()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Fatal("alter table should returns one message")
+	}
+	if msgs[0].Content.Head.Type != message2.TYPE_ALTER_TABLE.String() {
+		t.Fail()
+	}
+	if msgs[0].Table() != "database1.table1" {
+		t.Fail()
+	}
+	if val, ok := msgs[0].Content.Data.(message2.AlterTable); !ok || val.Query == "" {
+		t.Fail()
+	}
+
+	e.Query = []byte("RENAME TABLE table1 TO database2.table2, table3 TO table4")
+	msgs, err = ddlMessage(p, e, uint32(time.Now().Unix()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Fatal("rename two tables should returns two messages")
+	}
+	if val, ok := msgs[0].Content.Data.(message2.RenameTable); !ok || val.NewDatabase != "database2" || val.NewTable != "table2" {
+		t.Fail()
+	}
+	if val, ok := msgs[1].Content.Data.(message2.RenameTable); !ok || val.NewDatabase != "database1" {
+		t.Fail()
+	}
+
+	e.Query = []byte("DROP TABLE IF EXISTS database2.table2, table4")
+	msgs, err = ddlMessage(p, e, uint32(time.Now().Unix()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Table() != "database2.table2" || msgs[1].Table() != "database1.table4" {
+		t.Fail()
+	}
+
+	e.Query = []byte("TRUNCATE TABLE table1")
+	msgs, err = ddlMessage(p, e, uint32(time.Now().Unix()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Content.Head.Type != message2.TYPE_TRUNCATE_TABLE.String() {
+		t.Fail()
+	}
+
+	// ddl the parser fails on is sent with raw query
+	e.Query = []byte("ALTER TABLE table1 ADD COLUMN name varchar(20) NOT NULL DEFAULT '' FOO BAR")
+	msgs, err = ddlMessage(p, e, uint32(time.Now().Unix()))
+	if err == nil || len(msgs) != 1 || msgs[0].Content.Head.Type != message2.TYPE_DDL.String() || msgs[0].Content.Head.Database != "database1" {
+		t.Fatal(msgs, err)
+	}
+	if val, ok := msgs[0].Content.Data.(message2.DDL); !ok || val.Query != string(e.Query) {
+		t.Error(msgs[0].Content.Data)
+	}
+}
+
+func TestDDLTables(t *testing.T) {
+	e := &replication.QueryEvent{Schema: []byte("shop"), Query: []byte("rename table item to item_old, log.a to log.b")}
+	tables := ddlTables(parser.New(), e)
+	if len(tables) != 4 || tables[0] != [2]string{"shop", "item"} || tables[1] != [2]string{"shop", "item_old"} || tables[3] != [2]string{"log", "b"} {
+		t.Error(tables)
+	}
+	e.Query = []byte("insert into item values (1)")
+	if tables = ddlTables(parser.New(), e); len(tables) != 0 {
+		t.Error(tables)
+	}
+}
@@ -0,0 +1,221 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/pingcap/parser"
+	"github.com/sirupsen/logrus"
+)
+
+var errFileStopped = errors.New("binlog file reading stopped")
+
+// eventReader reads events of local binlog files or of the binlog stream of mysql,
+// and passes them to canal handler the same way as canal does
+type eventReader struct {
+	handler *canalHandler
+	flavor  string
+	// gset gtid set executed, gtid of the transaction being read
+	gset    mysql.GTIDSet
+	gtid    mysql.GTIDSet
+	stopped bool
+	// canal reads tables of rows from mysql when events are streamed from it, nil for file source
+	canal  *canal.Canal
+	parser *parser.Parser
+	// noGTID gtid set is not tracked, binlog is streamed in position mode
+	noGTID bool
+	// syncedMutex guards position and gtid set synced, they are read out of the reader
+	syncedMutex sync.RWMutex
+	syncedPos   mysql.Position
+	syncedSet   mysql.GTIDSet
+	// done is closed after events are not streamed any more, nil for file source
+	done chan struct{}
+}
+
+func (f *eventReader) onEvent(ctx context.Context, name string, ev *replication.BinlogEvent) (err error) {
+	select {
+	case <-ctx.Done():
+		{
+			f.stopped = true
+			return errFileStopped
+		}
+	default:
+	}
+	if f.handler.finished {
+		f.stopped = true
+		return errFileStopped
+	}
+	f.handler.timestamp = ev.Header.Timestamp
+	pos := mysql.Position{Name: name, Pos: ev.Header.LogPos}
+	switch e := ev.Event.(type) {
+	case *replication.PreviousGTIDsEvent:
+		{
+			if f.gset == nil && !f.noGTID {
+				f.gset, err = mysql.ParseGTIDSet(f.flavor, e.GTIDSets)
+			}
+			return
+		}
+	case *replication.MariadbGTIDListEvent:
+		{
+			if f.gset == nil && !f.noGTID {
+				list := make([]string, len(e.GTIDs))
+				for i, v := range e.GTIDs {
+					list[i] = v.String()
+				}
+				f.gset, err = mysql.ParseGTIDSet(f.flavor, strings.Join(list, ","))
+			}
+			return
+		}
+	case *replication.GTIDEvent:
+		{
+			if e.GNO == 0 {
+				return
+			}
+			sid := e.SID
+			f.gtid, err = mysql.ParseMysqlGTIDSet(fmt.Sprintf("%x-%x-%x-%x-%x:%d", sid[0:4], sid[4:6], sid[6:8], sid[8:10], sid[10:16], e.GNO))
+			if err != nil {
+				return
+			}
+			return f.handler.OnGTID(f.gtid)
+		}
+	case *replication.MariadbGTIDEvent:
+		{
+			f.gtid, err = mysql.ParseMariadbGTIDSet(e.GTID.String())
+			if err != nil {
+				return
+			}
+			return f.handler.OnGTID(f.gtid)
+		}
+	case *replication.RowsEvent:
+		{
+			action := fileAction(ev.Header.EventType)
+			if action == "" {
+				return
+			}
+			var table *schema.Table
+			if table, err = f.table(e.Table); err != nil || table == nil {
+				return
+			}
+			unsignedRows(table, e.Rows)
+			return f.handler.onRows(&canal.RowsEvent{Table: table, Action: action, Rows: e.Rows, Header: ev.Header}, e.SkippedColumns)
+		}
+	case *replication.RowsQueryEvent:
+		{
+			return f.handler.OnRowsQueryEvent(e)
+		}
+	case *replication.MariadbAnnotateRowsEvent:
+		{
+			return f.handler.OnRowsQueryEvent(&replication.RowsQueryEvent{Query: e.Query})
+		}
+	case *replication.XIDEvent:
+		{
+			if err = f.handler.OnXID(pos); err != nil {
+				return
+			}
+			if err = f.commit(); err != nil {
+				return
+			}
+			return f.synced(pos, false)
+		}
+	case *replication.QueryEvent:
+		{
+			query := strings.ToUpper(strings.TrimSpace(string(e.Query)))
+			if query == "BEGIN" {
+				return
+			}
+			if query == "COMMIT" {
+				// transaction of non-transactional engine ends with COMMIT instead of XID
+				err = f.handler.OnXID(pos)
+			} else {
+				// statement out of a transaction such as ddl is a transaction itself
+				if err = f.tableChanged(e); err != nil {
+					return
+				}
+				err = f.handler.OnDDL(pos, e)
+			}
+			if err != nil {
+				return
+			}
+			if err = f.commit(); err != nil {
+				return
+			}
+			return f.synced(pos, true)
+		}
+	case *replication.RotateEvent:
+		{
+			if err = f.handler.OnRotate(e); err != nil {
+				return
+			}
+			next := mysql.Position{Name: string(e.NextLogName), Pos: uint32(e.Position)}
+			return f.synced(next, true)
+		}
+	}
+	return
+}
+
+// table returns table of rows, it is read from mysql when events are streamed, otherwise built from table map event.
+// nil is returned if rows of the table are skipped the same as canal does
+func (f *eventReader) table(e *replication.TableMapEvent) (table *schema.Table, err error) {
+	if f.canal == nil {
+		return fileTable(e), nil
+	}
+	table, err = f.canal.GetTable(string(e.Schema), string(e.Table))
+	if err == canal.ErrExcludedTable || err == schema.ErrTableNotExist || err == schema.ErrMissingTableMeta {
+		logrus.Warnf("Skip rows of table %s.%s: %v", e.Schema, e.Table, err)
+		return nil, nil
+	}
+	return
+}
+
+// tableChanged drops cached tables changed by ddl, the same as canal does
+func (f *eventReader) tableChanged(e *replication.QueryEvent) (err error) {
+	if f.parser == nil {
+		f.parser = parser.New()
+	}
+	for _, v := range ddlTables(f.parser, e) {
+		if f.canal != nil {
+			f.canal.ClearTableCache([]byte(v[0]), []byte(v[1]))
+		}
+		if err = f.handler.OnTableChanged(v[0], v[1]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// synced records position and gtid set synced, then passes them to handler
+func (f *eventReader) synced(pos mysql.Position, force bool) error {
+	f.syncedMutex.Lock()
+	f.syncedPos = pos
+	f.syncedSet = nil
+	if f.gset != nil {
+		f.syncedSet = f.gset.Clone()
+	}
+	f.syncedMutex.Unlock()
+	return f.handler.OnPosSynced(pos, f.gset, force)
+}
+
+// position returns position and gtid set synced
+func (f *eventReader) position() (mysql.Position, mysql.GTIDSet) {
+	f.syncedMutex.RLock()
+	defer f.syncedMutex.RUnlock()
+	return f.syncedPos, f.syncedSet
+}
+
+// commit adds gtid of the transaction to the executed gtid set
+func (f *eventReader) commit() (err error) {
+	defer func() {
+		f.gtid = nil
+	}()
+	if f.gset == nil || f.gtid == nil {
+		return
+	}
+	return f.gset.Update(f.gtid.String())
+}
@@ -0,0 +1,123 @@
+package input
+
+import (
+	"fmt"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/schema"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/sirupsen/logrus"
+)
+
+// tableSchema caches column schema of tables for message head
+type tableSchema struct {
+	canal  *canal.Canal
+	tables map[string][]*message2.Column
+}
+
+func newTableSchema(c *canal.Canal) *tableSchema {
+	return &tableSchema{
+		canal:  c,
+		tables: map[string][]*message2.Column{},
+	}
+}
+
+// columns returns column schema of table, nullability is queried from mysql at first sight of the table
+func (s *tableSchema) columns(table *schema.Table) []*message2.Column {
+	key := table.Schema + "." + table.Name
+	if cols := s.tables[key]; sameColumns(cols, table) {
+		return cols
+	}
+	nullable := s.nullable(table)
+	cols := make([]*message2.Column, len(table.Columns))
+	for i, v := range table.Columns {
+		cols[i] = &message2.Column{
+			Name:      v.Name,
+			Type:      v.RawType,
+			Unsigned:  v.IsUnsigned,
+			Nullable:  nullable[v.Name],
+			Collation: v.Collation,
+		}
+	}
+	s.tables[key] = cols
+	return cols
+}
+
+// sameColumns returns true if cached columns are the same as columns of table
+func sameColumns(cols []*message2.Column, table *schema.Table) bool {
+	if cols == nil || len(cols) != len(table.Columns) {
+		return false
+	}
+	for i, v := range table.Columns {
+		if cols[i].Name != v.Name || cols[i].Type != v.RawType {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *tableSchema) nullable(table *schema.Table) (res map[string]bool) {
+	res = map[string]bool{}
+	if s.canal == nil {
+		return
+	}
+	query := fmt.Sprintf("SHOW FULL COLUMNS FROM `%s`.`%s`", table.Schema, table.Name)
+	r, err := s.canal.Execute(query)
+	if err != nil {
+		logrus.Errorln("Get columns of table error: ", err)
+		return
+	}
+	for i := 0; i < r.RowNumber(); i++ {
+		name, _ := r.GetString(i, 0)
+		null, _ := r.GetString(i, 3)
+		res[name] = null == "YES"
+	}
+	return
+}
+
+// invalidate drops cached schema of table after ddl.
+// builtin delete is shadowed by the delete message builder of this package
+func (s *tableSchema) invalidate(database string, table string) {
+	s.tables[database+"."+table] = nil
+}
+
+// fill sets schema and primary key of message head, row is the row data the primary key read from
+func (s *tableSchema) fill(table *schema.Table, msg *message2.Message, row map[string]interface{}) {
+	msg.Content.Head.Schema = s.columns(table)
+	if len(table.PKColumns) == 0 {
+		return
+	}
+	pk := &message2.PK{
+		Columns: make([]string, len(table.PKColumns)),
+		Values:  make([]interface{}, len(table.PKColumns)),
+	}
+	for i := range table.PKColumns {
+		name := table.GetPKColumn(i).Name
+		pk.Columns[i] = name
+		pk.Values[i] = row[name]
+	}
+	msg.Content.Head.PK = pk
+}
+
+// rowData returns the row identifies the message, new row of insert and update, old row of delete
+func rowData(msg *message2.Message) map[string]interface{} {
+	switch data := msg.Content.Data.(type) {
+	case message2.Insert:
+		{
+			return data.New
+		}
+	case message2.Update:
+		{
+			return data.New
+		}
+	case message2.Delete:
+		{
+			return data.Old
+		}
+	case message2.Snapshot:
+		{
+			return data.New
+		}
+	}
+	return nil
+}
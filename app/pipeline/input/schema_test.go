@@ -0,0 +1,34 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+)
+
+func TestTableSchema(t *testing.T) {
+	table := &schema.Table{
+		Schema: "database1",
+		Name:   "table1",
+		Columns: []schema.TableColumn{
+			{Name: "id", Type: schema.TYPE_NUMBER, RawType: "int(10) unsigned", IsUnsigned: true},
+			{Name: "name", Type: schema.TYPE_STRING, RawType: "varchar(64)", Collation: "utf8mb4_general_ci"},
+		},
+		PKColumns: []int{0},
+	}
+	s := newTableSchema(nil)
+	msg := message2.Get()
+	msg.Content.Data = message2.Delete{Old: map[string]interface{}{"id": uint32(10), "name": "roy"}}
+	s.fill(table, msg, rowData(msg))
+	if len(msg.Content.Head.Schema) != 2 || msg.Content.Head.Schema[0].Type != "int(10) unsigned" || !msg.Content.Head.Schema[0].Unsigned {
+		t.Fail()
+	}
+	if msg.Content.Head.PK == nil || msg.Content.Head.PK.Columns[0] != "id" || msg.Content.Head.PK.Values[0] != uint32(10) {
+		t.Fail()
+	}
+	s.invalidate("database1", "table1")
+	if s.tables["database1.table1"] != nil {
+		t.Fail()
+	}
+}
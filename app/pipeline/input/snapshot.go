@@ -0,0 +1,373 @@
+package input
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/configs"
+	"github.com/jin06/binlogo/pkg/mysql/replication"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
+	"github.com/jin06/binlogo/pkg/promeths"
+	"github.com/jin06/binlogo/pkg/store/dao/dao_pipe"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// snapshotChunkSize rows read by one snapshot query
+const snapshotChunkSize = 1000
+
+var errSnapshotCanceled = errors.New("snapshot canceled")
+
+// prepareSnapshot returns the snapshot should be run before binlog streaming, nil if not needed.
+// a new snapshot is only created when the pipeline has no position,
+// the captured position is recorded so that binlog streaming continues from it.
+func (r *Input) prepareSnapshot() (snap *pipeline.Snapshot, conn *client.Conn, err error) {
+	if !r.pipe.Mysql.Snapshot {
+		return
+	}
+	snap, err = dao_pipe.GetSnapshot(r.Options.PipeName)
+	if err != nil {
+		return
+	}
+	if snap != nil {
+		if snap.Done {
+			snap = nil
+			return
+		}
+		conn, err = r.resumeSnapshot(snap)
+		if err != nil {
+			snap = nil
+		}
+		return
+	}
+	record, err := dao_pipe.GetRecord(r.Options.PipeName)
+	if err != nil {
+		return
+	}
+	if record != nil && record.Pre != nil {
+		return
+	}
+	conn, err = r.snapshotConn()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			conn.Close()
+			conn = nil
+			snap = nil
+		}
+	}()
+	snap = &pipeline.Snapshot{
+		PipelineName: r.Options.PipeName,
+		CreateTime:   time.Now(),
+	}
+	if _, err = conn.Execute("FLUSH TABLES WITH READ LOCK"); err != nil {
+		return
+	}
+	if _, err = conn.Execute("START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+		conn.Execute("UNLOCK TABLES")
+		return
+	}
+	snap.Position, err = r.snapshotPosition(conn)
+	if _, errUnlock := conn.Execute("UNLOCK TABLES"); err == nil {
+		err = errUnlock
+	}
+	if err != nil {
+		return
+	}
+	snap.Tables, err = r.snapshotTables(conn)
+	if err != nil {
+		return
+	}
+	if err = dao_pipe.UpdateSnapshot(snap); err != nil {
+		return
+	}
+	err = dao_pipe.UpdateRecord(&pipeline.RecordPosition{
+		PipelineName: r.Options.PipeName,
+		Pre:          snap.Position,
+	})
+	return
+}
+
+// resumeSnapshot continues the unfinished snapshot after the last primary key of each table.
+// Rows changed since the snapshot started are read with their newer values, binlog streaming still
+// continues from the position of the snapshot, so they converge after the changes are replayed
+func (r *Input) resumeSnapshot(snap *pipeline.Snapshot) (conn *client.Conn, err error) {
+	logrus.Infoln("Resume unfinished snapshot of pipeline", r.Options.PipeName)
+	conn, err = r.snapshotConn()
+	if err != nil {
+		return
+	}
+	if _, err = conn.Execute("START TRANSACTION WITH CONSISTENT SNAPSHOT"); err == nil {
+		err = dao_pipe.UpdateRecord(&pipeline.RecordPosition{
+			PipelineName: r.Options.PipeName,
+			Pre:          snap.Position,
+		})
+	}
+	if err != nil {
+		conn.Close()
+		conn = nil
+	}
+	return
+}
+
+func (r *Input) snapshotConn() (conn *client.Conn, err error) {
+	conn, err = replication.Connect(r.pipe.Mysql, r.addr())
+	if err != nil {
+		return
+	}
+	_, err = conn.Execute("SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ")
+	if err == nil {
+		// timestamp is read in UTC the same as binlog rows
+		_, err = conn.Execute("SET SESSION time_zone = '+00:00'")
+	}
+	if err != nil {
+		conn.Close()
+		conn = nil
+	}
+	return
+}
+
+// snapshotPosition get current binlog position while tables are locked
+func (r *Input) snapshotPosition(conn *client.Conn) (pos *pipeline.Position, err error) {
+	status, err := replication.GetMasterStatus(conn, r.pipe.Mysql.Flavor.YaString())
+	if err != nil {
+		return
+	}
+	pos = &pipeline.Position{
+		PipelineName:   r.Options.PipeName,
+		BinlogFile:     status.File,
+		BinlogPosition: status.Position,
+	}
+	if r.pipe.Mysql.Mode == pipeline.MODE_GTID {
+		pos.GTIDSet = status.GTIDSet
+	}
+	return
+}
+
+// snapshotTables list tables that pass the pipeline filters
+func (r *Input) snapshotTables(conn *client.Conn) (tables []*pipeline.SnapshotTable, err error) {
+	res, err := conn.Execute("SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.TABLES " +
+		"WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_SCHEMA NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys') " +
+		"ORDER BY TABLE_SCHEMA, TABLE_NAME")
+	if err != nil {
+		return
+	}
+	filter := tool.NewFilter(r.pipe.Filters)
+	tables = []*pipeline.SnapshotTable{}
+	for i := 0; i < res.RowNumber(); i++ {
+		var database, table string
+		if database, err = res.GetString(i, 0); err != nil {
+			return
+		}
+		if table, err = res.GetString(i, 1); err != nil {
+			return
+		}
+		isFilter, errFilter := filter.IsFilterWithName(database + "." + table)
+		if errFilter != nil || isFilter {
+			continue
+		}
+		tables = append(tables, &pipeline.SnapshotTable{Database: database, Table: table})
+	}
+	return
+}
+
+// runSnapshot read rows of every unfinished table and send them to the pipeline
+func (r *Input) runSnapshot(snap *pipeline.Snapshot, conn *client.Conn) (err error) {
+	defer conn.Close()
+	logrus.Infoln("Run initial snapshot of pipeline", r.Options.PipeName)
+	for _, t := range snap.Tables {
+		if t.Done {
+			continue
+		}
+		if err = r.snapshotTable(snap, t, conn); err != nil {
+			return
+		}
+	}
+	conn.Execute("COMMIT")
+	snap.Done = true
+	if err = r.sendSnapshotMarker(snap); err != nil {
+		return
+	}
+	logrus.Infoln("Initial snapshot of pipeline finished", r.Options.PipeName)
+	return
+}
+
+func (r *Input) snapshotTable(snap *pipeline.Snapshot, t *pipeline.SnapshotTable, conn *client.Conn) (err error) {
+	table, err := r.canal.GetTable(t.Database, t.Table)
+	if err != nil {
+		return
+	}
+	name := fmt.Sprintf("`%s`.`%s`", t.Database, t.Table)
+	if len(table.PKColumns) == 0 {
+		// without primary key the table can not be read in chunks, read it as a whole
+		t.Rows = 0
+		var result mysql.Result
+		err = conn.ExecuteSelectStreaming("SELECT * FROM "+name, &result, func(row []mysql.FieldValue) (err error) {
+			_, err = r.sendSnapshotRow(snap, t, table, result.Fields, row)
+			return
+		})
+		if err != nil {
+			if r.ctx.Err() != nil {
+				// the error of callback is wrapped by client
+				err = errSnapshotCanceled
+			}
+			return
+		}
+		t.Done = true
+		return r.sendSnapshotMarker(snap)
+	}
+	return r.snapshotChunks(snap, t, table, conn.Execute)
+}
+
+// snapshotChunks reads table with primary key in chunks ordered by the key, from the row after the last primary key
+// read, so an interrupted snapshot continues where it stopped
+func (r *Input) snapshotChunks(snap *pipeline.Snapshot, t *pipeline.SnapshotTable, table *schema.Table, execute func(command string, args ...interface{}) (*mysql.Result, error)) (err error) {
+	name := fmt.Sprintf("`%s`.`%s`", t.Database, t.Table)
+	pkNames := make([]string, len(table.PKColumns))
+	for i := range table.PKColumns {
+		pkNames[i] = "`" + table.GetPKColumn(i).Name + "`"
+	}
+	pkList := strings.Join(pkNames, ", ")
+	for !t.Done {
+		query := "SELECT * FROM " + name
+		args := snapshotArgs(t.LastPK)
+		if len(args) > 0 {
+			query += fmt.Sprintf(" WHERE (%s) > (%s)", pkList, strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", "))
+		}
+		query += fmt.Sprintf(" ORDER BY %s LIMIT %d", pkList, snapshotChunkSize)
+		var res *mysql.Result
+		res, err = execute(query, args...)
+		if err != nil {
+			return
+		}
+		for i := range res.Values {
+			var row map[string]interface{}
+			if row, err = r.sendSnapshotRow(snap, t, table, res.Fields, res.Values[i]); err != nil {
+				return
+			}
+			lastPK := make([]interface{}, len(table.PKColumns))
+			for k := range table.PKColumns {
+				lastPK[k] = row[table.GetPKColumn(k).Name]
+			}
+			t.LastPK = lastPK
+		}
+		if res.RowNumber() < snapshotChunkSize {
+			t.Done = true
+		}
+		if err = r.sendSnapshotMarker(snap); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// sendSnapshotRow send a row as snapshot message, returns the row data
+func (r *Input) sendSnapshotRow(snap *pipeline.Snapshot, t *pipeline.SnapshotTable, table *schema.Table, fields []*mysql.Field, row []mysql.FieldValue) (newer map[string]interface{}, err error) {
+	newer = map[string]interface{}{}
+	for i := range fields {
+		if i >= len(row) {
+			break
+		}
+		name := string(fields[i].Name)
+		var column *schema.TableColumn
+		if idx := table.FindColumn(name); idx >= 0 {
+			column = &table.Columns[idx]
+		}
+		newer[name] = r.conv.value(column, snapshotValue(column, row[i].Value()))
+	}
+	t.Rows++
+	msg := message2.Get()
+	msg.Content.Head.Type = message2.TYPE_SNAPSHOT.String()
+	msg.Content.Head.Database = t.Database
+	msg.Content.Head.Table = t.Table
+	msg.Content.Head.Time = uint32(time.Now().Unix())
+	msg.Content.Head.Position = *snap.Position
+	msg.Content.Data = message2.Snapshot{New: newer}
+	if r.schema != nil {
+		r.schema.fill(table, msg, newer)
+	}
+	if err = r.sendSnapshot(msg); err != nil {
+		return
+	}
+	promeths.MessageTotalCounter.With(prometheus.Labels{"pipeline": r.Options.PipeName, "node": configs.NodeName}).Inc()
+	return
+}
+
+// sendSnapshotMarker send a filtered message carrying snapshot progress, output records it after previous rows are sent
+func (r *Input) sendSnapshotMarker(snap *pipeline.Snapshot) error {
+	msg := message2.Get()
+	msg.Filter = true
+	msg.Content.Head.Type = message2.TYPE_SNAPSHOT.String()
+	msg.Content.Head.Time = uint32(time.Now().Unix())
+	msg.Content.Head.Position = *snap.Position
+	msg.Snapshot = snap.Clone()
+	return r.sendSnapshot(msg)
+}
+
+// sendSnapshot sends snapshot message to the pipeline, it returns errSnapshotCanceled if the input is stopped
+func (r *Input) sendSnapshot(msg *message2.Message) error {
+	select {
+	case <-r.ctx.Done():
+		{
+			message2.Put(msg)
+			return errSnapshotCanceled
+		}
+	case r.OutChan <- msg:
+		{
+			return nil
+		}
+	}
+}
+
+// snapshotValue converts text value of query result to the type same as binlog rows,
+// then it is converted by converter like binlog rows
+func snapshotValue(column *schema.TableColumn, val interface{}) interface{} {
+	b, ok := val.([]byte)
+	if !ok {
+		return val
+	}
+	if column == nil {
+		return string(b)
+	}
+	switch column.Type {
+	case schema.TYPE_BINARY, schema.TYPE_BIT:
+		{
+			return append([]byte{}, b...)
+		}
+	}
+	if strings.Contains(column.RawType, "blob") {
+		return append([]byte{}, b...)
+	}
+	return string(b)
+}
+
+// snapshotArgs converts primary key values loaded from etcd to query args
+func snapshotArgs(lastPK []interface{}) (args []interface{}) {
+	args = make([]interface{}, len(lastPK))
+	for i, v := range lastPK {
+		args[i] = v
+		n, ok := v.(json.Number)
+		if !ok {
+			continue
+		}
+		if i64, err := n.Int64(); err == nil {
+			args[i] = i64
+		} else if u64, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+			args[i] = u64
+		} else if f64, err := n.Float64(); err == nil {
+			args[i] = f64
+		}
+	}
+	return
+}
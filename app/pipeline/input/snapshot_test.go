@@ -0,0 +1,132 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/promeths"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestSnapshotValue(t *testing.T) {
+	if v := snapshotValue(&schema.TableColumn{Type: schema.TYPE_STRING}, []byte("roy")); v != "roy" {
+		t.Fail()
+	}
+	if v := snapshotValue(&schema.TableColumn{Type: schema.TYPE_DECIMAL}, []byte("1.50")); v != "1.50" {
+		t.Fail()
+	}
+	if _, ok := snapshotValue(&schema.TableColumn{Type: schema.TYPE_BINARY}, []byte{1}).([]byte); !ok {
+		t.Fail()
+	}
+	if v := snapshotValue(nil, int64(1)); v != int64(1) {
+		t.Fail()
+	}
+}
+
+func TestSnapshotArgs(t *testing.T) {
+	args := snapshotArgs([]interface{}{json.Number("10"), json.Number("18446744073709551615"), "a"})
+	if args[0] != int64(10) || args[1] != uint64(18446744073709551615) || args[2] != "a" {
+		t.Error(args)
+	}
+}
+
+func TestSendSnapshotCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := &Input{OutChan: make(chan *message2.Message), ctx: ctx}
+	if err := r.sendSnapshot(message2.New()); err != errSnapshotCanceled {
+		t.Error(err)
+	}
+}
+
+func TestSnapshotResume(t *testing.T) {
+	promeths.Init()
+	table := &schema.Table{
+		Schema:    "shop",
+		Name:      "item",
+		Columns:   []schema.TableColumn{{Name: "id", Type: schema.TYPE_NUMBER}, {Name: "name", Type: schema.TYPE_STRING}},
+		PKColumns: []int{0},
+	}
+	const total = 2500
+	// execute serves rows of the table after the primary key in args
+	execute := func(command string, args ...interface{}) (res *mysql.Result, err error) {
+		var last int64
+		if len(args) > 0 {
+			last = args[0].(int64)
+		}
+		var values [][]interface{}
+		for id := last + 1; id <= total && len(values) < snapshotChunkSize; id++ {
+			values = append(values, []interface{}{id, "item"})
+		}
+		rs, err := mysql.BuildSimpleTextResultset([]string{"id", "name"}, values)
+		if err != nil {
+			return
+		}
+		for _, row := range rs.RowDatas {
+			var data []mysql.FieldValue
+			if data, err = row.ParseText(rs.Fields, nil); err != nil {
+				return
+			}
+			rs.Values = append(rs.Values, data)
+		}
+		return &mysql.Result{Resultset: rs}, nil
+	}
+	snap := &pipeline.Snapshot{
+		PipelineName: "test",
+		Position:     &pipeline.Position{BinlogFile: "mysql-bin.000001", BinlogPosition: 4},
+		Tables:       []*pipeline.SnapshotTable{{Database: "shop", Table: "item"}},
+	}
+	// run reads the table until stop returns true for a marker, it returns ids of rows and the last progress
+	run := func(snap *pipeline.Snapshot, stop func(progress *pipeline.Snapshot) bool) (ids []int64, progress *pipeline.Snapshot, err error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := &Input{OutChan: make(chan *message2.Message), ctx: ctx, Options: &Options{PipeName: "test"}}
+		done := make(chan error, 1)
+		go func() {
+			done <- r.snapshotChunks(snap, snap.Tables[0], table, execute)
+		}()
+		for {
+			select {
+			case err = <-done:
+				{
+					return
+				}
+			case msg := <-r.OutChan:
+				{
+					if msg.Snapshot != nil {
+						progress = msg.Snapshot
+						if stop(progress) {
+							// the input is stopped while it sends the next row
+							cancel()
+							err = <-done
+							return
+						}
+						continue
+					}
+					ids = append(ids, msg.Content.Data.(message2.Snapshot).New["id"].(int64))
+				}
+			}
+		}
+	}
+	// the snapshot is interrupted after the first chunk
+	ids, progress, err := run(snap, func(*pipeline.Snapshot) bool { return true })
+	if err != errSnapshotCanceled || len(ids) != snapshotChunkSize || progress.Tables[0].Done {
+		t.Fatal(len(ids), progress, err)
+	}
+	// progress is loaded from etcd on another node
+	resumed := &pipeline.Snapshot{}
+	if err = resumed.Unmarshal([]byte(progress.Val())); err != nil {
+		t.Fatal(err)
+	}
+	ids, progress, err = run(resumed, func(*pipeline.Snapshot) bool { return false })
+	if err != nil || len(ids) != total-snapshotChunkSize || ids[0] != snapshotChunkSize+1 || ids[len(ids)-1] != total {
+		t.Fatal(len(ids), err)
+	}
+	if !progress.Tables[0].Done || progress.Tables[0].Rows != total || *progress.Position != *snap.Position {
+		t.Error(progress.Tables[0], progress.Position)
+	}
+}
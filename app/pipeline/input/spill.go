@@ -0,0 +1,80 @@
+package input
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+)
+
+func init() {
+	// concrete types carried by interface values of row messages
+	gob.Register(message2.Insert{})
+	gob.Register(message2.Update{})
+	gob.Register(message2.Delete{})
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register(json.Number(""))
+	gob.Register(time.Time{})
+}
+
+// spill holds row messages of a large transaction in a temporary segment file instead of memory,
+// they are read back in order when the transaction is sent
+type spill struct {
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *gob.Encoder
+	rows    int
+	same    sameTable
+}
+
+func newSpill() (s *spill, err error) {
+	file, err := os.CreateTemp("", "binlogo-spill-*")
+	if err != nil {
+		return
+	}
+	s = &spill{file: file, writer: bufio.NewWriter(file)}
+	s.encoder = gob.NewEncoder(s.writer)
+	return
+}
+
+// write writes message to the segment file, the message is put back to pool
+func (s *spill) write(msg *message2.Message) (err error) {
+	if err = s.encoder.Encode(msg); err != nil {
+		return
+	}
+	s.rows++
+	s.same.add(msg.Content.Head.Database, msg.Content.Head.Table)
+	message2.Put(msg)
+	return
+}
+
+// each reads spilled messages in the order they were written
+func (s *spill) each(fn func(msg *message2.Message)) (err error) {
+	if err = s.writer.Flush(); err != nil {
+		return
+	}
+	if _, err = s.file.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	decoder := gob.NewDecoder(bufio.NewReader(s.file))
+	for i := 0; i < s.rows; i++ {
+		msg := message2.Get()
+		if err = decoder.Decode(msg); err != nil {
+			message2.Put(msg)
+			return
+		}
+		fn(msg)
+	}
+	return
+}
+
+// close removes the segment file
+func (s *spill) close() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
@@ -0,0 +1,93 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/promeths"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestSpill(t *testing.T) {
+	s, err := newSpill()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	for _, msg := range transactionRows() {
+		msg.Content.Data = message2.Update{
+			Old: map[string]interface{}{"id": 1, "name": nil},
+			New: map[string]interface{}{"id": 1, "name": "roy"},
+		}
+		if err = s.write(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.rows != 2 || s.same.database != "database1" || s.same.table != "" {
+		t.Fatal(s.rows, s.same)
+	}
+	tables := []string{}
+	err = s.each(func(msg *message2.Message) {
+		tables = append(tables, msg.Content.Head.Table)
+		update, ok := msg.Content.Data.(message2.Update)
+		if !ok || update.New["name"] != "roy" || update.Old["name"] != nil {
+			t.Fail()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 || tables[0] != "table1" || tables[1] != "table2" {
+		t.Fatal(tables)
+	}
+}
+
+func TestHandlerSpill(t *testing.T) {
+	promeths.Init()
+	for _, mode := range []pipeline.TransactionMode{pipeline.TRANSACTION_NONE, pipeline.TRANSACTION_MARKER} {
+		handler := &canalHandler{
+			DummyEventHandler: canal.DummyEventHandler{},
+			ch:                make(chan *message2.Message, 10),
+			pipe:              &pipeline.Pipeline{Name: "go_test_pipeline"},
+			transaction:       mode,
+			spillRows:         1,
+		}
+		for i := 0; i < 2; i++ {
+			if err := handler.spillMessages(transactionRows()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(handler.messages) != 1 || handler.spill == nil || handler.spill.rows != 3 {
+			t.Fatal(len(handler.messages))
+		}
+		handler.xid = true
+		if err := handler.OnPosSynced(mysql.Position{Name: "mysql-bin.000001", Pos: 1000}, nil, false); err != nil {
+			t.Fatal(err)
+		}
+		if handler.spill != nil {
+			t.Fail()
+		}
+		types := []string{}
+		tables := []string{}
+		total := len(handler.ch)
+		for i := 0; i < total; i++ {
+			msg := <-handler.ch
+			if msg.Content.Head.Position.ConsumeRows != i+1 || msg.Content.Head.Position.TotalRows != total {
+				t.Fail()
+			}
+			types = append(types, msg.Content.Head.Type)
+			tables = append(tables, msg.Content.Head.Table)
+		}
+		if mode == pipeline.TRANSACTION_NONE {
+			if total != 4 || tables[0] != "table1" || tables[1] != "table2" || tables[2] != "table1" || tables[3] != "table2" {
+				t.Fatal(tables)
+			}
+			continue
+		}
+		if total != 6 || types[0] != "begin" || types[5] != "commit" || tables[1] != "table1" || tables[4] != "table2" {
+			t.Fatal(types, tables)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/jin06/binlogo/pkg/event"
+	event2 "github.com/jin06/binlogo/pkg/store/model/event"
+	"github.com/sirupsen/logrus"
+)
+
+// streamed returns true if binlog of mysql is read by event reader instead of canal.
+// canal of go-mysql v1.3.0 drops rows query events, they are needed by rows_query,
+// and columns bitmap of rows, without it columns absent in a row image other than FULL can not be told from null
+func (r *Input) streamed() bool {
+	if r.pipe.Mysql.RowsQuery {
+		return true
+	}
+	res, err := r.canal.Execute("SELECT @@GLOBAL.binlog_row_image")
+	if err != nil {
+		logrus.Errorln("Get binlog_row_image error, binlog is read by event reader: ", err)
+		return true
+	}
+	image, _ := res.GetString(0, 0)
+	return image != "" && !strings.EqualFold(image, "FULL")
+}
+
+// runStream streams binlog from position, or from gtid set if it is not nil, and passes events to handler by event reader.
+// Tables of rows are read by canal, which is not run. The reader stops when canal is closed
+func (r *Input) runStream(handler *canalHandler, pos mysql.Position, gset mysql.GTIDSet) (err error) {
+	cfg, err := r.canalConfig(r.pipe.Mysql.Candidates()[r.endpoint])
+	if err != nil {
+		return
+	}
+	syncerCfg, err := syncerConfig(cfg)
+	if err != nil {
+		return
+	}
+	syncer := replication.NewBinlogSyncer(syncerCfg)
+	reader := &eventReader{
+		handler: handler,
+		flavor:  r.pipe.Mysql.Flavor.YaString(),
+		canal:   r.canal,
+		noGTID:  gset == nil,
+		done:    make(chan struct{}),
+	}
+	var s *replication.BinlogStreamer
+	if gset != nil {
+		reader.gset = gset.Clone()
+		s, err = syncer.StartSyncGTID(gset.Clone())
+	} else {
+		s, err = syncer.StartSync(pos)
+	}
+	if err != nil {
+		syncer.Close()
+		return
+	}
+	reader.syncedPos = pos
+	if gset != nil {
+		reader.syncedSet = gset.Clone()
+	}
+	handler.reader = reader
+	r.canalMutex.Lock()
+	r.reader = reader
+	r.canalMutex.Unlock()
+	ctx := r.canal.Ctx()
+	go func() {
+		defer close(reader.done)
+		defer syncer.Close()
+		if errStream := reader.stream(ctx, s, pos.Name); errStream != nil && ctx.Err() == nil {
+			event.Event(event2.NewErrorPipeline(r.Options.PipeName, "Read mysql binlog error: "+errStream.Error()))
+		}
+	}()
+	return
+}
+
+// stream reads events of streamer until it fails or ctx is done, name is the binlog file streaming starts from.
+// After the end condition is reached events are not read any more
+func (f *eventReader) stream(ctx context.Context, s *replication.BinlogStreamer, name string) (err error) {
+	for {
+		var ev *replication.BinlogEvent
+		if ev, err = s.GetEvent(ctx); err != nil {
+			return
+		}
+		rotate, isRotate := ev.Event.(*replication.RotateEvent)
+		if ev.Header.LogPos == 0 {
+			// fake rotate event only carries name of the binlog file events come from
+			if isRotate {
+				name = string(rotate.NextLogName)
+			}
+			continue
+		}
+		if err = f.onEvent(ctx, name, ev); err != nil {
+			if f.stopped {
+				<-ctx.Done()
+				return nil
+			}
+			return
+		}
+		if isRotate {
+			name = string(rotate.NextLogName)
+		}
+	}
+}
+
+// syncerConfig returns config of binlog syncer the same as canal creates from its config
+func syncerConfig(cfg *canal.Config) (res replication.BinlogSyncerConfig, err error) {
+	res = replication.BinlogSyncerConfig{
+		ServerID:                cfg.ServerID,
+		Flavor:                  cfg.Flavor,
+		User:                    cfg.User,
+		Password:                cfg.Password,
+		Charset:                 cfg.Charset,
+		HeartbeatPeriod:         cfg.HeartbeatPeriod,
+		ReadTimeout:             cfg.ReadTimeout,
+		UseDecimal:              cfg.UseDecimal,
+		ParseTime:               cfg.ParseTime,
+		SemiSyncEnabled:         cfg.SemiSyncEnabled,
+		MaxReconnectAttempts:    cfg.MaxReconnectAttempts,
+		DisableRetrySync:        cfg.DisableRetrySync,
+		TimestampStringLocation: cfg.TimestampStringLocation,
+		TLSConfig:               cfg.TLSConfig,
+	}
+	if strings.Contains(cfg.Addr, "/") {
+		res.Host = cfg.Addr
+		return
+	}
+	idx := strings.LastIndex(cfg.Addr, ":")
+	if idx < 0 {
+		err = fmt.Errorf("invalid mysql addr format %s, must host:port", cfg.Addr)
+		return
+	}
+	port, err := strconv.ParseUint(cfg.Addr[idx+1:], 10, 16)
+	if err != nil {
+		return
+	}
+	res.Host, res.Port = cfg.Addr[:idx], uint16(port)
+	return
+}
@@ -0,0 +1,110 @@
+package input
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/promeths"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestSyncerConfig(t *testing.T) {
+	cfg, err := syncerConfig(&canal.Config{Addr: "127.0.0.1:13306", ServerID: 10, HeartbeatPeriod: heartbeatPeriod})
+	if err != nil || cfg.Host != "127.0.0.1" || cfg.Port != 13306 || cfg.ServerID != 10 || cfg.HeartbeatPeriod != heartbeatPeriod {
+		t.Error(cfg, err)
+	}
+	if cfg, err = syncerConfig(&canal.Config{Addr: "[::1]:3306"}); err != nil || cfg.Host != "[::1]" || cfg.Port != 3306 {
+		t.Error(cfg, err)
+	}
+	if _, err = syncerConfig(&canal.Config{Addr: "127.0.0.1"}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestReaderPosition(t *testing.T) {
+	promeths.Init()
+	m := &pipeline.Mysql{Mode: pipeline.MODE_POSITION}
+	reader := &eventReader{
+		handler: &canalHandler{
+			ch:    make(chan *message2.Message, 10),
+			pipe:  &pipeline.Pipeline{Name: "go_test_pipeline", Mysql: m},
+			image: newRowImage(m),
+		},
+		noGTID: true,
+	}
+	events := []*replication.BinlogEvent{
+		{
+			Header: &replication.EventHeader{EventType: replication.PREVIOUS_GTIDS_EVENT, LogPos: 100},
+			Event:  &replication.PreviousGTIDsEvent{GTIDSets: "de278ad0-2106-11e4-9f8e-6edd0ca20947:1-2"},
+		},
+		{
+			Header: &replication.EventHeader{EventType: replication.WRITE_ROWS_EVENTv2, LogPos: 200},
+			Event:  &replication.RowsEvent{Table: fileTableEvent(), Rows: [][]interface{}{{int32(1), nil, int64(1), "roy"}}},
+		},
+		{
+			Header: &replication.EventHeader{EventType: replication.XID_EVENT, LogPos: 300},
+			Event:  &replication.XIDEvent{},
+		},
+	}
+	for _, ev := range events {
+		if err := reader.onEvent(context.Background(), "mysql-bin.000002", ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// gtid set of a file streamed from a position is not the executed set, it is not tracked in position mode
+	pos, set := reader.position()
+	if pos != (mysql.Position{Name: "mysql-bin.000002", Pos: 300}) || set != nil {
+		t.Error(pos, set)
+	}
+	if msg := <-reader.handler.ch; msg.Content.Head.Position.GTIDSet != "" || msg.Content.Head.Position.BinlogPosition != 300 {
+		t.Error(msg.Content.Head.Position)
+	}
+}
+
+func TestReaderRowImage(t *testing.T) {
+	promeths.Init()
+	m := &pipeline.Mysql{Mode: pipeline.MODE_POSITION}
+	reader := &eventReader{
+		handler: &canalHandler{
+			ch:    make(chan *message2.Message, 10),
+			pipe:  &pipeline.Pipeline{Name: "go_test_pipeline", Mysql: m},
+			image: newRowImage(m),
+		},
+		noGTID: true,
+	}
+	// binlog_row_image is MINIMAL, before image has primary key only, after image has changed column only
+	events := []*replication.BinlogEvent{
+		{
+			Header: &replication.EventHeader{EventType: replication.UPDATE_ROWS_EVENTv2, LogPos: 200},
+			Event: &replication.RowsEvent{
+				Table:          fileTableEvent(),
+				Rows:           [][]interface{}{{int32(1), nil, nil, nil}, {nil, nil, nil, "bob"}},
+				SkippedColumns: [][]int{{1, 2, 3}, {0, 1, 2}},
+			},
+		},
+		{
+			Header: &replication.EventHeader{EventType: replication.XID_EVENT, LogPos: 300},
+			Event:  &replication.XIDEvent{},
+		},
+	}
+	for _, ev := range events {
+		if err := reader.onEvent(context.Background(), "mysql-bin.000002", ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	update, ok := (<-reader.handler.ch).Content.Data.(message2.Update)
+	if !ok {
+		t.Fatal("not update")
+	}
+	if update.New["id"] != uint32(1) || update.New["name"] != "bob" || !reflect.DeepEqual(update.Changed, []string{"name"}) {
+		t.Error(update)
+	}
+	if !reflect.DeepEqual(update.NewMissing, []string{"price", "flag"}) || !reflect.DeepEqual(update.OldMissing, []string{"price", "flag", "name"}) {
+		t.Error(update.OldMissing, update.NewMissing)
+	}
+}
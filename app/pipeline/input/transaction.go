@@ -0,0 +1,116 @@
+package input
+
+import (
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// transactionMessages wraps row messages of a transaction by transaction mode
+func transactionMessages(mode pipeline.TransactionMode, msgs []*message2.Message, gtid string) []*message2.Message {
+	if len(msgs) == 0 {
+		return msgs
+	}
+	switch mode {
+	case pipeline.TRANSACTION_MARKER:
+		{
+			return transactionMarkers(msgs, gtid)
+		}
+	case pipeline.TRANSACTION_GROUP:
+		{
+			return []*message2.Message{transactionMessage(msgs, gtid)}
+		}
+	}
+	return msgs
+}
+
+// transactionMarkers adds begin and commit messages around rows
+func transactionMarkers(msgs []*message2.Message, gtid string) (res []*message2.Message) {
+	same := &sameTable{}
+	for _, v := range msgs {
+		same.add(v.Content.Head.Database, v.Content.Head.Table)
+	}
+	res = make([]*message2.Message, 0, len(msgs)+2)
+	res = append(res, transactionMarker(message2.TYPE_BEGIN, &msgs[0].Content.Head, same, gtid))
+	res = append(res, msgs...)
+	res = append(res, transactionMarker(message2.TYPE_COMMIT, &msgs[0].Content.Head, same, gtid))
+	return
+}
+
+// transactionMarker returns begin or commit message of the transaction, first is head of the first row
+func transactionMarker(t message2.MessageType, first *message2.Head, same *sameTable, gtid string) (msg *message2.Message) {
+	msg = message2.Get()
+	msg.Content.Head.Type = t.String()
+	msg.Content.Head.Time = first.Time
+	msg.Content.Head.Database = same.database
+	msg.Content.Head.Table = same.table
+	msg.Content.Data = message2.TransactionMarker{GTID: gtid, Rows: same.rows}
+	return
+}
+
+// transactionMessage groups rows into one message, row messages are put back to pool
+func transactionMessage(msgs []*message2.Message, gtid string) (msg *message2.Message) {
+	msg = transactionHead(msgs, message2.TYPE_TRANSACTION)
+	trx := message2.Transaction{GTID: gtid, Changes: make([]*message2.Change, len(msgs))}
+	for i, v := range msgs {
+		trx.Changes[i] = &message2.Change{
+			Type:     v.Content.Head.Type,
+			Database: v.Content.Head.Database,
+			Table:    v.Content.Head.Table,
+			PK:       v.Content.Head.PK,
+			Data:     v.Content.Data,
+		}
+		message2.Put(v)
+	}
+	msg.Content.Data = trx
+	return
+}
+
+// transactionHead returns a message with head of the transaction,
+// database and table are set only when all rows belong to the same one
+func transactionHead(msgs []*message2.Message, t message2.MessageType) (msg *message2.Message) {
+	msg = message2.Get()
+	same := &sameTable{}
+	for _, v := range msgs {
+		same.add(v.Content.Head.Database, v.Content.Head.Table)
+	}
+	msg.Content.Head.Type = t.String()
+	msg.Content.Head.Time = msgs[0].Content.Head.Time
+	msg.Content.Head.Database = same.database
+	msg.Content.Head.Table = same.table
+	return
+}
+
+// sameTable tracks database and table shared by rows, empty if rows belong to different ones
+type sameTable struct {
+	database string
+	table    string
+	rows     int
+}
+
+// add adds a row of the table
+func (s *sameTable) add(database string, table string) {
+	s.rows++
+	if s.rows == 1 {
+		s.database = database
+		s.table = table
+		return
+	}
+	if database != s.database {
+		s.database = ""
+		s.table = ""
+		return
+	}
+	if table != s.table {
+		s.table = ""
+	}
+}
+
+// merge adds rows tracked by another sameTable
+func (s *sameTable) merge(o *sameTable) {
+	if o.rows == 0 {
+		return
+	}
+	rows := s.rows
+	s.add(o.database, o.table)
+	s.rows = rows + o.rows
+}
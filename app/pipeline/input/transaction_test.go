@@ -0,0 +1,48 @@
+package input
+
+import (
+	"testing"
+
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func transactionRows() []*message2.Message {
+	msgs := []*message2.Message{}
+	for _, table := range []string{"table1", "table2"} {
+		msg := message2.Get()
+		msg.Content.Head.Type = message2.TYPE_INSERT.String()
+		msg.Content.Head.Database = "database1"
+		msg.Content.Head.Table = table
+		msg.Content.Data = message2.Insert{New: map[string]interface{}{"id": 1}}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestTransactionMessages(t *testing.T) {
+	gtid := "045c649a-408d-11ec-ae21-0242ac110006:64"
+	msgs := transactionMessages(pipeline.TRANSACTION_NONE, transactionRows(), gtid)
+	if len(msgs) != 2 {
+		t.Fail()
+	}
+	msgs = transactionMessages(pipeline.TRANSACTION_MARKER, transactionRows(), gtid)
+	if len(msgs) != 4 || msgs[0].Content.Head.Type != "begin" || msgs[3].Content.Head.Type != "commit" {
+		t.Fatal(msgs)
+	}
+	marker, ok := msgs[3].Content.Data.(message2.TransactionMarker)
+	if !ok || marker.GTID != gtid || marker.Rows != 2 {
+		t.Fail()
+	}
+	if msgs[0].Content.Head.Database != "database1" || msgs[0].Content.Head.Table != "" {
+		t.Fail()
+	}
+	msgs = transactionMessages(pipeline.TRANSACTION_GROUP, transactionRows(), gtid)
+	if len(msgs) != 1 || msgs[0].Content.Head.Type != "transaction" {
+		t.Fatal(msgs)
+	}
+	trx, ok := msgs[0].Content.Data.(message2.Transaction)
+	if !ok || len(trx.Changes) != 2 || trx.Changes[1].Table != "table2" || trx.GTID != gtid {
+		t.Fail()
+	}
+}
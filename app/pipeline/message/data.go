@@ -4,12 +4,20 @@ package message
 type MessageType byte
 
 var (
-	TYPE_EMPTY        MessageType = 0
-	TYPE_INSERT       MessageType = 1
-	TYPE_UPDATE       MessageType = 2
-	TYPE_DELETE       MessageType = 3
-	TYPE_CREATE_TABLE MessageType = 4
-	TYPE_ALTER_TABLE  MessageType = 5
+	TYPE_EMPTY          MessageType = 0
+	TYPE_INSERT         MessageType = 1
+	TYPE_UPDATE         MessageType = 2
+	TYPE_DELETE         MessageType = 3
+	TYPE_CREATE_TABLE   MessageType = 4
+	TYPE_ALTER_TABLE    MessageType = 5
+	TYPE_DROP_TABLE     MessageType = 6
+	TYPE_RENAME_TABLE   MessageType = 7
+	TYPE_TRUNCATE_TABLE MessageType = 8
+	TYPE_SNAPSHOT       MessageType = 9
+	TYPE_BEGIN          MessageType = 10
+	TYPE_COMMIT         MessageType = 11
+	TYPE_TRANSACTION    MessageType = 12
+	TYPE_DDL            MessageType = 13
 )
 
 // String returns MessageType's string
@@ -35,6 +43,38 @@ func (mt MessageType) String() string {
 		{
 			return "alter_table"
 		}
+	case TYPE_DROP_TABLE:
+		{
+			return "drop_table"
+		}
+	case TYPE_RENAME_TABLE:
+		{
+			return "rename_table"
+		}
+	case TYPE_TRUNCATE_TABLE:
+		{
+			return "truncate_table"
+		}
+	case TYPE_SNAPSHOT:
+		{
+			return "snapshot"
+		}
+	case TYPE_BEGIN:
+		{
+			return "begin"
+		}
+	case TYPE_COMMIT:
+		{
+			return "commit"
+		}
+	case TYPE_TRANSACTION:
+		{
+			return "transaction"
+		}
+	case TYPE_DDL:
+		{
+			return "ddl"
+		}
 	case TYPE_EMPTY:
 		{
 			return "empty"
@@ -46,23 +86,87 @@ func (mt MessageType) String() string {
 // Insert for mysql insert
 type Insert struct {
 	New map[string]interface{} `json:"new"`
+	// Missing columns not present in row image of mysql, e.g. binlog_row_image is not FULL
+	Missing []string `json:"missing,omitempty"`
 }
 
 // Update for mysql update
 type Update struct {
 	Old map[string]interface{} `json:"old"`
 	New map[string]interface{} `json:"new"`
+	// Changed columns whose values differ between old and new
+	Changed []string `json:"changed"`
+	// OldMissing and NewMissing columns not present in before and after row image of mysql
+	OldMissing []string `json:"old_missing,omitempty"`
+	NewMissing []string `json:"new_missing,omitempty"`
 }
 
 // Delete for mysql delete
 type Delete struct {
 	Old map[string]interface{} `json:"old"`
+	// Missing columns not present in row image of mysql, e.g. binlog_row_image is not FULL
+	Missing []string `json:"missing,omitempty"`
+}
+
+// Snapshot for existing row read by initial snapshot
+type Snapshot struct {
+	New map[string]interface{} `json:"new"`
+}
+
+// DDL common part of mysql ddl, the raw query of the statement.
+// It is the data of TYPE_DDL message, which is ddl the parser fails on
+type DDL struct {
+	Query string `json:"query"`
 }
 
 // CreateTable for mysql ddl
 type CreateTable struct {
+	DDL
 }
 
 // AlterTable for mysql ddl
 type AlterTable struct {
+	DDL
+}
+
+// DropTable for mysql ddl
+type DropTable struct {
+	DDL
+}
+
+// RenameTable for mysql ddl, Database and Table of head is the old name
+type RenameTable struct {
+	DDL
+	NewDatabase string `json:"new_database"`
+	NewTable    string `json:"new_table"`
+}
+
+// TruncateTable for mysql ddl
+type TruncateTable struct {
+	DDL
+}
+
+// TransactionMarker for begin and commit of mysql transaction
+type TransactionMarker struct {
+	// GTID of the transaction, empty if gtid is not enabled in mysql
+	GTID string `json:"gtid"`
+	// Rows count of row changes in the transaction
+	Rows int `json:"rows"`
+}
+
+// Transaction for whole mysql transaction delivered as one message
+type Transaction struct {
+	GTID    string    `json:"gtid"`
+	Changes []*Change `json:"changes"`
+}
+
+// Change row change in transaction, Data is one of Insert, Update and Delete
+type Change struct {
+	Type     string      `json:"type"`
+	Database string      `json:"database"`
+	Table    string      `json:"table"`
+	PK       *PK         `json:"pk,omitempty"`
+	Data     interface{} `json:"data"`
+	// Origin physical table of the row, only set when the table is routed to a logical table
+	Origin *Origin `json:"origin,omitempty"`
 }
@@ -25,6 +25,14 @@ type Message struct {
 	Status  int16
 	Filter  bool
 	Content Content
+	// Snapshot progress of initial snapshot, carried by marker message at the end of a snapshot chunk
+	Snapshot *pipeline.Snapshot `json:"-"`
+	// Finished marks the end of bounded replay, output finishes the pipeline after messages before it are handled
+	Finished bool `json:"-"`
+	// Partial marks messages split from one message except the last, output does not record their position
+	Partial bool `json:"-"`
+	// RowKey physical table and primary key of the row before column rules, set by filter when coalescing is enabled
+	RowKey string `json:"-"`
 }
 
 // New return a new message
@@ -58,6 +66,14 @@ type Head struct {
 	Database string            `json:"database"`
 	Table    string            `json:"table"`
 	Position pipeline.Position `json:"position"`
+	// Schema column schema of the table, only set when enabled in pipeline
+	Schema []*Column `json:"schema,omitempty"`
+	// PK primary key of the row, only set when enabled in pipeline
+	PK *PK `json:"pk,omitempty"`
+	// Query statement causing the row, only set when enabled in pipeline
+	Query string `json:"query,omitempty"`
+	// Origin physical table of the row, only set when the table is routed to a logical table
+	Origin *Origin `json:"origin,omitempty"`
 }
 
 func (h *Head) reset() {
@@ -66,6 +82,32 @@ func (h *Head) reset() {
 	h.Database = ""
 	h.Table = ""
 	h.Position.Reset()
+	h.Schema = nil
+	h.PK = nil
+	h.Query = ""
+	h.Origin = nil
+}
+
+// Origin physical database and table of a logical table
+type Origin struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+}
+
+// Column schema of table column
+type Column struct {
+	Name string `json:"name"`
+	// Type mysql column type, e.g. int(10) unsigned, varchar(64)
+	Type      string `json:"type"`
+	Unsigned  bool   `json:"unsigned"`
+	Nullable  bool   `json:"nullable"`
+	Collation string `json:"collation,omitempty"`
+}
+
+// PK primary key column names and values of a row
+type PK struct {
+	Columns []string      `json:"columns"`
+	Values  []interface{} `json:"values"`
 }
 
 // Json marshal message to json data
@@ -103,6 +145,11 @@ func (msg *Message) ToString() string {
 	return res
 }
 
+// IsSnapshot returns true if message is generated by initial snapshot
+func (msg *Message) IsSnapshot() bool {
+	return msg.Content.Head.Type == TYPE_SNAPSHOT.String()
+}
+
 // Table returns table with database
 func (msg *Message) Table() string {
 	return fmt.Sprintf("%s.%s", msg.Content.Head.Database, msg.Content.Head.Table)
@@ -112,6 +159,10 @@ func (msg *Message) Table() string {
 func (msg *Message) reset() {
 	msg.Status = STATUS_NEW
 	msg.Filter = false
+	msg.Snapshot = nil
+	msg.Finished = false
+	msg.Partial = false
+	msg.RowKey = ""
 	msg.Content.reset()
 }
 
@@ -0,0 +1,256 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// window collapses changes of the same row into one net change.
+// Messages are added after the record check, the position of the last message added is recorded
+// after the net changes are sent
+type window struct {
+	interval time.Duration
+	count    int
+	tables   *tool.Tables
+	// keys keys in order of their first change, changes of a key is nil if they cancel each other out
+	keys    []string
+	changes map[string]*message2.Message
+	rows    int
+	start   time.Time
+	// end filtered message carrying position and time of the last message added
+	end *message2.Message
+}
+
+func newWindow(c *pipeline.Coalesce) (w *window, err error) {
+	w = &window{count: c.Count, changes: map[string]*message2.Message{}}
+	interval := c.Interval
+	if interval == 0 {
+		interval = pipeline.COALESCE_INTERVAL
+	}
+	w.interval = time.Duration(interval) * time.Millisecond
+	w.tables, err = tool.NewTables(c.Rule, c.Syntax)
+	return
+}
+
+// accept returns true if message can be added to window, filtered messages are accepted
+func (w *window) accept(msg *message2.Message) bool {
+	if msg.Snapshot != nil || msg.Finished || msg.Partial || msg.IsSnapshot() {
+		return false
+	}
+	return msg.Filter || w.key(msg)
+}
+
+// add adds message accepted by window, only positions of filtered messages are kept
+func (w *window) add(msg *message2.Message) {
+	if w.end == nil {
+		w.end = message2.Get()
+		w.end.Filter = true
+		w.start = time.Now()
+	}
+	w.end.Content.Head.Position = msg.Content.Head.Position
+	w.end.Content.Head.Time = msg.Content.Head.Time
+	if msg.Filter {
+		message2.Put(msg)
+		return
+	}
+	w.rows++
+	prev, ok := w.changes[msg.RowKey]
+	if !ok {
+		w.keys = append(w.keys, msg.RowKey)
+	}
+	w.changes[msg.RowKey] = merge(prev, msg)
+}
+
+// key returns true if message is a row change keyed by filter and its table is coalesced
+func (w *window) key(msg *message2.Message) bool {
+	head := msg.Content.Head
+	if msg.RowKey == "" || !w.tables.Match(head.Database, head.Table) {
+		return false
+	}
+	switch msg.Content.Data.(type) {
+	case message2.Insert, message2.Update, message2.Delete:
+		{
+			return true
+		}
+	}
+	return false
+}
+
+// merge returns net change of prev and msg, nil if they cancel each other out.
+// Columns of both changes are kept, since updates may carry changed columns only
+func merge(prev *message2.Message, msg *message2.Message) *message2.Message {
+	if prev == nil {
+		return msg
+	}
+	defer message2.Put(prev)
+	switch p := prev.Content.Data.(type) {
+	case message2.Insert:
+		{
+			switch d := msg.Content.Data.(type) {
+			case message2.Update:
+				{
+					// the row is new, so it is still an insert with the final values
+					msg.Content.Head.Type = message2.TYPE_INSERT.String()
+					msg.Content.Data = message2.Insert{
+						New:     overlay(p.New, d.New),
+						Missing: missing(p.New, d.New, p.Missing, d.NewMissing),
+					}
+				}
+			case message2.Delete:
+				{
+					message2.Put(msg)
+					return nil
+				}
+			}
+		}
+	case message2.Update:
+		{
+			switch d := msg.Content.Data.(type) {
+			case message2.Update:
+				{
+					// old values are the earliest seen, new values the latest seen
+					old := overlay(d.Old, p.Old)
+					new := overlay(p.New, d.New)
+					msg.Content.Data = message2.Update{
+						Old:        old,
+						New:        new,
+						Changed:    changed(old, new, p.Changed, d.Changed),
+						OldMissing: missing(d.Old, p.Old, d.OldMissing, p.OldMissing),
+						NewMissing: missing(p.New, d.New, p.NewMissing, d.NewMissing),
+					}
+				}
+			case message2.Delete:
+				{
+					msg.Content.Data = message2.Delete{
+						Old:     overlay(d.Old, p.Old),
+						Missing: missing(d.Old, p.Old, d.Missing, p.OldMissing),
+					}
+				}
+			}
+		}
+	case message2.Delete:
+		{
+			if d, ok := msg.Content.Data.(message2.Insert); ok {
+				// the row is deleted and inserted again
+				msg.Content.Head.Type = message2.TYPE_UPDATE.String()
+				msg.Content.Data = message2.Update{
+					Old:        p.Old,
+					New:        d.New,
+					Changed:    changed(p.Old, d.New, nil, nil),
+					OldMissing: p.Missing,
+					NewMissing: d.Missing,
+				}
+			}
+		}
+	}
+	return msg
+}
+
+// overlay returns columns of base overlaid by columns of top
+func overlay(base map[string]interface{}, top map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(base)+len(top))
+	for k, v := range base {
+		res[k] = v
+	}
+	for k, v := range top {
+		res[k] = v
+	}
+	return res
+}
+
+// missing returns absent columns of lists which are in neither base nor top
+func missing(base map[string]interface{}, top map[string]interface{}, lists ...[]string) (res []string) {
+	seen := map[string]bool{}
+	for _, list := range lists {
+		for _, v := range list {
+			if _, ok := base[v]; ok {
+				continue
+			}
+			if _, ok := top[v]; ok || seen[v] {
+				continue
+			}
+			seen[v] = true
+			res = append(res, v)
+		}
+	}
+	return
+}
+
+// changed returns columns whose values differ between old and new, columns in changed lists of
+// updates are kept in case their values are not comparable
+func changed(old map[string]interface{}, new map[string]interface{}, lists ...[]string) []string {
+	res := []string{}
+	seen := map[string]bool{}
+	for _, list := range lists {
+		for _, v := range list {
+			if !seen[v] {
+				seen[v] = true
+				res = append(res, v)
+			}
+		}
+	}
+	var diff []string
+	for k, v := range new {
+		if seen[k] {
+			continue
+		}
+		if o, ok := old[k]; !ok || !equal(o, v) {
+			diff = append(diff, k)
+		}
+	}
+	sort.Strings(diff)
+	return append(res, diff...)
+}
+
+func equal(a interface{}, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+// full returns true if count of window is reached
+func (w *window) full() bool {
+	return w.count > 0 && w.rows >= w.count
+}
+
+// expired returns true if interval of window is reached
+func (w *window) expired() bool {
+	return w.end != nil && time.Since(w.start) >= w.interval
+}
+
+// drain returns net changes in order of their keys and the end message, window is empty after it
+func (w *window) drain() (msgs []*message2.Message, end *message2.Message) {
+	for _, k := range w.keys {
+		if m := w.changes[k]; m != nil {
+			msgs = append(msgs, m)
+		}
+	}
+	end = w.end
+	w.keys, w.changes, w.rows, w.end = nil, map[string]*message2.Message{}, 0, nil
+	return
+}
+
+// flush sends net changes of window without recording their positions, then records the end position
+func (o *Output) flush(ctx context.Context) (err error) {
+	msgs, end := o.window.drain()
+	if end == nil {
+		return
+	}
+	for i, m := range msgs {
+		m.Partial = true
+		if err = o.deliver(ctx, m); err != nil {
+			for _, v := range msgs[i+1:] {
+				message2.Put(v)
+			}
+			message2.Put(end)
+			return
+		}
+	}
+	return o.deliver(ctx, end)
+}
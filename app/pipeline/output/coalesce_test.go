@@ -0,0 +1,183 @@
+package output
+
+import (
+	"fmt"
+	"testing"
+
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/app/pipeline/transform"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func coalesceMessage(id int, consume int, data interface{}) *message2.Message {
+	msg := message2.New()
+	msg.Content.Head.Database = "shop"
+	msg.Content.Head.Table = "counter"
+	msg.Content.Head.PK = &message2.PK{Columns: []string{"id"}, Values: []interface{}{id}}
+	msg.Content.Head.Position = pipeline.Position{BinlogFile: "mysql-bin.000001", BinlogPosition: 100, TotalRows: 10, ConsumeRows: consume}
+	msg.Content.Data = data
+	msg.RowKey = fmt.Sprintf("shop.counter:[%d]", id)
+	switch data.(type) {
+	case message2.Insert:
+		{
+			msg.Content.Head.Type = message2.TYPE_INSERT.String()
+		}
+	case message2.Update:
+		{
+			msg.Content.Head.Type = message2.TYPE_UPDATE.String()
+		}
+	case message2.Delete:
+		{
+			msg.Content.Head.Type = message2.TYPE_DELETE.String()
+		}
+	}
+	return msg
+}
+
+func TestWindow(t *testing.T) {
+	w, err := newWindow(&pipeline.Coalesce{Rule: "shop.counter", Count: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := func(n int) map[string]interface{} {
+		return map[string]interface{}{"id": 1, "n": n}
+	}
+	adds := []*message2.Message{
+		// insert and update of 1 are an insert with final values
+		coalesceMessage(1, 1, message2.Insert{New: row(0)}),
+		coalesceMessage(1, 2, message2.Update{Old: row(0), New: row(1), Changed: []string{"n"}}),
+		// insert and delete of 2 are nothing
+		coalesceMessage(2, 3, message2.Insert{New: map[string]interface{}{"id": 2}}),
+		coalesceMessage(2, 4, message2.Delete{Old: map[string]interface{}{"id": 2}}),
+		// updates of 3 are one update
+		coalesceMessage(3, 5, message2.Update{Old: map[string]interface{}{"id": 3, "n": 0, "m": 0}, New: map[string]interface{}{"id": 3, "n": 1, "m": 0}, Changed: []string{"n"}}),
+		coalesceMessage(3, 6, message2.Update{Old: map[string]interface{}{"id": 3, "n": 1, "m": 0}, New: map[string]interface{}{"id": 3, "n": 1, "m": 1}, Changed: []string{"m"}}),
+		coalesceMessage(1, 7, message2.Update{Old: row(1), New: row(2), Changed: []string{"n"}}),
+	}
+	for _, v := range adds {
+		if !w.accept(v) {
+			t.Fatal(v)
+		}
+		w.add(v)
+	}
+	filtered := coalesceMessage(4, 8, message2.Insert{})
+	filtered.Filter = true
+	if !w.accept(filtered) {
+		t.Fail()
+	}
+	w.add(filtered)
+	other := coalesceMessage(5, 9, message2.Insert{})
+	other.Content.Head.Table = "order"
+	if w.accept(other) {
+		t.Fail()
+	}
+	// rows not keyed by filter are not coalesced
+	unkeyed := coalesceMessage(5, 9, message2.Insert{})
+	unkeyed.RowKey = ""
+	if w.accept(unkeyed) {
+		t.Fail()
+	}
+	msgs, end := w.drain()
+	if len(msgs) != 2 || end == nil || !end.Filter || end.Content.Head.Position.ConsumeRows != 8 {
+		t.Fatal(msgs, end)
+	}
+	insert, ok := msgs[0].Content.Data.(message2.Insert)
+	if !ok || msgs[0].Content.Head.Type != message2.TYPE_INSERT.String() || insert.New["n"] != 2 {
+		t.Error(msgs[0].Content)
+	}
+	update, ok := msgs[1].Content.Data.(message2.Update)
+	if !ok || update.Old["n"] != 0 || update.New["m"] != 1 || len(update.Changed) != 2 {
+		t.Error(msgs[1].Content)
+	}
+	if msgs, end = w.drain(); len(msgs) != 0 || end != nil {
+		t.Error(msgs, end)
+	}
+}
+
+func TestMergeDeleteInsert(t *testing.T) {
+	prev := coalesceMessage(1, 1, message2.Delete{Old: map[string]interface{}{"id": 1, "n": 1}})
+	msg := coalesceMessage(1, 2, message2.Insert{New: map[string]interface{}{"id": 1, "n": 2}})
+	res := merge(prev, msg)
+	update, ok := res.Content.Data.(message2.Update)
+	if !ok || res.Content.Head.Type != message2.TYPE_UPDATE.String() || len(update.Changed) != 1 || update.Changed[0] != "n" {
+		t.Error(res.Content)
+	}
+}
+
+func TestWindowShards(t *testing.T) {
+	w, err := newWindow(&pipeline.Coalesce{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rows of physical shards routed to one logical table have the same primary key in head
+	for i, shard := range []string{"counter_0", "counter_1"} {
+		msg := coalesceMessage(1, i+1, message2.Insert{New: map[string]interface{}{"id": 1}})
+		msg.Content.Head.Origin = &message2.Origin{Database: "shop", Table: shard}
+		msg.RowKey = "shop." + shard + ":[1]"
+		w.add(msg)
+	}
+	if msgs, _ := w.drain(); len(msgs) != 2 {
+		t.Error(msgs)
+	}
+}
+
+func TestMergeChangedOnly(t *testing.T) {
+	// updates carry changed columns and primary key only
+	prev := coalesceMessage(1, 1, message2.Update{Old: map[string]interface{}{"id": 1, "a": 0}, New: map[string]interface{}{"id": 1, "a": 1}, Changed: []string{"a"}})
+	msg := coalesceMessage(1, 2, message2.Update{Old: map[string]interface{}{"id": 1, "b": 0}, New: map[string]interface{}{"id": 1, "b": 1}, Changed: []string{"b"}})
+	update, ok := merge(prev, msg).Content.Data.(message2.Update)
+	if !ok || update.Old["a"] != 0 || update.Old["b"] != 0 || update.New["a"] != 1 || update.New["b"] != 1 || len(update.Changed) != 2 {
+		t.Error(update)
+	}
+
+	prev = coalesceMessage(1, 1, message2.Insert{New: map[string]interface{}{"id": 1, "a": 0, "b": 0}})
+	msg = coalesceMessage(1, 2, message2.Update{Old: map[string]interface{}{"id": 1, "b": 0}, New: map[string]interface{}{"id": 1, "b": 1}, Changed: []string{"b"}})
+	insert, ok := merge(prev, msg).Content.Data.(message2.Insert)
+	if !ok || len(insert.New) != 3 || insert.New["a"] != 0 || insert.New["b"] != 1 {
+		t.Error(insert)
+	}
+
+	prev = coalesceMessage(1, 1, message2.Update{Old: map[string]interface{}{"id": 1, "a": 0}, New: map[string]interface{}{"id": 1, "a": 1}, Changed: []string{"a"}})
+	msg = coalesceMessage(1, 2, message2.Delete{Old: map[string]interface{}{"id": 1, "a": 1, "b": 0}})
+	del, ok := merge(prev, msg).Content.Data.(message2.Delete)
+	if !ok || del.Old["a"] != 0 || del.Old["b"] != 0 {
+		t.Error(del)
+	}
+}
+
+func TestWindowScript(t *testing.T) {
+	w, err := newWindow(&pipeline.Coalesce{Rule: "shop.counter"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &pipeline.Script{Source: `
+def transform(content):
+    content["data"]["new"]["name"] = content["data"]["new"]["name"].upper()
+    return content
+`}
+	prev := coalesceMessage(1, 1, message2.Insert{New: map[string]interface{}{"id": 1, "name": "roy"}})
+	update := coalesceMessage(1, 2, message2.Update{
+		Old:     map[string]interface{}{"id": 1, "name": "roy"},
+		New:     map[string]interface{}{"id": 1, "name": "bob"},
+		Changed: []string{"name"},
+	})
+	contents, _, err := transform.RunScript(s, update.Content)
+	if err != nil || len(contents) != 1 {
+		t.Fatal(contents, err)
+	}
+	// update modified by script is still an update, it is coalesced with the insert of the row
+	update.Content = contents[0]
+	for _, v := range []*message2.Message{prev, update} {
+		if !w.accept(v) {
+			t.Fatal(v.Content)
+		}
+		w.add(v)
+	}
+	msgs, _ := w.drain()
+	if len(msgs) != 1 {
+		t.Fatal(msgs)
+	}
+	if insert, ok := msgs[0].Content.Data.(message2.Insert); !ok || insert.New["name"] != "BOB" {
+		t.Error(msgs[0].Content)
+	}
+}
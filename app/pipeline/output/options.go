@@ -9,6 +9,7 @@ type Options struct {
 	Output       *pipeline.Output
 	PipelineName string
 	MysqlMode    pipeline.Mode
+	Coalesce     *pipeline.Coalesce
 }
 
 // Option is a function for configure Options
@@ -34,3 +35,10 @@ func OptionMysqlMode(mode pipeline.Mode) Option {
 		options.MysqlMode = mode
 	}
 }
+
+// OptionCoalesce sets coalescing of changes
+func OptionCoalesce(c *pipeline.Coalesce) Option {
+	return func(options *Options) {
+		options.Coalesce = c
+	}
+}
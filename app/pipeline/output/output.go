@@ -3,6 +3,8 @@ package output
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	message2 "github.com/jin06/binlogo/app/pipeline/message"
@@ -15,6 +17,7 @@ import (
 	stdout2 "github.com/jin06/binlogo/app/pipeline/output/sender/stdout"
 	"github.com/jin06/binlogo/configs"
 	"github.com/jin06/binlogo/pkg/event"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
 	"github.com/jin06/binlogo/pkg/promeths"
 	"github.com/jin06/binlogo/pkg/store/dao/dao_pipe"
 	event2 "github.com/jin06/binlogo/pkg/store/model/event"
@@ -24,12 +27,21 @@ import (
 
 // Output handle message output
 // depends pipeline config, send message to stdout、kafka, etc.
+// A message may be sent to several senders by their routes
 type Output struct {
 	InChan  chan *message2.Message
-	Sender  sender2.Sender
 	Options *Options
 	ctx     context.Context
+	senders []*namedSender
 	record  *pipeline.RecordPosition
+	// splitPass result of record check of the first part of a split message, the other parts follow it
+	splitPass *bool
+	// window coalescing window, nil without coalescing
+	window *window
+	// checkpoint position of the last recorded transaction and time of its event
+	checkpoint      pipeline.Position
+	checkpointTime  uint32
+	checkpointMutex sync.Mutex
 }
 
 // New return a Output object
@@ -44,30 +56,94 @@ func New(opts ...Option) (out *Output, err error) {
 	return
 }
 
+// namedSender sender of output with routes of messages sent to it
+type namedSender struct {
+	name   string
+	sender sender2.Sender
+	routes []*tool.SenderRoute
+}
+
 func (o *Output) init() (err error) {
-	switch o.Options.Output.Sender.Type {
+	o.window = nil
+	if o.Options.Coalesce != nil {
+		if o.window, err = newWindow(o.Options.Coalesce); err != nil {
+			return
+		}
+	}
+	o.senders = nil
+	list := o.Options.Output.SenderList()
+	if len(list) == 0 {
+		return errors.New("output has no sender")
+	}
+	for _, v := range list {
+		s := &namedSender{name: v.Name}
+		if s.name == "" {
+			s.name = v.Type
+		}
+		if s.sender, err = newSender(v); err != nil {
+			return
+		}
+		if s.routes, err = tool.NewSenderRoutes(v.Routes); err != nil {
+			err = fmt.Errorf("sender %s: %v", s.name, err)
+			return
+		}
+		o.senders = append(o.senders, s)
+	}
+	return
+}
+
+func newSender(s *pipeline.Sender) (res sender2.Sender, err error) {
+	switch s.Type {
 	case pipeline.SNEDER_TYPE_STDOUT:
-		o.Sender, err = stdout2.New()
+		res, err = stdout2.New()
 	case pipeline.SNEDER_TYPE_HTTP:
-		o.Sender, err = http.New(o.Options.Output.Sender.Http)
+		res, err = http.New(s.Http)
 	case pipeline.SNEDER_TYPE_RABBITMQ:
-		o.Sender, err = rabbitmq.New(o.Options.Output.Sender.RabbitMQ)
+		res, err = rabbitmq.New(s.RabbitMQ)
 	case pipeline.SENDER_TYPE_REDIS:
-		o.Sender, err = redis.New(o.Options.Output.Sender.Redis)
+		res, err = redis.New(s.Redis)
 	case pipeline.SENDER_TYPE_KAFKA:
-		o.Sender, err = kafka2.New(o.Options.Output.Sender.Kafka)
+		res, err = kafka2.New(s.Kafka)
 	case pipeline.SENDER_TYPE_ROCKETMQ:
-		o.Sender, err = rocketmq.New(o.Options.Output.Sender.RocketMQ)
+		res, err = rocketmq.New(s.RocketMQ)
 	default:
-		o.Sender, err = stdout2.New()
+		res, err = stdout2.New()
 	}
-
 	return
 }
 
+// match returns true if message is routed to sender, a transaction is routed if any of its changes is routed
+func (s *namedSender) match(msg *message2.Message) bool {
+	if len(s.routes) == 0 {
+		return true
+	}
+	if trx, ok := msg.Content.Data.(message2.Transaction); ok {
+		for _, v := range trx.Changes {
+			if s.matchEvent(v.Database, v.Table, v.Type) {
+				return true
+			}
+		}
+		return false
+	}
+	head := msg.Content.Head
+	return s.matchEvent(head.Database, head.Table, head.Type)
+}
+
+func (s *namedSender) matchEvent(database string, table string, messageType string) bool {
+	event := tool.MessageEvent(messageType)
+	for _, r := range s.routes {
+		if r.Match(database, table, event) {
+			return true
+		}
+	}
+	return false
+}
+
 func (o *Output) loopHandle(ctx context.Context, msg *message2.Message) (err error) {
+	// senders acknowledged the message are not sent again when retrying
+	acked := map[int]bool{}
 	for i := 0; i < 3; i++ {
-		err = o.handle(msg)
+		err = o.handle(msg, acked)
 		if err != nil {
 			promeths.MessageSendErrCounter.With(prometheus.Labels{"pipeline": o.Options.PipelineName, "node": configs.NodeName}).Inc()
 		} else {
@@ -88,7 +164,7 @@ func (o *Output) loopHandle(ctx context.Context, msg *message2.Message) (err err
 	return
 }
 
-func (o *Output) handle(msg *message2.Message) (err error) {
+func (o *Output) handle(msg *message2.Message, acked map[int]bool) (err error) {
 	defer func() {
 		if err != nil {
 			event.Event(event2.NewErrorPipeline(o.Options.PipelineName, "send message error: "+err.Error()))
@@ -101,7 +177,7 @@ func (o *Output) handle(msg *message2.Message) (err error) {
 		}
 	case message2.STATUS_SEND:
 		{
-			err = o.syncRecord()
+			err = o.sync(msg)
 			if err == nil {
 				msg.Status = message2.STATUS_RECORD
 			}
@@ -109,19 +185,13 @@ func (o *Output) handle(msg *message2.Message) (err error) {
 		}
 	default:
 		if !msg.Filter {
-			var ok bool
-			ok, err = o.Sender.Send(msg)
-			if err == nil {
-				if !ok {
-					err = errors.New("send message failed")
-				}
-			}
+			err = o.send(msg, acked)
 			if err == nil {
 				msg.Status = message2.STATUS_SEND
 			}
 		}
 		if err == nil {
-			err = o.syncRecord()
+			err = o.sync(msg)
 			if err == nil {
 				msg.Status = message2.STATUS_RECORD
 			}
@@ -130,6 +200,72 @@ func (o *Output) handle(msg *message2.Message) (err error) {
 	}
 }
 
+// send sends message to every sender it is routed to, indexes of senders acknowledged it are added to acked.
+// The message is recorded only after all the senders acknowledged it
+func (o *Output) send(msg *message2.Message, acked map[int]bool) (err error) {
+	for i, s := range o.senders {
+		if acked[i] || !s.match(msg) {
+			continue
+		}
+		var ok bool
+		ok, err = s.sender.Send(msg)
+		if err == nil && !ok {
+			err = errors.New("send message failed")
+		}
+		if err != nil {
+			if len(o.senders) > 1 {
+				err = fmt.Errorf("sender %s: %v", s.name, err)
+			}
+			return
+		}
+		acked[i] = true
+	}
+	return
+}
+
+// sync records the progress after message is handled.
+// snapshot messages record snapshot progress instead of binlog position,
+// finished message finishes the pipeline after all messages before it are recorded,
+// position of a split message is recorded by its last part
+func (o *Output) sync(msg *message2.Message) (err error) {
+	if msg.Finished {
+		return o.finish()
+	}
+	if msg.Snapshot != nil {
+		return dao_pipe.UpdateSnapshot(msg.Snapshot)
+	}
+	if msg.IsSnapshot() || msg.Partial {
+		return
+	}
+	if err = o.syncRecord(); err != nil {
+		return
+	}
+	if msg.Content.Head.Position.TotalRows == msg.Content.Head.Position.ConsumeRows {
+		o.checkpointMutex.Lock()
+		o.checkpoint = msg.Content.Head.Position
+		o.checkpointTime = msg.Content.Head.Time
+		o.checkpointMutex.Unlock()
+	}
+	return
+}
+
+// Checkpoint returns position of the last recorded transaction and time of its event, time is 0 if nothing is recorded
+func (o *Output) Checkpoint() (pos pipeline.Position, t uint32) {
+	o.checkpointMutex.Lock()
+	defer o.checkpointMutex.Unlock()
+	return o.checkpoint, o.checkpointTime
+}
+
+// finish flips the pipeline status to finished, then the pipeline is stopped by scheduler
+func (o *Output) finish() (err error) {
+	_, err = dao_pipe.UpdatePipeline(o.Options.PipelineName, pipeline.WithPipeStatus(pipeline.STATUS_FINISHED))
+	if err != nil {
+		return
+	}
+	event.Event(event2.NewInfoPipeline(o.Options.PipelineName, "Pipeline finished, end condition reached"))
+	return
+}
+
 // Run start Output to send message
 func (o *Output) Run(ctx context.Context) (err error) {
 	err = o.init()
@@ -142,31 +278,61 @@ func (o *Output) Run(ctx context.Context) (err error) {
 		defer func() {
 			cancel()
 		}()
+		// tick is nil without coalescing, so it never fires
+		var tick <-chan time.Time
+		if o.window != nil {
+			ticker := time.NewTicker(o.window.interval / 4)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
 		for {
 			select {
 			case <-ctx.Done():
 				{
 					return
 				}
+			case <-tick:
+				{
+					if o.window.expired() {
+						if err1 := o.flush(ctx); err1 != nil {
+							return
+						}
+					}
+				}
 			case msg := <-o.InChan:
 				{
-					check, errPrepare := o.prepareRecord(msg)
-					if errPrepare != nil {
-						message2.Put(msg)
-						return
+					coalesce := o.window != nil && o.window.accept(msg)
+					if o.window != nil && !coalesce {
+						// messages can not be coalesced are sent after the window, so the order of positions is kept.
+						// The window is flushed before the record check, so its end is recorded with its own position
+						if err1 := o.flush(ctx); err1 != nil {
+							message2.Put(msg)
+							return
+						}
+					}
+					if !msg.IsSnapshot() && !msg.Finished {
+						check, errPrepare := o.checkRecord(msg)
+						if errPrepare != nil {
+							message2.Put(msg)
+							return
+						}
+						if !check {
+							message2.Put(msg)
+							continue
+						}
 					}
-					if !check {
-						message2.Put(msg)
+					if coalesce {
+						o.window.add(msg)
+						if o.window.full() {
+							if err1 := o.flush(ctx); err1 != nil {
+								return
+							}
+						}
 						continue
 					}
-					if err1 := o.loopHandle(ctx, msg); err1 != nil {
-						message2.Put(msg)
+					if err1 := o.deliver(ctx, msg); err1 != nil {
 						return
 					}
-					promeths.MessageSendCounter.With(prometheus.Labels{"pipeline": o.Options.PipelineName, "node": configs.NodeName}).Inc()
-					pass := uint32(time.Now().Unix()) - msg.Content.Head.Time
-					promeths.MessageSendHistogram.With(prometheus.Labels{"pipeline": o.Options.PipelineName, "node": configs.NodeName}).Observe(float64(pass))
-					message2.Put(msg)
 				}
 			}
 		}
@@ -174,11 +340,40 @@ func (o *Output) Run(ctx context.Context) (err error) {
 	return
 }
 
+// deliver handles message and puts it back to pool
+func (o *Output) deliver(ctx context.Context, msg *message2.Message) (err error) {
+	defer message2.Put(msg)
+	if err = o.loopHandle(ctx, msg); err != nil {
+		return
+	}
+	promeths.MessageSendCounter.With(prometheus.Labels{"pipeline": o.Options.PipelineName, "node": configs.NodeName}).Inc()
+	pass := uint32(time.Now().Unix()) - msg.Content.Head.Time
+	promeths.MessageSendHistogram.With(prometheus.Labels{"pipeline": o.Options.PipelineName, "node": configs.NodeName}).Observe(float64(pass))
+	return
+}
+
 // Context return Output's context
 func (o *Output) Context() context.Context {
 	return o.ctx
 }
 
+// checkRecord checks message against the record, all parts of a split message share one check,
+// since they have the same position
+func (o *Output) checkRecord(msg *message2.Message) (pass bool, err error) {
+	if o.splitPass != nil {
+		pass = *o.splitPass
+		if !msg.Partial {
+			o.splitPass = nil
+		}
+		return
+	}
+	pass, err = o.prepareRecord(msg)
+	if err == nil && msg.Partial {
+		o.splitPass = &pass
+	}
+	return
+}
+
 func (o *Output) prepareRecord(msg *message2.Message) (pass bool, err error) {
 	pass = true
 	if o.record == nil {
@@ -2,7 +2,11 @@ package output
 
 import (
 	"context"
+	"errors"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/app/pipeline/transform"
 	"github.com/jin06/binlogo/configs"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
 	"github.com/jin06/binlogo/pkg/store/model/pipeline"
 	"testing"
 	"time"
@@ -72,3 +76,112 @@ func TestOutput(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// fakeSender records sent messages, it fails while fail is above 0
+type fakeSender struct {
+	sent int
+	fail int
+}
+
+func (s *fakeSender) Send(msg *message2.Message) (bool, error) {
+	if s.fail > 0 {
+		s.fail--
+		return false, errors.New("unavailable")
+	}
+	s.sent++
+	return true, nil
+}
+
+func TestSend(t *testing.T) {
+	routes, err := tool.NewSenderRoutes([]*pipeline.SenderRoute{{Rule: "user.*", Syntax: pipeline.FILTER_SYNTAX_GLOB}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	billingRoutes, err := tool.NewSenderRoutes([]*pipeline.SenderRoute{{Rule: "billing", Events: []string{pipeline.FILTER_EVENT_INSERT}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, billing, all := &fakeSender{}, &fakeSender{fail: 1}, &fakeSender{}
+	o := &Output{Options: &Options{PipelineName: "go_test_pipe"}, senders: []*namedSender{
+		{name: "user", sender: user, routes: routes},
+		{name: "billing", sender: billing, routes: billingRoutes},
+		{name: "all", sender: all},
+	}}
+	msg := message2.New()
+	msg.Content.Head.Type = message2.TYPE_INSERT.String()
+	msg.Content.Head.Database = "billing"
+	msg.Content.Head.Table = "invoice"
+	acked := map[int]bool{}
+	if err = o.send(msg, acked); err == nil {
+		t.Fail()
+	}
+	// acknowledged senders are not sent again
+	if err = o.send(msg, acked); err != nil {
+		t.Fatal(err)
+	}
+	if user.sent != 0 || billing.sent != 1 || all.sent != 1 {
+		t.Error(user.sent, billing.sent, all.sent)
+	}
+
+	msg.Content.Head.Type = message2.TYPE_UPDATE.String()
+	msg.Content.Data = message2.Transaction{Changes: []*message2.Change{
+		{Type: message2.TYPE_UPDATE.String(), Database: "billing", Table: "invoice"},
+		{Type: message2.TYPE_INSERT.String(), Database: "user", Table: "profile"},
+	}}
+	if err = o.send(msg, map[int]bool{}); err != nil {
+		t.Fatal(err)
+	}
+	if user.sent != 1 || billing.sent != 1 || all.sent != 2 {
+		t.Error(user.sent, billing.sent, all.sent)
+	}
+}
+
+func TestCheckRecordSplit(t *testing.T) {
+	o := &Output{Options: &Options{PipelineName: "go_test_pipe", MysqlMode: pipeline.MODE_POSITION}}
+	o.record = pipeline.NewRecordPosition(pipeline.WithPipelineName("go_test_pipe"))
+	*o.record.Now = pipeline.Position{BinlogFile: "mysql-bin.000001", BinlogPosition: 4, TotalRows: 1, ConsumeRows: 1}
+	// parts of the first row of an event split by script, all of them pass
+	for i, partial := range []bool{true, true, false} {
+		msg := message2.New()
+		msg.Partial = partial
+		msg.Content.Head.Position = pipeline.Position{BinlogFile: "mysql-bin.000001", BinlogPosition: 100, TotalRows: 3, ConsumeRows: 1}
+		pass, err := o.checkRecord(msg)
+		if err != nil || !pass {
+			t.Error(i, pass, err)
+		}
+	}
+	msg := message2.New()
+	msg.Content.Head.Position = pipeline.Position{BinlogFile: "mysql-bin.000001", BinlogPosition: 100, TotalRows: 3, ConsumeRows: 1}
+	if pass, _ := o.checkRecord(msg); pass {
+		t.Error("row is handled again")
+	}
+}
+
+func TestMatchScript(t *testing.T) {
+	routes, err := tool.NewSenderRoutes([]*pipeline.SenderRoute{{Rule: "shop.counter", Events: []string{pipeline.FILTER_EVENT_UPDATE}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &namedSender{name: "counter", routes: routes}
+	msg := message2.New()
+	msg.Content.Head.Type = message2.TYPE_TRANSACTION.String()
+	msg.Content.Data = message2.Transaction{GTID: "de278ad0-2106-11e4-9f8e-6edd0ca20947:1", Changes: []*message2.Change{
+		{Type: message2.TYPE_INSERT.String(), Database: "shop", Table: "order", Data: message2.Insert{New: map[string]interface{}{"id": 1}}},
+		{Type: message2.TYPE_UPDATE.String(), Database: "shop", Table: "counter", Data: message2.Update{
+			Old: map[string]interface{}{"id": 1, "n": 0}, New: map[string]interface{}{"id": 1, "n": 1}, Changed: []string{"n"},
+		}},
+	}}
+	contents, _, err := transform.RunScript(&pipeline.Script{Source: "def transform(content):\n    return content\n"}, msg.Content)
+	if err != nil || len(contents) != 1 {
+		t.Fatal(contents, err)
+	}
+	// transaction returned by script is routed by its changes
+	msg.Content = contents[0]
+	if !s.match(msg) {
+		t.Error(msg.Content)
+	}
+	trx := msg.Content.Data.(message2.Transaction)
+	if _, ok := trx.Changes[1].Data.(message2.Update); !ok {
+		t.Error(trx.Changes[1])
+	}
+}
@@ -41,9 +41,17 @@ func (r *Redis) Send(msg *message2.Message) (ok bool, err error) {
 	if err != nil {
 		return
 	}
-	err = r.Client.RPush(context.Background(), r.Redis.List, body).Err()
+	err = r.Client.RPush(context.Background(), r.list(msg), body).Err()
 	if err == nil {
 		ok = true
 	}
 	return
 }
+
+// list returns list of message, messages of a logical table are sent to the list of the logical table
+func (r *Redis) list(msg *message2.Message) string {
+	if !r.Redis.ListPerTable {
+		return r.Redis.List
+	}
+	return r.Redis.List + ":" + msg.Content.Head.Database + "." + msg.Content.Head.Table
+}
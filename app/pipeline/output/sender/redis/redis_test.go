@@ -27,3 +27,17 @@ func TestRedis(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestList(t *testing.T) {
+	r := &Redis{Redis: &pipeline.Redis{List: "binlogo"}}
+	msg := message2.New()
+	msg.Content.Head.Database = "mall"
+	msg.Content.Head.Table = "order"
+	if r.list(msg) != "binlogo" {
+		t.Error(r.list(msg))
+	}
+	r.Redis.ListPerTable = true
+	if r.list(msg) != "binlogo:mall.order" {
+		t.Error(r.list(msg))
+	}
+}
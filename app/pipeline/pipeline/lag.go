@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/jin06/binlogo/configs"
+	"github.com/jin06/binlogo/pkg/event"
+	"github.com/jin06/binlogo/pkg/promeths"
+	event2 "github.com/jin06/binlogo/pkg/store/model/event"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// LagInterval interval of checking replication lag
+const LagInterval = 10 * time.Second
+
+// lagTracker tracks replication lag, position of mysql master minus checkpointed position of output
+type lagTracker struct {
+	mutex sync.Mutex
+	lag   *pipeline.Lag
+	// caughtTime the last time checkpoint is seen at master, events not checkpointed yet are written after it
+	caughtTime time.Time
+	// behindSince time checkpoint is first seen behind master before it is caught up or any event is checkpointed
+	behindSince time.Time
+	// warned is true after lag exceeded threshold, until lag is under threshold again
+	warned bool
+}
+
+// trackLag checks master status of mysql and checkpoint of output, updates lag gauge and
+// raises a warn event when lag exceeds threshold of pipeline
+func (p *Pipeline) trackLag() {
+	status, err := p.Input.MasterStatus()
+	if err != nil {
+		logrus.Debugln("Get master status error: ", err)
+		return
+	}
+	master := &pipeline.Position{BinlogFile: status.File, BinlogPosition: status.Position, GTIDSet: status.GTIDSet}
+	checkpoint, eventTime := p.Output.Checkpoint()
+	syncedPos, syncedSet := p.Input.Synced()
+	synced := pipeline.Position{BinlogFile: syncedPos.Name, BinlogPosition: syncedPos.Pos}
+	if syncedSet != nil {
+		synced.GTIDSet = syncedSet.String()
+	}
+	// messages read from binlog may not produce checkpoint, e.g. statements other than rows and ddl,
+	// so input having read up to master with nothing in flight is caught up as well
+	idle := len(p.OutChan.Input) == 0 && len(p.OutChan.Filter) == 0 && len(p.OutChan.Transform) == 0
+	caught := caughtUp(p.Options.Pipeline.Mysql, master, &checkpoint) || (idle && caughtUp(p.Options.Pipeline.Mysql, master, &synced))
+	lag := p.lag.update(p.Input.Addr(), master, checkpoint, eventTime, caught, time.Now())
+	promeths.LagGauge.With(prometheus.Labels{"pipeline": p.Options.Pipeline.Name, "node": configs.NodeName}).Set(float64(lag.Seconds))
+	if p.lag.exceeded(lag.Seconds, p.Options.Pipeline.LagThreshold) {
+		event.Event(event2.NewWarnPipeline(p.Options.Pipeline.Name,
+			fmt.Sprintf("Replication lag %d seconds exceeds threshold %d seconds", lag.Seconds, p.Options.Pipeline.LagThreshold)))
+	}
+}
+
+// Lag returns the last replication lag, nil if unknown
+func (p *Pipeline) Lag() *pipeline.Lag {
+	p.lag.mutex.Lock()
+	defer p.lag.mutex.Unlock()
+	return p.lag.lag
+}
+
+// update returns lag at now. Lag is the age of the oldest event not checkpointed, it is written after
+// the checkpointed event and after the last time checkpoint is seen at master, so an idle source has no lag
+func (t *lagTracker) update(addr string, master *pipeline.Position, checkpoint pipeline.Position, eventTime uint32, caught bool, now time.Time) *pipeline.Lag {
+	lag := &pipeline.Lag{Address: addr, Master: master, Checkpoint: &checkpoint, UpdateTime: now}
+	if caught {
+		t.caughtTime = now
+		t.behindSince = time.Time{}
+	} else {
+		since := t.caughtTime
+		if eventTime > 0 {
+			if v := time.Unix(int64(eventTime), 0); v.After(since) {
+				since = v
+			}
+		}
+		if since.IsZero() {
+			if t.behindSince.IsZero() {
+				t.behindSince = now
+			}
+			since = t.behindSince
+		}
+		if lag.Seconds = int64(now.Sub(since).Seconds()); lag.Seconds < 0 {
+			lag.Seconds = 0
+		}
+	}
+	t.mutex.Lock()
+	t.lag = lag
+	t.mutex.Unlock()
+	return lag
+}
+
+// exceeded returns true once when seconds exceeds threshold, 0 threshold means no warning
+func (t *lagTracker) exceeded(seconds int64, threshold int) bool {
+	if threshold <= 0 || seconds <= int64(threshold) {
+		t.warned = false
+		return false
+	}
+	if t.warned {
+		return false
+	}
+	t.warned = true
+	return true
+}
+
+// caughtUp returns true if pos has reached master, by gtid set in gtid mode
+func caughtUp(m *pipeline.Mysql, master *pipeline.Position, pos *pipeline.Position) bool {
+	if m.Mode == pipeline.MODE_GTID && master.GTIDSet != "" && pos.GTIDSet != "" {
+		masterSet, err := mysql.ParseGTIDSet(m.Flavor.YaString(), master.GTIDSet)
+		if err != nil {
+			return false
+		}
+		set, err := mysql.ParseGTIDSet(m.Flavor.YaString(), pos.GTIDSet)
+		if err != nil {
+			return false
+		}
+		return set.Contain(masterSet)
+	}
+	if pos.BinlogFile == "" {
+		return false
+	}
+	current := mysql.Position{Name: pos.BinlogFile, Pos: pos.BinlogPosition}
+	return current.Compare(mysql.Position{Name: master.BinlogFile, Pos: master.BinlogPosition}) >= 0
+}
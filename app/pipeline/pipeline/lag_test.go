@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestCaughtUp(t *testing.T) {
+	m := &pipeline.Mysql{Mode: pipeline.MODE_POSITION, Flavor: pipeline.FLAVOR_MYSQL}
+	master := &pipeline.Position{BinlogFile: "mysql-bin.000002", BinlogPosition: 1000}
+	if caughtUp(m, master, &pipeline.Position{BinlogFile: "mysql-bin.000001", BinlogPosition: 2000}) {
+		t.Fail()
+	}
+	if !caughtUp(m, master, &pipeline.Position{BinlogFile: "mysql-bin.000002", BinlogPosition: 1000}) {
+		t.Fail()
+	}
+	if caughtUp(m, master, &pipeline.Position{}) {
+		t.Fail()
+	}
+	m.Mode = pipeline.MODE_GTID
+	master.GTIDSet = "045c649a-408d-11ec-ae21-0242ac110006:1-64"
+	if caughtUp(m, master, &pipeline.Position{GTIDSet: "045c649a-408d-11ec-ae21-0242ac110006:1-63"}) {
+		t.Fail()
+	}
+	if !caughtUp(m, master, &pipeline.Position{GTIDSet: "045c649a-408d-11ec-ae21-0242ac110006:1-64"}) {
+		t.Fail()
+	}
+}
+
+func TestLagTracker(t *testing.T) {
+	tracker := &lagTracker{}
+	now := time.Now()
+	master := &pipeline.Position{BinlogFile: "mysql-bin.000002", BinlogPosition: 1000}
+	if lag := tracker.update("", master, pipeline.Position{}, uint32(now.Unix())-30, false, now); lag.Seconds != 30 {
+		t.Fatal(lag.Seconds)
+	}
+	if lag := tracker.update("", master, pipeline.Position{}, 0, true, now); lag.Seconds != 0 {
+		t.Fail()
+	}
+	// source idle after the checkpointed event, lag counts from the last time caught up
+	if lag := tracker.update("", master, pipeline.Position{}, uint32(now.Unix())-3600, false, now.Add(10*time.Second)); lag.Seconds != 10 {
+		t.Fatal(lag.Seconds)
+	}
+
+	// nothing checkpointed, lag counts from the first time seen behind
+	tracker = &lagTracker{}
+	tracker.update("", master, pipeline.Position{}, 0, false, now)
+	if lag := tracker.update("", master, pipeline.Position{}, 0, false, now.Add(20*time.Second)); lag.Seconds != 20 {
+		t.Fatal(lag.Seconds)
+	}
+
+	if tracker.exceeded(100, 0) || tracker.exceeded(10, 60) {
+		t.Fail()
+	}
+	if !tracker.exceeded(100, 60) || tracker.exceeded(120, 60) {
+		t.Fail()
+	}
+	if tracker.exceeded(30, 60) || !tracker.exceeded(100, 60) {
+		t.Fail()
+	}
+}
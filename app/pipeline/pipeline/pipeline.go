@@ -4,28 +4,32 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	filter2 "github.com/jin06/binlogo/app/pipeline/filter"
 	input2 "github.com/jin06/binlogo/app/pipeline/input"
 	message2 "github.com/jin06/binlogo/app/pipeline/message"
 	output2 "github.com/jin06/binlogo/app/pipeline/output"
+	transform2 "github.com/jin06/binlogo/app/pipeline/transform"
 	"github.com/jin06/binlogo/pkg/event"
 	event2 "github.com/jin06/binlogo/pkg/store/model/event"
 	"github.com/sirupsen/logrus"
 )
 
 // Pipeline for handle message
-// contains input, filter, output
+// contains input, filter, transform, output
 type Pipeline struct {
-	Input    *input2.Input
-	Output   *output2.Output
-	Filter   *filter2.Filter
-	OutChan  *OutChan
-	Options  Options
-	cancel   context.CancelFunc
-	runMutex sync.Mutex
-	status   status
-	ctx      context.Context
+	Input     *input2.Input
+	Output    *output2.Output
+	Filter    *filter2.Filter
+	Transform *transform2.Transform
+	OutChan   *OutChan
+	Options   Options
+	cancel    context.CancelFunc
+	runMutex  sync.Mutex
+	status    status
+	ctx       context.Context
+	lag       lagTracker
 }
 
 type status byte
@@ -52,9 +56,10 @@ func New(opt ...Option) (p *Pipeline, err error) {
 
 // OutChan  is used to deliver messages in different components
 type OutChan struct {
-	Input  chan *message2.Message
-	Filter chan *message2.Message
-	Out    chan *message2.Message
+	Input     chan *message2.Message
+	Filter    chan *message2.Message
+	Transform chan *message2.Message
+	Out       chan *message2.Message
 }
 
 func (p *Pipeline) init() (err error) {
@@ -74,6 +79,9 @@ func (p *Pipeline) init() (err error) {
 	if err = p.initFilter(); err != nil {
 		return
 	}
+	if err = p.initTransform(); err != nil {
+		return
+	}
 	if err = p.initOutput(); err != nil {
 		return
 	}
@@ -83,8 +91,9 @@ func (p *Pipeline) init() (err error) {
 func (p *Pipeline) initDataLine() {
 	capacity := 100000
 	p.OutChan = &OutChan{
-		Input:  make(chan *message2.Message, capacity),
-		Filter: make(chan *message2.Message, capacity),
+		Input:     make(chan *message2.Message, capacity),
+		Filter:    make(chan *message2.Message, capacity),
+		Transform: make(chan *message2.Message, capacity),
 	}
 }
 
@@ -104,13 +113,21 @@ func (p *Pipeline) initFilter() (err error) {
 	return
 }
 
+func (p *Pipeline) initTransform() (err error) {
+	p.Transform, err = transform2.New(transform2.WithPipe(p.Options.Pipeline))
+	p.Transform.InChan = p.OutChan.Filter
+	p.Transform.OutChan = p.OutChan.Transform
+	return
+}
+
 func (p *Pipeline) initOutput() (err error) {
 	p.Output, err = output2.New(
 		output2.OptionOutput(p.Options.Pipeline.Output),
 		output2.OptionPipeName(p.Options.Pipeline.Name),
 		output2.OptionMysqlMode(p.Options.Pipeline.Mysql.Mode),
+		output2.OptionCoalesce(p.Options.Pipeline.Coalesce),
 	)
-	p.Output.InChan = p.OutChan.Filter
+	p.Output.InChan = p.OutChan.Transform
 	return
 }
 
@@ -140,13 +157,23 @@ func (p *Pipeline) Run(ctx context.Context) {
 			event.Event(event2.NewErrorPipeline(p.Options.Pipeline.Name, "Start error: "+err.Error()))
 			return
 		}
+		if err = p.Transform.Run(myCtx); err != nil {
+			event.Event(event2.NewErrorPipeline(p.Options.Pipeline.Name, "Start error: "+err.Error()))
+			return
+		}
 		if err = p.Output.Run(myCtx); err != nil {
 			event.Event(event2.NewErrorPipeline(p.Options.Pipeline.Name, "Start error: "+err.Error()))
 			return
 		}
 		event.Event(event2.NewInfoPipeline(p.Options.Pipeline.Name, "Start succeeded"))
+		lagTicker := time.NewTicker(LagInterval)
+		defer lagTicker.Stop()
 		for {
 			select {
+			case <-lagTicker.C:
+				{
+					p.trackLag()
+				}
 			case <-ctx.Done():
 				{
 					return
@@ -159,6 +186,10 @@ func (p *Pipeline) Run(ctx context.Context) {
 				{
 					return
 				}
+			case <-p.Transform.Context().Done():
+				{
+					return
+				}
 			case <-p.Output.Context().Done():
 				{
 					return
@@ -0,0 +1,370 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+	"go.starlark.net/starlark"
+)
+
+// scriptFunc function called by script for each message
+const scriptFunc = "transform"
+
+// script compiled starlark script, globals are frozen after the script is loaded,
+// so messages do not share any state
+type script struct {
+	name     string
+	fn       starlark.Callable
+	maxSteps uint64
+	onError  pipeline.OnError
+	printer  func(msg string)
+}
+
+// CheckScript returns error if script can not be compiled
+func CheckScript(s *pipeline.Script) (err error) {
+	_, err = newScript("check", s, nil)
+	return
+}
+
+// RunScript compiles script and runs it on content, it returns contents after script and output of print
+func RunScript(s *pipeline.Script, content message2.Content) (contents []message2.Content, prints []string, err error) {
+	printer := func(msg string) {
+		prints = append(prints, msg)
+	}
+	var sc *script
+	if sc, err = newScript("test", s, printer); err != nil {
+		return
+	}
+	contents, err = sc.run(content)
+	return
+}
+
+// newScript compiles script and runs its top level statements once, printer receives output of print, it may be nil
+func newScript(name string, s *pipeline.Script, printer func(msg string)) (sc *script, err error) {
+	if s.Source == "" {
+		err = errors.New("source of script is empty")
+		return
+	}
+	sc = &script{name: name, maxSteps: s.MaxSteps, printer: printer}
+	if sc.maxSteps == 0 {
+		sc.maxSteps = pipeline.SCRIPT_MAX_STEPS
+	}
+	if sc.onError, err = onError(s.OnError); err != nil {
+		return
+	}
+	globals, err := starlark.ExecFile(sc.thread(), name+".star", s.Source, nil)
+	if err != nil {
+		err = fmt.Errorf("script: %v", err)
+		return
+	}
+	globals.Freeze()
+	fn, ok := globals[scriptFunc].(starlark.Callable)
+	if !ok {
+		err = fmt.Errorf("script does not define function %s(content)", scriptFunc)
+		return
+	}
+	sc.fn = fn
+	return
+}
+
+// thread returns a new thread with execution budget, threads have no load function, so scripts can not load modules
+func (sc *script) thread() *starlark.Thread {
+	t := &starlark.Thread{
+		Name: sc.name,
+		Print: func(_ *starlark.Thread, msg string) {
+			if sc.printer != nil {
+				sc.printer(msg)
+			}
+		},
+	}
+	t.SetMaxExecutionSteps(sc.maxSteps)
+	return t
+}
+
+// run calls script with content, the result is empty if the message is dropped.
+// Positions of contents are kept as before the script
+func (sc *script) run(content message2.Content) (res []message2.Content, err error) {
+	in, err := toStarlark(content)
+	if err != nil {
+		return
+	}
+	out, err := starlark.Call(sc.thread(), sc.fn, starlark.Tuple{in}, nil)
+	if err != nil {
+		return
+	}
+	var values []starlark.Value
+	switch val := out.(type) {
+	case starlark.NoneType:
+		{
+			return
+		}
+	case *starlark.Dict:
+		{
+			values = append(values, val)
+		}
+	case *starlark.List:
+		{
+			for i := 0; i < val.Len(); i++ {
+				values = append(values, val.Index(i))
+			}
+		}
+	default:
+		{
+			err = fmt.Errorf("%s returns %s, want dict, list or None", scriptFunc, out.Type())
+			return
+		}
+	}
+	for i, v := range values {
+		if _, ok := v.(*starlark.Dict); !ok {
+			err = fmt.Errorf("%s returns %s at index %d of list, want dict", scriptFunc, v.Type(), i)
+			return
+		}
+		var c message2.Content
+		if c, err = fromContent(v); err != nil {
+			return
+		}
+		c.Head.Position = content.Head.Position
+		res = append(res, c)
+	}
+	return
+}
+
+// toStarlark converts content to starlark values through its json form
+func toStarlark(content message2.Content) (v starlark.Value, err error) {
+	b, err := json.Marshal(content)
+	if err != nil {
+		return
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var val interface{}
+	if err = d.Decode(&val); err != nil {
+		return
+	}
+	return starlarkValue(val)
+}
+
+func starlarkValue(val interface{}) (v starlark.Value, err error) {
+	switch val := val.(type) {
+	case nil:
+		{
+			return starlark.None, nil
+		}
+	case bool:
+		{
+			return starlark.Bool(val), nil
+		}
+	case string:
+		{
+			return starlark.String(val), nil
+		}
+	case json.Number:
+		{
+			if i, ok := new(big.Int).SetString(val.String(), 10); ok {
+				return starlark.MakeBigInt(i), nil
+			}
+			var f float64
+			if f, err = strconv.ParseFloat(val.String(), 64); err != nil {
+				return
+			}
+			return starlark.Float(f), nil
+		}
+	case []interface{}:
+		{
+			list := make([]starlark.Value, len(val))
+			for i, item := range val {
+				if list[i], err = starlarkValue(item); err != nil {
+					return
+				}
+			}
+			return starlark.NewList(list), nil
+		}
+	case map[string]interface{}:
+		{
+			// keys are sorted, so scripts iterate dicts in the same order
+			keys := make([]string, 0, len(val))
+			for k := range val {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			dict := starlark.NewDict(len(val))
+			for _, k := range keys {
+				var item starlark.Value
+				if item, err = starlarkValue(val[k]); err != nil {
+					return
+				}
+				if err = dict.SetKey(starlark.String(k), item); err != nil {
+					return
+				}
+			}
+			return dict, nil
+		}
+	}
+	err = fmt.Errorf("value of type %T can not be converted", val)
+	return
+}
+
+// dataTypes types of data by type of head, data returned by script is converted back to them,
+// so messages are coalesced, routed and filtered the same as before the script
+var dataTypes = map[string]reflect.Type{
+	message2.TYPE_INSERT.String():         reflect.TypeOf(message2.Insert{}),
+	message2.TYPE_UPDATE.String():         reflect.TypeOf(message2.Update{}),
+	message2.TYPE_DELETE.String():         reflect.TypeOf(message2.Delete{}),
+	message2.TYPE_SNAPSHOT.String():       reflect.TypeOf(message2.Snapshot{}),
+	message2.TYPE_CREATE_TABLE.String():   reflect.TypeOf(message2.CreateTable{}),
+	message2.TYPE_ALTER_TABLE.String():    reflect.TypeOf(message2.AlterTable{}),
+	message2.TYPE_DROP_TABLE.String():     reflect.TypeOf(message2.DropTable{}),
+	message2.TYPE_RENAME_TABLE.String():   reflect.TypeOf(message2.RenameTable{}),
+	message2.TYPE_TRUNCATE_TABLE.String(): reflect.TypeOf(message2.TruncateTable{}),
+	message2.TYPE_DDL.String():            reflect.TypeOf(message2.DDL{}),
+	message2.TYPE_BEGIN.String():          reflect.TypeOf(message2.TransactionMarker{}),
+	message2.TYPE_COMMIT.String():         reflect.TypeOf(message2.TransactionMarker{}),
+}
+
+// fromContent converts dict returned by script to content
+func fromContent(v starlark.Value) (c message2.Content, err error) {
+	val, err := goValue(v)
+	if err != nil {
+		return
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return
+	}
+	raw := struct {
+		Head message2.Head   `json:"head"`
+		Data json.RawMessage `json:"data"`
+	}{}
+	if err = decode(b, &raw, false); err != nil {
+		err = fmt.Errorf("content returned by %s is invalid: %v", scriptFunc, err)
+		return
+	}
+	c.Head = raw.Head
+	c.Data, err = contentData(c.Head.Type, raw.Data)
+	return
+}
+
+// contentData converts data returned by script to the type of data of message type t.
+// Data not in the form of the type, e.g. a new structure built by script, is kept as json values
+func contentData(t string, b json.RawMessage) (data interface{}, err error) {
+	if len(b) == 0 {
+		return
+	}
+	if t == message2.TYPE_TRANSACTION.String() {
+		if trx, ok := transactionData(b); ok {
+			return trx, nil
+		}
+	} else if typ, ok := dataTypes[t]; ok {
+		typed := reflect.New(typ)
+		if decode(b, typed.Interface(), true) == nil {
+			return typed.Elem().Interface(), nil
+		}
+	}
+	err = decode(b, &data, false)
+	return
+}
+
+// transactionData converts data returned by script to transaction, data of each change is converted by its type
+func transactionData(b json.RawMessage) (trx message2.Transaction, ok bool) {
+	raw := struct {
+		GTID    string `json:"gtid"`
+		Changes []*struct {
+			message2.Change
+			Data json.RawMessage `json:"data"`
+		} `json:"changes"`
+	}{}
+	if decode(b, &raw, true) != nil {
+		return
+	}
+	trx = message2.Transaction{GTID: raw.GTID, Changes: make([]*message2.Change, len(raw.Changes))}
+	for i, v := range raw.Changes {
+		if v == nil {
+			return trx, false
+		}
+		change := v.Change
+		var err error
+		if change.Data, err = contentData(change.Type, v.Data); err != nil {
+			return trx, false
+		}
+		trx.Changes[i] = &change
+	}
+	return trx, true
+}
+
+// decode decodes json keeping numbers as json.Number, fields not in v are errors if strict
+func decode(b []byte, v interface{}, strict bool) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if strict {
+		d.DisallowUnknownFields()
+	}
+	return d.Decode(v)
+}
+
+func goValue(v starlark.Value) (val interface{}, err error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		{
+			return nil, nil
+		}
+	case starlark.Bool:
+		{
+			return bool(v), nil
+		}
+	case starlark.String:
+		{
+			return string(v), nil
+		}
+	case starlark.Int:
+		{
+			return json.Number(v.String()), nil
+		}
+	case starlark.Float:
+		{
+			return float64(v), nil
+		}
+	case *starlark.List:
+		{
+			return goValues(v)
+		}
+	case starlark.Tuple:
+		{
+			return goValues(v)
+		}
+	case *starlark.Dict:
+		{
+			res := make(map[string]interface{}, v.Len())
+			for _, item := range v.Items() {
+				k, ok := item[0].(starlark.String)
+				if !ok {
+					err = fmt.Errorf("dict key %s is not a string", item[0].String())
+					return
+				}
+				if res[string(k)], err = goValue(item[1]); err != nil {
+					return
+				}
+			}
+			return res, nil
+		}
+	}
+	err = fmt.Errorf("value of type %s can not be converted", v.Type())
+	return
+}
+
+func goValues(v starlark.Indexable) (res []interface{}, err error) {
+	res = make([]interface{}, v.Len())
+	for i := range res {
+		if res[i], err = goValue(v.Index(i)); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,157 @@
+package transform
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/promeths"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func scriptContent() message2.Content {
+	return message2.Content{
+		Head: message2.Head{
+			Type:     message2.TYPE_INSERT.String(),
+			Database: "mall",
+			Table:    "order",
+			Position: pipeline.Position{BinlogFile: "mysql-bin.000001", BinlogPosition: 100},
+		},
+		Data: message2.Insert{New: map[string]interface{}{"id": uint64(18446744073709551615), "items": "a,b"}},
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	s := &pipeline.Script{Source: `
+def transform(content):
+    row = content["data"]["new"]
+    print(content["head"]["table"])
+    if row["items"] == "":
+        return None
+    res = []
+    for item in row["items"].split(","):
+        c = {"head": dict(content["head"]), "data": {"id": row["id"], "item": item}}
+        c["head"]["position"] = {}
+        res.append(c)
+    return res
+`}
+	contents, prints, err := RunScript(s, scriptContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents) != 2 || len(prints) != 1 || prints[0] != "order" {
+		t.Fatal(contents, prints)
+	}
+	for i, item := range []string{"a", "b"} {
+		c := contents[i]
+		data := c.Data.(map[string]interface{})
+		// position is kept, big integers are kept
+		if c.Head.Position.BinlogPosition != 100 || data["item"] != item || data["id"] != json.Number("18446744073709551615") {
+			t.Error(c)
+		}
+	}
+
+	content := scriptContent()
+	content.Data = message2.Insert{New: map[string]interface{}{"items": ""}}
+	if contents, _, err = RunScript(s, content); err != nil || len(contents) != 0 {
+		t.Error(contents, err)
+	}
+}
+
+func TestScriptCheck(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"def transform(:",
+		"def other(content):\n    return content\n",
+		"load('x.star', 'f')\ndef transform(content):\n    return content\n",
+	} {
+		if err := CheckScript(&pipeline.Script{Source: src}); err == nil {
+			t.Error(src)
+		}
+	}
+	// globals are frozen, messages do not share state
+	s := &pipeline.Script{Source: "seen = []\ndef transform(content):\n    seen.append(1)\n    return content\n"}
+	if _, _, err := RunScript(s, scriptContent()); err == nil {
+		t.Fail()
+	}
+	s = &pipeline.Script{Source: "def transform(content):\n    for i in range(1000000):\n        pass\n    return content\n", MaxSteps: 1000}
+	if _, _, err := RunScript(s, scriptContent()); err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Error(err)
+	}
+	s = &pipeline.Script{Source: "def transform(content):\n    return 1\n"}
+	if _, _, err := RunScript(s, scriptContent()); err == nil {
+		t.Fail()
+	}
+}
+
+func TestTransformScript(t *testing.T) {
+	promeths.Init()
+	pipe := &pipeline.Pipeline{
+		Name: "test",
+		Script: &pipeline.Script{Source: `
+def transform(content):
+    if content["head"]["table"] == "log":
+        return None
+    if content["head"]["table"] == "bad":
+        fail("bad table")
+    return [content, content]
+`},
+	}
+	tr, err := New(WithPipe(pipe))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.init(); err != nil {
+		t.Fatal(err)
+	}
+	msg := message2.New()
+	msg.Content = scriptContent()
+	msgs := tr.runScript(msg)
+	if len(msgs) != 2 || !msgs[0].Partial || msgs[1].Partial || msgs[1] != msg {
+		t.Error(msgs)
+	}
+
+	msg = message2.New()
+	msg.Content = scriptContent()
+	msg.Content.Head.Table = "log"
+	if msgs = tr.runScript(msg); len(msgs) != 1 || !msgs[0].Filter {
+		t.Error(msgs)
+	}
+
+	// failed script drops the message by default
+	msg = message2.New()
+	msg.Content = scriptContent()
+	msg.Content.Head.Table = "bad"
+	if msgs = tr.runScript(msg); len(msgs) != 1 || !msgs[0].Filter {
+		t.Error(msgs)
+	}
+
+	pipe.Script.OnError = pipeline.ON_ERROR_STOP
+	if err = tr.init(); err != nil {
+		t.Fatal(err)
+	}
+	msg = message2.New()
+	msg.Content = scriptContent()
+	msg.Content.Head.Table = "bad"
+	if msgs = tr.runScript(msg); msgs != nil {
+		t.Error(msgs)
+	}
+
+	// skipped script passes the message as it was
+	pipe.Script.OnError = pipeline.ON_ERROR_SKIP
+	if err = tr.init(); err != nil {
+		t.Fatal(err)
+	}
+	if msgs = tr.runScript(msg); len(msgs) != 1 || msgs[0].Filter {
+		t.Error(msgs)
+	}
+	if _, ok := msgs[0].Content.Data.(message2.Insert); !ok {
+		t.Error(msgs[0].Content.Data)
+	}
+
+	pipe.Script.OnError = "retry"
+	if err = tr.init(); err == nil {
+		t.Fail()
+	}
+}
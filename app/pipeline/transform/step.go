@@ -0,0 +1,517 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// rowEvent event of a table transformed by steps, Data is one of Insert, Update, Delete and Snapshot,
+// or flat map after flatten. Other data such as ddl only has its table changed
+type rowEvent struct {
+	Database string
+	Table    string
+	Type     string
+	Time     uint32
+	PK       *message2.PK
+	Data     interface{}
+}
+
+// step one step of transform chain, an error leaves the event as it was before the step
+type step interface {
+	apply(e *rowEvent) error
+}
+
+// chainStep step with tables it applies to
+type chainStep struct {
+	index   int
+	conf    *pipeline.Transform
+	tables  *tool.Tables
+	step    step
+	onError pipeline.OnError
+}
+
+// onError returns on error action, ON_ERROR_DROP if it is empty
+func onError(o pipeline.OnError) (res pipeline.OnError, err error) {
+	switch o {
+	case "":
+		{
+			res = pipeline.ON_ERROR_DROP
+		}
+	case pipeline.ON_ERROR_DROP, pipeline.ON_ERROR_STOP, pipeline.ON_ERROR_SKIP:
+		{
+			res = o
+		}
+	default:
+		{
+			err = fmt.Errorf("on_error %s is not supported", o)
+		}
+	}
+	return
+}
+
+// Check returns error if transform step is illegal
+func Check(t *pipeline.Transform) (err error) {
+	_, err = newChainStep(0, t)
+	return
+}
+
+func newChainStep(index int, t *pipeline.Transform) (c *chainStep, err error) {
+	c = &chainStep{index: index, conf: t}
+	if c.onError, err = onError(t.OnError); err != nil {
+		return
+	}
+	if c.tables, err = tool.NewTables(t.Rule, t.Syntax); err != nil {
+		return
+	}
+	switch t.Type {
+	case pipeline.TRANSFORM_RENAME_COLUMNS:
+		{
+			c.step, err = newRenameColumns(t)
+		}
+	case pipeline.TRANSFORM_RENAME_TABLE:
+		{
+			c.step, err = newRenameTable(t)
+		}
+	case pipeline.TRANSFORM_ADD_FIELDS:
+		{
+			c.step, err = newAddFields(t)
+		}
+	case pipeline.TRANSFORM_DROP_FIELDS:
+		{
+			c.step, err = newDropFields(t)
+		}
+	case pipeline.TRANSFORM_CAST:
+		{
+			c.step, err = newCast(t)
+		}
+	case pipeline.TRANSFORM_FLATTEN:
+		{
+			c.step = newFlatten(t)
+		}
+	default:
+		{
+			err = fmt.Errorf("transform type %s is not supported", t.Type)
+		}
+	}
+	return
+}
+
+// renameColumns renames columns of rows, changed and missing columns and primary key
+type renameColumns struct {
+	names map[string]string
+}
+
+func newRenameColumns(t *pipeline.Transform) (s *renameColumns, err error) {
+	if len(t.Rename) == 0 {
+		err = errors.New("rename of rename_columns is empty")
+		return
+	}
+	for k, v := range t.Rename {
+		if k == "" || v == "" {
+			err = errors.New("column name of rename_columns is empty")
+			return
+		}
+	}
+	s = &renameColumns{names: t.Rename}
+	return
+}
+
+func (s *renameColumns) apply(e *rowEvent) error {
+	for _, m := range images(e.Data) {
+		// values are taken before set, so columns can be swapped
+		values := map[string]interface{}{}
+		for old := range s.names {
+			if v, ok := m[old]; ok {
+				values[old] = v
+				delete(m, old)
+			}
+		}
+		for old, v := range values {
+			m[s.names[old]] = v
+		}
+	}
+	rename := func(names []string) []string {
+		res := make([]string, len(names))
+		for i, v := range names {
+			if n, ok := s.names[v]; ok {
+				v = n
+			}
+			res[i] = v
+		}
+		return res
+	}
+	e.Data = eachNames(e.Data, rename)
+	if e.PK != nil {
+		e.PK = &message2.PK{Columns: rename(e.PK.Columns), Values: e.PK.Values}
+	}
+	return nil
+}
+
+// renameTable renames tables, tables renamed to the same name are merged
+type renameTable struct {
+	database string
+	table    string
+}
+
+func newRenameTable(t *pipeline.Transform) (s *renameTable, err error) {
+	if t.Database == "" && t.Table == "" {
+		err = errors.New("database and table of rename_table are empty")
+		return
+	}
+	s = &renameTable{database: t.Database, table: t.Table}
+	return
+}
+
+func (s *renameTable) apply(e *rowEvent) error {
+	if s.database != "" {
+		e.Database = s.database
+	}
+	if s.table != "" {
+		e.Table = s.table
+	}
+	return nil
+}
+
+// addFields adds static or computed fields to rows
+type addFields struct {
+	fields []*field
+}
+
+type field struct {
+	name  string
+	value interface{}
+	tmpl  *template.Template
+}
+
+// templateData data of template of computed field
+type templateData struct {
+	Database string
+	Table    string
+	Type     string
+	Time     uint32
+	Row      map[string]interface{}
+}
+
+func newAddFields(t *pipeline.Transform) (s *addFields, err error) {
+	if len(t.Fields) == 0 {
+		err = errors.New("fields of add_fields are empty")
+		return
+	}
+	s = &addFields{}
+	for _, v := range t.Fields {
+		if v.Name == "" {
+			err = errors.New("field name of add_fields is empty")
+			return
+		}
+		f := &field{name: v.Name, value: v.Value}
+		if v.Template != "" {
+			if f.tmpl, err = template.New(v.Name).Option("missingkey=zero").Parse(v.Template); err != nil {
+				err = fmt.Errorf("template of field %s is invalid: %v", v.Name, err)
+				return
+			}
+		}
+		s.fields = append(s.fields, f)
+	}
+	return
+}
+
+func (s *addFields) apply(e *rowEvent) error {
+	ims := images(e.Data)
+	values := make([]map[string]interface{}, len(ims))
+	for i, m := range ims {
+		values[i] = map[string]interface{}{}
+		for _, f := range s.fields {
+			if f.tmpl == nil {
+				values[i][f.name] = f.value
+				continue
+			}
+			var b strings.Builder
+			data := &templateData{Database: e.Database, Table: e.Table, Type: e.Type, Time: e.Time, Row: m}
+			if err := f.tmpl.Execute(&b, data); err != nil {
+				return fmt.Errorf("template of field %s: %v", f.name, err)
+			}
+			values[i][f.name] = b.String()
+		}
+	}
+	for i, m := range ims {
+		for k, v := range values[i] {
+			m[k] = v
+		}
+	}
+	return nil
+}
+
+// dropFields drops fields of rows, changed and missing columns
+type dropFields struct {
+	columns map[string]bool
+}
+
+func newDropFields(t *pipeline.Transform) (s *dropFields, err error) {
+	if len(t.Columns) == 0 {
+		err = errors.New("columns of drop_fields are empty")
+		return
+	}
+	s = &dropFields{columns: map[string]bool{}}
+	for _, v := range t.Columns {
+		s.columns[v] = true
+	}
+	return
+}
+
+func (s *dropFields) apply(e *rowEvent) error {
+	for _, m := range images(e.Data) {
+		for k := range s.columns {
+			delete(m, k)
+		}
+	}
+	e.Data = eachNames(e.Data, func(names []string) []string {
+		res := make([]string, 0, len(names))
+		for _, v := range names {
+			if !s.columns[v] {
+				res = append(res, v)
+			}
+		}
+		return res
+	})
+	return nil
+}
+
+// cast casts values of columns, NULL is kept as NULL
+type cast struct {
+	casts map[string]pipeline.CastType
+}
+
+func newCast(t *pipeline.Transform) (s *cast, err error) {
+	if len(t.Casts) == 0 {
+		err = errors.New("casts of cast are empty")
+		return
+	}
+	for k, v := range t.Casts {
+		switch v {
+		case pipeline.CAST_INT, pipeline.CAST_FLOAT, pipeline.CAST_STRING, pipeline.CAST_BOOL:
+		default:
+			{
+				err = fmt.Errorf("type %s of column %s is not supported, supported types are int, float, string and bool", v, k)
+				return
+			}
+		}
+	}
+	s = &cast{casts: t.Casts}
+	return
+}
+
+func (s *cast) apply(e *rowEvent) error {
+	ims := images(e.Data)
+	values := make([]map[string]interface{}, len(ims))
+	for i, m := range ims {
+		values[i] = map[string]interface{}{}
+		for column, t := range s.casts {
+			v, ok := m[column]
+			if !ok || v == nil {
+				continue
+			}
+			val, err := castValue(v, t)
+			if err != nil {
+				// value is not in error, it may be sensitive
+				return fmt.Errorf("column %s can not be cast to %s", column, t)
+			}
+			values[i][column] = val
+		}
+	}
+	for i, m := range ims {
+		for k, v := range values[i] {
+			m[k] = v
+		}
+	}
+	return nil
+}
+
+func castValue(v interface{}, t pipeline.CastType) (res interface{}, err error) {
+	switch t {
+	case pipeline.CAST_STRING:
+		{
+			res = valueString(v)
+		}
+	case pipeline.CAST_FLOAT:
+		{
+			res, err = strconv.ParseFloat(strings.TrimSpace(valueString(v)), 64)
+		}
+	case pipeline.CAST_BOOL:
+		{
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			var f float64
+			if f, err = strconv.ParseFloat(strings.TrimSpace(valueString(v)), 64); err == nil {
+				return f != 0, nil
+			}
+			res, err = strconv.ParseBool(strings.TrimSpace(valueString(v)))
+		}
+	default:
+		{
+			switch val := v.(type) {
+			case bool:
+				{
+					if val {
+						return int64(1), nil
+					}
+					return int64(0), nil
+				}
+			case uint64:
+				{
+					if val > math.MaxInt64 {
+						return nil, strconv.ErrRange
+					}
+					return int64(val), nil
+				}
+			}
+			s := strings.TrimSpace(valueString(v))
+			if res, err = strconv.ParseInt(s, 10, 64); err == nil {
+				return
+			}
+			// integral decimals and floats like 10.00
+			var f float64
+			if f, err = strconv.ParseFloat(s, 64); err != nil {
+				return
+			}
+			if f != math.Trunc(f) || f > math.MaxInt64 || f < math.MinInt64 {
+				return nil, strconv.ErrRange
+			}
+			res = int64(f)
+		}
+	}
+	return
+}
+
+// flatten flattens rows into one map, columns of old image of update are prefixed
+type flatten struct {
+	prefix string
+}
+
+func newFlatten(t *pipeline.Transform) *flatten {
+	prefix := t.Prefix
+	if prefix == "" {
+		prefix = "old_"
+	}
+	return &flatten{prefix: prefix}
+}
+
+func (s *flatten) apply(e *rowEvent) error {
+	res := map[string]interface{}{}
+	switch data := e.Data.(type) {
+	case message2.Insert:
+		{
+			copyValues(res, data.New, "")
+		}
+	case message2.Update:
+		{
+			copyValues(res, data.New, "")
+			copyValues(res, data.Old, s.prefix)
+		}
+	case message2.Delete:
+		{
+			copyValues(res, data.Old, "")
+		}
+	case message2.Snapshot:
+		{
+			copyValues(res, data.New, "")
+		}
+	default:
+		{
+			return nil
+		}
+	}
+	e.Data = res
+	return nil
+}
+
+func copyValues(dst map[string]interface{}, src map[string]interface{}, prefix string) {
+	for k, v := range src {
+		dst[prefix+k] = v
+	}
+}
+
+// images returns maps of values of rows in data
+func images(data interface{}) (res []map[string]interface{}) {
+	switch val := data.(type) {
+	case message2.Insert:
+		{
+			res = append(res, val.New)
+		}
+	case message2.Update:
+		{
+			res = append(res, val.Old, val.New)
+		}
+	case message2.Delete:
+		{
+			res = append(res, val.Old)
+		}
+	case message2.Snapshot:
+		{
+			res = append(res, val.New)
+		}
+	case map[string]interface{}:
+		{
+			res = append(res, val)
+		}
+	}
+	for i := len(res) - 1; i >= 0; i-- {
+		if res[i] == nil {
+			res = append(res[:i], res[i+1:]...)
+		}
+	}
+	return
+}
+
+// eachNames returns data with names of changed and missing columns replaced by f
+func eachNames(data interface{}, f func([]string) []string) interface{} {
+	switch val := data.(type) {
+	case message2.Insert:
+		{
+			val.Missing = namesOrNil(val.Missing, f)
+			return val
+		}
+	case message2.Update:
+		{
+			val.Changed = namesOrNil(val.Changed, f)
+			val.OldMissing = namesOrNil(val.OldMissing, f)
+			val.NewMissing = namesOrNil(val.NewMissing, f)
+			return val
+		}
+	case message2.Delete:
+		{
+			val.Missing = namesOrNil(val.Missing, f)
+			return val
+		}
+	}
+	return data
+}
+
+func namesOrNil(names []string, f func([]string) []string) []string {
+	if names == nil {
+		return nil
+	}
+	return f(names)
+}
+
+func valueString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		{
+			return val
+		}
+	case []byte:
+		{
+			return string(val)
+		}
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,162 @@
+package transform
+
+import (
+	"testing"
+
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func updateEvent() *rowEvent {
+	return &rowEvent{
+		Database: "mall",
+		Table:    "order_001",
+		Type:     "update",
+		PK:       &message2.PK{Columns: []string{"id"}, Values: []interface{}{int64(1)}},
+		Data: message2.Update{
+			Old:     map[string]interface{}{"id": int64(1), "status": "new", "amount": "10.00"},
+			New:     map[string]interface{}{"id": int64(1), "status": "paid", "amount": "10.00"},
+			Changed: []string{"status"},
+		},
+	}
+}
+
+func TestRenameColumns(t *testing.T) {
+	s, err := newRenameColumns(&pipeline.Transform{Rename: map[string]string{"id": "order_id", "status": "state"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := updateEvent()
+	if err = s.apply(e); err != nil {
+		t.Error(err)
+	}
+	data := e.Data.(message2.Update)
+	if data.New["order_id"] != int64(1) || data.Old["state"] != "new" || data.New["status"] != nil {
+		t.Error(data)
+	}
+	if data.Changed[0] != "state" || e.PK.Columns[0] != "order_id" {
+		t.Error(data.Changed, e.PK)
+	}
+	if _, err = newRenameColumns(&pipeline.Transform{}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestRenameTable(t *testing.T) {
+	s, err := newRenameTable(&pipeline.Transform{Table: "order"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := updateEvent()
+	s.apply(e)
+	if e.Database != "mall" || e.Table != "order" {
+		t.Error(e.Database, e.Table)
+	}
+	if _, err = newRenameTable(&pipeline.Transform{}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestAddFields(t *testing.T) {
+	s, err := newAddFields(&pipeline.Transform{Fields: []*pipeline.TransformField{
+		{Name: "source", Value: "binlogo"},
+		{Name: "key", Template: "{{.Database}}.{{.Table}}:{{.Row.id}}"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := updateEvent()
+	if err = s.apply(e); err != nil {
+		t.Error(err)
+	}
+	data := e.Data.(message2.Update)
+	if data.New["source"] != "binlogo" || data.Old["key"] != "mall.order_001:1" {
+		t.Error(data)
+	}
+	if _, err = newAddFields(&pipeline.Transform{Fields: []*pipeline.TransformField{{Name: "a", Template: "{{.Row"}}}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestDropFields(t *testing.T) {
+	s, err := newDropFields(&pipeline.Transform{Columns: []string{"status"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := updateEvent()
+	s.apply(e)
+	data := e.Data.(message2.Update)
+	if _, ok := data.New["status"]; ok || len(data.Changed) != 0 || len(data.Old) != 2 {
+		t.Error(data)
+	}
+}
+
+func TestCast(t *testing.T) {
+	s, err := newCast(&pipeline.Transform{Casts: map[string]pipeline.CastType{
+		"amount": pipeline.CAST_INT,
+		"id":     pipeline.CAST_STRING,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := updateEvent()
+	if err = s.apply(e); err != nil {
+		t.Error(err)
+	}
+	data := e.Data.(message2.Update)
+	if data.New["amount"] != int64(10) || data.Old["id"] != "1" {
+		t.Error(data)
+	}
+	s, _ = newCast(&pipeline.Transform{Casts: map[string]pipeline.CastType{"status": pipeline.CAST_INT}})
+	e = updateEvent()
+	if err = s.apply(e); err == nil || err.Error() != "column status can not be cast to int" {
+		t.Error(err)
+	}
+	if e.Data.(message2.Update).New["status"] != "paid" {
+		t.Fail()
+	}
+	cases := []struct {
+		val      interface{}
+		t        pipeline.CastType
+		expected interface{}
+	}{
+		{"1.5", pipeline.CAST_FLOAT, 1.5},
+		{"true", pipeline.CAST_BOOL, true},
+		{int64(0), pipeline.CAST_BOOL, false},
+		{true, pipeline.CAST_INT, int64(1)},
+		{[]byte("abc"), pipeline.CAST_STRING, "abc"},
+	}
+	for _, v := range cases {
+		if res, err := castValue(v.val, v.t); err != nil || res != v.expected {
+			t.Error(v, res, err)
+		}
+	}
+	if _, err = castValue("10.5", pipeline.CAST_INT); err == nil {
+		t.Fail()
+	}
+	if _, err = newCast(&pipeline.Transform{Casts: map[string]pipeline.CastType{"a": "date"}}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	s := newFlatten(&pipeline.Transform{})
+	e := updateEvent()
+	s.apply(e)
+	data := e.Data.(map[string]interface{})
+	if data["status"] != "paid" || data["old_status"] != "new" || len(data) != 6 {
+		t.Error(data)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := Check(&pipeline.Transform{Type: "lowercase"}); err == nil {
+		t.Fail()
+	}
+	if err := Check(&pipeline.Transform{Type: pipeline.TRANSFORM_FLATTEN, Rule: "order_(", Syntax: pipeline.FILTER_SYNTAX_REGEX}); err == nil {
+		t.Fail()
+	}
+	if err := Check(&pipeline.Transform{Type: pipeline.TRANSFORM_RENAME_TABLE, Rule: "mall.order_*", Syntax: pipeline.FILTER_SYNTAX_GLOB, Table: "order"}); err != nil {
+		t.Error(err)
+	}
+}
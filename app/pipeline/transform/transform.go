@@ -0,0 +1,274 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/configs"
+	"github.com/jin06/binlogo/pkg/event"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
+	"github.com/jin06/binlogo/pkg/promeths"
+	event2 "github.com/jin06/binlogo/pkg/store/model/event"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Transform routes tables, runs transform steps and script of pipeline on messages passing filter
+type Transform struct {
+	InChan  chan *message2.Message
+	OutChan chan *message2.Message
+	Options *Options
+	ctx     context.Context
+	router  *tool.Router
+	steps   []*chainStep
+	script  *script
+}
+
+// New returns a new Transform
+func New(opts ...Option) (t *Transform, err error) {
+	options := &Options{}
+	for _, v := range opts {
+		v(options)
+	}
+	t = &Transform{
+		Options: options,
+	}
+	return
+}
+
+func (t *Transform) init() (err error) {
+	if t.router, err = tool.NewRouter(t.Options.Pipe.Routes); err != nil {
+		return
+	}
+	t.steps = nil
+	for i, v := range t.Options.Pipe.Transforms {
+		var s *chainStep
+		if s, err = newChainStep(i, v); err != nil {
+			err = fmt.Errorf("transform step %d: %v", i, err)
+			return
+		}
+		t.steps = append(t.steps, s)
+	}
+	t.script = nil
+	if t.Options.Pipe.Script != nil {
+		printer := func(msg string) {
+			logrus.Debugf("pipeline %s script: %s", t.Options.Pipe.Name, msg)
+		}
+		if t.script, err = newScript(t.Options.Pipe.Name, t.Options.Pipe.Script, printer); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// route routes tables of message to logical tables, physical tables are kept in origin
+func (t *Transform) route(msg *message2.Message) {
+	if msg.Filter {
+		return
+	}
+	if trx, ok := msg.Content.Data.(message2.Transaction); ok {
+		for _, v := range trx.Changes {
+			if database, table, ok := t.router.Route(v.Database, v.Table); ok {
+				v.Origin = &message2.Origin{Database: v.Database, Table: v.Table}
+				v.Database, v.Table = database, table
+			}
+		}
+		return
+	}
+	head := &msg.Content.Head
+	if database, table, ok := t.router.Route(head.Database, head.Table); ok {
+		head.Origin = &message2.Origin{Database: head.Database, Table: head.Table}
+		head.Database, head.Table = database, table
+	}
+}
+
+// handle runs steps on message, a message a step fails on is dropped by filtering it unless the step is skipped.
+// on_error applies to each change of a transaction message, only changes steps fail on are dropped.
+// It returns false if the pipeline should be stopped
+func (t *Transform) handle(msg *message2.Message) bool {
+	if msg.Filter || len(t.steps) == 0 {
+		return true
+	}
+	if trx, ok := msg.Content.Data.(message2.Transaction); ok {
+		return t.handleTransaction(msg, trx)
+	}
+	head := &msg.Content.Head
+	e := &rowEvent{Database: head.Database, Table: head.Table, Type: head.Type, Time: head.Time, PK: head.PK, Data: msg.Content.Data}
+	switch t.apply(e) {
+	case "":
+		{
+			head.Database, head.Table, head.PK, msg.Content.Data = e.Database, e.Table, e.PK, e.Data
+		}
+	case pipeline.ON_ERROR_DROP:
+		{
+			msg.Filter = true
+		}
+	case pipeline.ON_ERROR_STOP:
+		{
+			return false
+		}
+	}
+	return true
+}
+
+// handleTransaction runs steps on each change of transaction, changes steps fail on are removed,
+// the message is filtered if all changes are removed
+func (t *Transform) handleTransaction(msg *message2.Message, trx message2.Transaction) bool {
+	changes := make([]*message2.Change, 0, len(trx.Changes))
+	for _, v := range trx.Changes {
+		e := &rowEvent{Database: v.Database, Table: v.Table, Type: v.Type, Time: msg.Content.Head.Time, PK: v.PK, Data: v.Data}
+		switch t.apply(e) {
+		case "":
+			{
+				v.Database, v.Table, v.PK, v.Data = e.Database, e.Table, e.PK, e.Data
+				changes = append(changes, v)
+			}
+		case pipeline.ON_ERROR_STOP:
+			{
+				return false
+			}
+		}
+	}
+	trx.Changes = changes
+	msg.Content.Data = trx
+	msg.Filter = len(changes) == 0
+	return true
+}
+
+// apply runs steps in order, a failed step is reported by an event.
+// It returns on_error of the failed step if the message can not go on, empty otherwise
+func (t *Transform) apply(e *rowEvent) pipeline.OnError {
+	for _, s := range t.steps {
+		if !s.tables.Match(e.Database, e.Table) {
+			continue
+		}
+		if err := s.step.apply(e); err != nil {
+			t.failed(strconv.Itoa(s.index), string(s.conf.Type), s.onError, e.Database, e.Table, err)
+			if s.onError != pipeline.ON_ERROR_SKIP {
+				return s.onError
+			}
+		}
+	}
+	return ""
+}
+
+// runScript runs script on message after steps, it returns messages split from the message.
+// A dropped message is filtered, it is still passed to output to record its position.
+// If script fails, the message is handled by on_error of script, nil is returned if the pipeline should be stopped
+func (t *Transform) runScript(msg *message2.Message) []*message2.Message {
+	if msg.Filter || t.script == nil {
+		return []*message2.Message{msg}
+	}
+	contents, err := t.script.run(msg.Content)
+	if err != nil {
+		t.failed("script", "script", t.script.onError, msg.Content.Head.Database, msg.Content.Head.Table, err)
+		switch t.script.onError {
+		case pipeline.ON_ERROR_DROP:
+			{
+				msg.Filter = true
+			}
+		case pipeline.ON_ERROR_STOP:
+			{
+				return nil
+			}
+		}
+		return []*message2.Message{msg}
+	}
+	if len(contents) == 0 {
+		msg.Filter = true
+		return []*message2.Message{msg}
+	}
+	if len(contents) > 1 {
+		// split messages are not changes of one row, they are not coalesced
+		msg.RowKey = ""
+	}
+	msgs := make([]*message2.Message, len(contents))
+	for i, c := range contents {
+		m := msg
+		if i < len(contents)-1 {
+			m = message2.Get()
+			m.Status = msg.Status
+			m.Partial = true
+		}
+		m.Content = c
+		msgs[i] = m
+	}
+	return msgs
+}
+
+// failed reports failed step by metric and event, step is index of step or script
+func (t *Transform) failed(step string, stepType string, onError pipeline.OnError, database string, table string, err error) {
+	if promeths.TransformErrorCounter != nil {
+		promeths.TransformErrorCounter.With(prometheus.Labels{
+			"pipeline": t.Options.Pipe.Name,
+			"node":     configs.NodeName,
+			"step":     step,
+			"type":     stepType,
+		}).Inc()
+	}
+	name := "script"
+	if step != "script" {
+		name = fmt.Sprintf("step %s %s", step, stepType)
+	}
+	action := "it is skipped"
+	switch onError {
+	case pipeline.ON_ERROR_DROP:
+		{
+			action = "message is dropped"
+		}
+	case pipeline.ON_ERROR_STOP:
+		{
+			action = "pipeline is stopped"
+		}
+	}
+	event.Event(event2.NewErrorPipeline(t.Options.Pipe.Name,
+		fmt.Sprintf("Transform %s failed on %s.%s, %s: %v", name, database, table, action, err)))
+}
+
+// Run Transform start working
+func (t *Transform) Run(ctx context.Context) (err error) {
+	err = t.init()
+	if err != nil {
+		return
+	}
+	myCtx, c := context.WithCancel(ctx)
+	t.ctx = myCtx
+	go func() {
+		defer func() {
+			c()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				{
+					return
+				}
+			case msg := <-t.InChan:
+				{
+					t.route(msg)
+					if !t.handle(msg) {
+						message2.Put(msg)
+						return
+					}
+					msgs := t.runScript(msg)
+					if msgs == nil {
+						message2.Put(msg)
+						return
+					}
+					for _, m := range msgs {
+						t.OutChan <- m
+					}
+				}
+			}
+		}
+	}()
+	return
+}
+
+// Context return Transform's context for cancel goroutine
+func (t *Transform) Context() context.Context {
+	return t.ctx
+}
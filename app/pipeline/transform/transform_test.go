@@ -0,0 +1,178 @@
+package transform
+
+import (
+	"context"
+	"testing"
+
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/promeths"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestRun(t *testing.T) {
+	promeths.Init()
+	pipe := &pipeline.Pipeline{
+		Name: "test",
+		Transforms: []*pipeline.Transform{
+			{Type: pipeline.TRANSFORM_RENAME_TABLE, Rule: "mall.order_*", Syntax: pipeline.FILTER_SYNTAX_GLOB, Table: "order"},
+			{Type: pipeline.TRANSFORM_CAST, Rule: "mall.order", Casts: map[string]pipeline.CastType{"status": pipeline.CAST_INT}, OnError: pipeline.ON_ERROR_SKIP},
+			{Type: pipeline.TRANSFORM_ADD_FIELDS, Rule: "mall.order", Fields: []*pipeline.TransformField{{Name: "shard", Value: true}}},
+		},
+	}
+	tr, err := New(WithPipe(pipe))
+	if err != nil {
+		t.Error(err)
+	}
+	tr.InChan = make(chan *message2.Message, 1)
+	tr.OutChan = make(chan *message2.Message, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = tr.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	msg := message2.New()
+	msg.Content.Head.Database = "mall"
+	msg.Content.Head.Table = "order_001"
+	msg.Content.Data = message2.Insert{New: map[string]interface{}{"status": "paid"}}
+	tr.InChan <- msg
+	msg = <-tr.OutChan
+	data := msg.Content.Data.(message2.Insert)
+	// failed cast is skipped, the following steps still run
+	if msg.Content.Head.Table != "order" || data.New["status"] != "paid" || data.New["shard"] != true {
+		t.Error(msg.Content.Head, data)
+	}
+
+	pipe.Transforms = append(pipe.Transforms, &pipeline.Transform{Type: "unknown"})
+	if err = tr.init(); err == nil {
+		t.Fail()
+	}
+	pipe.Transforms[len(pipe.Transforms)-1] = &pipeline.Transform{Type: pipeline.TRANSFORM_FLATTEN, OnError: "retry"}
+	if err = tr.init(); err == nil {
+		t.Fail()
+	}
+}
+
+func TestOnError(t *testing.T) {
+	promeths.Init()
+	cast := &pipeline.Transform{Type: pipeline.TRANSFORM_CAST, Casts: map[string]pipeline.CastType{"status": pipeline.CAST_INT}}
+	pipe := &pipeline.Pipeline{
+		Name: "test",
+		Transforms: []*pipeline.Transform{
+			{Type: pipeline.TRANSFORM_RENAME_TABLE, Table: "order"},
+			cast,
+		},
+	}
+	tr, err := New(WithPipe(pipe))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newMsg := func() *message2.Message {
+		msg := message2.New()
+		msg.Content.Head.Database = "mall"
+		msg.Content.Head.Table = "order_001"
+		msg.Content.Data = message2.Insert{New: map[string]interface{}{"status": "paid"}}
+		return msg
+	}
+
+	// dropped by default, steps before the failed one are not delivered
+	if err = tr.init(); err != nil {
+		t.Fatal(err)
+	}
+	msg := newMsg()
+	if !tr.handle(msg) || !msg.Filter {
+		t.Error(msg)
+	}
+	// only the change of transaction the step fails on is dropped
+	msg = newMsg()
+	msg.Content.Data = message2.Transaction{Changes: []*message2.Change{
+		{Database: "mall", Table: "order_001", Data: message2.Insert{New: map[string]interface{}{"status": 1}}},
+		{Database: "mall", Table: "order_001", Data: message2.Insert{New: map[string]interface{}{"status": "paid"}}},
+	}}
+	if !tr.handle(msg) || msg.Filter {
+		t.Error(msg)
+	}
+	if changes := msg.Content.Data.(message2.Transaction).Changes; len(changes) != 1 || changes[0].Table != "order" {
+		t.Error(changes)
+	}
+	msg = newMsg()
+	msg.Content.Data = message2.Transaction{Changes: []*message2.Change{
+		{Database: "mall", Table: "order_001", Data: message2.Insert{New: map[string]interface{}{"status": "paid"}}},
+	}}
+	if !tr.handle(msg) || !msg.Filter {
+		t.Error(msg)
+	}
+
+	cast.OnError = pipeline.ON_ERROR_STOP
+	if err = tr.init(); err != nil {
+		t.Fatal(err)
+	}
+	if tr.handle(newMsg()) {
+		t.Fail()
+	}
+
+	cast.OnError = pipeline.ON_ERROR_SKIP
+	if err = tr.init(); err != nil {
+		t.Fatal(err)
+	}
+	msg = newMsg()
+	if !tr.handle(msg) || msg.Filter || msg.Content.Head.Table != "order" {
+		t.Error(msg)
+	}
+
+	// stopped transform stops the pipeline without passing the message
+	cast.OnError = pipeline.ON_ERROR_STOP
+	tr.InChan = make(chan *message2.Message, 1)
+	tr.OutChan = make(chan *message2.Message, 1)
+	if err = tr.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	tr.InChan <- newMsg()
+	<-tr.Context().Done()
+	if len(tr.OutChan) != 0 {
+		t.Fail()
+	}
+}
+
+func TestRoute(t *testing.T) {
+	pipe := &pipeline.Pipeline{
+		Name: "test",
+		Routes: []*pipeline.TableRoute{
+			{Rule: "mall_*.order_*", Syntax: pipeline.FILTER_SYNTAX_GLOB, Database: "mall", Table: "order"},
+		},
+		Transforms: []*pipeline.Transform{
+			{Type: pipeline.TRANSFORM_ADD_FIELDS, Rule: "mall.order", Fields: []*pipeline.TransformField{{Name: "shard", Template: "{{.Table}}"}}},
+		},
+	}
+	tr, err := New(WithPipe(pipe))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.init(); err != nil {
+		t.Fatal(err)
+	}
+	msg := message2.New()
+	msg.Content.Head.Database = "mall_01"
+	msg.Content.Head.Table = "order_07"
+	msg.Content.Data = message2.Insert{New: map[string]interface{}{"id": 1}}
+	tr.route(msg)
+	tr.handle(msg)
+	head := msg.Content.Head
+	// transforms match the logical table
+	if head.Database != "mall" || head.Table != "order" || head.Origin == nil ||
+		head.Origin.Database != "mall_01" || head.Origin.Table != "order_07" ||
+		msg.Content.Data.(message2.Insert).New["shard"] != "order" {
+		t.Error(head, msg.Content.Data)
+	}
+
+	msg = message2.New()
+	msg.Content.Data = message2.Transaction{Changes: []*message2.Change{
+		{Database: "mall_02", Table: "order_01"},
+		{Database: "mall_02", Table: "item"},
+	}}
+	tr.route(msg)
+	changes := msg.Content.Data.(message2.Transaction).Changes
+	if changes[0].Table != "order" || changes[0].Origin == nil || changes[0].Origin.Table != "order_01" ||
+		changes[1].Table != "item" || changes[1].Origin != nil {
+		t.Error(changes[0], changes[1])
+	}
+}
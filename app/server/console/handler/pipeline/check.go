@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jin06/binlogo/app/pipeline/transform"
+	pipeline2 "github.com/jin06/binlogo/app/server/console/module/pipeline"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// checkPipeline returns error if config of pipeline created or updated is illegal
+func checkPipeline(p *pipeline.Pipeline) (err error) {
+	if p.Mysql != nil && p.Mysql.SpillRows > 0 && p.Mysql.Transaction == pipeline.TRANSACTION_GROUP {
+		// grouped transaction is one message, it is held in memory however its rows are buffered
+		return errors.New("spill_rows can not be used with group transaction")
+	}
+	for _, v := range p.Filters {
+		if err = tool.FilterCheck(v); err != nil {
+			return
+		}
+	}
+	if err = pipeline2.CheckColumns(p); err != nil {
+		return
+	}
+	if err = tool.OutputCheck(p.Output); err != nil {
+		return
+	}
+	if err = tool.CoalesceCheck(p); err != nil {
+		return
+	}
+	for i, v := range p.Routes {
+		if err = tool.RouteCheck(v); err != nil {
+			return fmt.Errorf("route %d: %v", i, err)
+		}
+	}
+	for i, v := range p.Transforms {
+		if err = transform.Check(v); err != nil {
+			return fmt.Errorf("transform step %d: %v", i, err)
+		}
+	}
+	if p.Script != nil {
+		err = transform.CheckScript(p.Script)
+	}
+	return
+}
@@ -3,7 +3,6 @@ package pipeline
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jin06/binlogo/app/server/console/handler"
-	"github.com/jin06/binlogo/pkg/pipeline/tool"
 	"github.com/jin06/binlogo/pkg/store/dao/dao_pipe"
 	"github.com/jin06/binlogo/pkg/store/model/pipeline"
 	"github.com/jin06/binlogo/pkg/util/random"
@@ -18,11 +17,9 @@ func Create(c *gin.Context) {
 		c.JSON(200, handler.Fail(err.Error()))
 		return
 	}
-	for _, v := range q.Filters {
-		if !tool.FilterVerifyStr(v.Rule) {
-			c.JSON(200, handler.Fail("Filter rule error, only support the format like database.table or database "))
-			return
-		}
+	if err := checkPipeline(q); err != nil {
+		c.JSON(200, handler.Fail(err))
+		return
 	}
 	q.CreateTime = time.Now()
 
@@ -41,23 +38,38 @@ func Create(c *gin.Context) {
 }
 
 func pipelineDefault(p *pipeline.Pipeline) {
-	switch p.Output.Sender.Type {
+	if p.Mysql != nil && p.Mysql.PasswordRef != "" {
+		// password is resolved from reference at runtime, never stored in clear text
+		p.Mysql.Password = ""
+	}
+	for _, v := range p.Columns {
+		if v.SaltRef != "" {
+			v.Salt = ""
+		}
+	}
+	for _, v := range p.Output.SenderList() {
+		senderDefault(p.Name, v)
+	}
+}
+
+func senderDefault(name string, s *pipeline.Sender) {
+	switch s.Type {
 	case pipeline.SNEDER_TYPE_RABBITMQ:
 		{
-			if p.Output.Sender.RabbitMQ.ExchangeName == "" {
-				p.Output.Sender.RabbitMQ.ExchangeName = p.Name
+			if s.RabbitMQ.ExchangeName == "" {
+				s.RabbitMQ.ExchangeName = name
 			}
 		}
 	case pipeline.SENDER_TYPE_REDIS:
 		{
-			if p.Output.Sender.Redis.List == "" {
-				p.Output.Sender.Redis.List = p.Name
+			if s.Redis.List == "" {
+				s.Redis.List = name
 			}
 		}
 	case pipeline.SENDER_TYPE_KAFKA:
 		{
-			if p.Output.Sender.Kafka.Topic == "" {
-				p.Output.Sender.Kafka.Topic = p.Name
+			if s.Kafka.Topic == "" {
+				s.Kafka.Topic = name
 			}
 		}
 	}
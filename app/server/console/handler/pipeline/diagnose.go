@@ -0,0 +1,22 @@
+package pipeline
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jin06/binlogo/app/server/console/handler"
+	pipeline2 "github.com/jin06/binlogo/app/server/console/module/pipeline"
+)
+
+// Diagnose handler, checks whether mysql is ready for pipeline
+func Diagnose(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(200, handler.Fail("Name is null"))
+		return
+	}
+	d, err := pipeline2.Diagnose(name)
+	if err != nil {
+		c.JSON(200, handler.Fail(err))
+		return
+	}
+	c.JSON(200, handler.Success(d))
+}
@@ -43,6 +43,14 @@ func AddFilter(c *gin.Context) {
 		c.JSON(200, handler.Fail(err))
 		return
 	}
+	if q.Filter == nil {
+		c.JSON(200, handler.Fail("Filter is null"))
+		return
+	}
+	if err := tool.FilterCheck(q.Filter); err != nil {
+		c.JSON(200, handler.Fail(err))
+		return
+	}
 	ok, err := dao_pipe.UpdatePipeline(q.PipeName, pipeline.WithAddFilter(q.Filter))
 	if err != nil || !ok {
 		c.JSON(200, handler.Fail("Add filter failed."))
@@ -61,6 +69,14 @@ func UpdateFilter(c *gin.Context) {
 		c.JSON(200, handler.Fail(err))
 		return
 	}
+	if q.Filter == nil {
+		c.JSON(200, handler.Fail("Filter is null"))
+		return
+	}
+	if err := tool.FilterCheck(q.Filter); err != nil {
+		c.JSON(200, handler.Fail(err))
+		return
+	}
 	ok, err := dao_pipe.UpdatePipeline(q.PipeName, pipeline.WithUpdateFilter(q.Index, q.Filter))
 	if err != nil || !ok {
 		c.JSON(200, handler.Fail("Update filter failed."))
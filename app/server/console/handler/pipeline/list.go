@@ -29,7 +29,7 @@ func List(c *gin.Context) {
 		if v.IsDelete {
 			continue
 		}
-		if v.Output.Sender.Http == nil {
+		if v.Output.Sender != nil && v.Output.Sender.Http == nil {
 			v.Output.Sender.Http = &pipeline2.Http{}
 		}
 		items = append(items, &pipeline.Item{Pipeline: v})
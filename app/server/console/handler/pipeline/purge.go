@@ -0,0 +1,17 @@
+package pipeline
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jin06/binlogo/app/server/console/handler"
+	pipeline2 "github.com/jin06/binlogo/app/server/console/module/pipeline"
+)
+
+// PurgeRisk handler, lists pipelines whose binlog is purged or about to be purged
+func PurgeRisk(c *gin.Context) {
+	risks, err := pipeline2.PurgeRisks()
+	if err != nil {
+		c.JSON(200, handler.Fail(err))
+		return
+	}
+	c.JSON(200, handler.Success(risks))
+}
@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"github.com/gin-gonic/gin"
+	message2 "github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/app/pipeline/transform"
+	"github.com/jin06/binlogo/app/server/console/handler"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// TestScript handler, runs script against a sample message content, returns contents after script and output of print.
+// Empty contents means the message is dropped
+func TestScript(c *gin.Context) {
+	q := &struct {
+		Script  *pipeline.Script  `json:"script"`
+		Content *message2.Content `json:"content"`
+	}{}
+	if err := c.BindJSON(q); err != nil {
+		c.JSON(200, handler.Fail(err))
+		return
+	}
+	if q.Script == nil || q.Content == nil {
+		c.JSON(200, handler.Fail("Script or content is null"))
+		return
+	}
+	contents, prints, err := transform.RunScript(q.Script, *q.Content)
+	if err != nil {
+		c.JSON(200, handler.Fail(err))
+		return
+	}
+	c.JSON(200, handler.Success(map[string]interface{}{
+		"contents": contents,
+		"prints":   prints,
+	}))
+}
@@ -4,7 +4,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jin06/binlogo/app/server/console/handler"
 	pipeline2 "github.com/jin06/binlogo/app/server/console/module/pipeline"
-	"github.com/jin06/binlogo/pkg/pipeline/tool"
 	"github.com/jin06/binlogo/pkg/store/dao/dao_pipe"
 	"github.com/jin06/binlogo/pkg/store/model/pipeline"
 )
@@ -16,11 +15,9 @@ func Update(c *gin.Context) {
 		c.JSON(200, handler.Fail(err.Error()))
 		return
 	}
-	for _, v := range q.Filters {
-		if !tool.FilterVerifyStr(v.Rule) {
-			c.JSON(200, handler.Fail("Filter rule error, only support the format like database.table or database "))
-			return
-		}
+	if err := checkPipeline(q); err != nil {
+		c.JSON(200, handler.Fail(err))
+		return
 	}
 
 	pipe, err := dao_pipe.GetPipeline(q.Name)
@@ -57,6 +54,17 @@ func UpdateStatus(c *gin.Context) {
 		c.JSON(200, handler.Fail("Wrong param status: "+q.Status))
 		return
 	}
+	if q.Status == pipeline.STATUS_RUN {
+		d, err := pipeline2.Diagnose(q.PipeName)
+		if err != nil {
+			c.JSON(200, handler.Fail(err.Error()))
+			return
+		}
+		if !d.Passed {
+			c.JSON(200, handler.Fail("Pre-flight check failed, "+d.Message()))
+			return
+		}
+	}
 
 	ok, err := dao_pipe.UpdatePipeline(q.PipeName, pipeline.WithPipeStatus(q.Status))
 	if err != nil || !ok {
@@ -0,0 +1,36 @@
+package position
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jin06/binlogo/app/server/console/handler"
+	"github.com/jin06/binlogo/pkg/mysql/replication"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
+)
+
+// UpdateByTime handler, update position of pipeline to the first transaction at or after the time
+func UpdateByTime(c *gin.Context) {
+	q := struct {
+		PipeName string `json:"pipeline_name"`
+		Time     string `json:"time"`
+	}{}
+	if err := c.BindJSON(&q); err != nil {
+		c.JSON(200, handler.Fail(err))
+		return
+	}
+	t, err := replication.ParseTime(q.Time)
+	if err != nil {
+		c.JSON(200, handler.Fail("time error: "+err.Error()))
+		return
+	}
+	ctx, cancel := context.WithTimeout(c, time.Minute)
+	defer cancel()
+	pos, err := tool.UpdatePositionByTime(ctx, q.PipeName, t)
+	if err != nil {
+		c.JSON(200, handler.Fail(err))
+		return
+	}
+	c.JSON(200, handler.Success(pos))
+}
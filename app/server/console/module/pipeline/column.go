@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/jin06/binlogo/pkg/mysql/replication"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// CheckColumns validates column rules of pipeline, columns of rules must exist in tables of mysql
+func CheckColumns(p *pipeline.Pipeline) (err error) {
+	if len(p.Columns) == 0 {
+		return
+	}
+	rules := make([]*tool.ColumnRule, len(p.Columns))
+	for i, v := range p.Columns {
+		if rules[i], err = tool.CompileColumnRule(v); err != nil {
+			err = fmt.Errorf("column rule %d: %v", i, err)
+			return
+		}
+	}
+	if p.Mysql == nil || p.Mysql.Source != pipeline.SOURCE_SERVER {
+		// binlog files, no server to look up columns
+		return
+	}
+	conn, err := replication.Connect(p.Mysql, replication.Addr(p.Mysql))
+	if err != nil {
+		err = fmt.Errorf("check columns of column rules: %v", err)
+		return
+	}
+	defer conn.Close()
+	res, err := conn.Execute("SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME FROM information_schema.COLUMNS " +
+		"WHERE TABLE_SCHEMA NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')")
+	if err != nil {
+		return
+	}
+	tables := map[string][]string{}
+	for i := 0; i < res.RowNumber(); i++ {
+		database, _ := res.GetString(i, 0)
+		table, _ := res.GetString(i, 1)
+		column, _ := res.GetString(i, 2)
+		tables[database+"."+table] = append(tables[database+"."+table], column)
+	}
+	for i, r := range rules {
+		if err = r.CheckTables(tables); err != nil {
+			err = fmt.Errorf("column rule %d: %v", i, err)
+			return
+		}
+	}
+	return
+}
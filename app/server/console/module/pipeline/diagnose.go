@@ -0,0 +1,30 @@
+package pipeline
+
+import (
+	"errors"
+
+	"github.com/jin06/binlogo/pkg/mysql/replication"
+	"github.com/jin06/binlogo/pkg/store/dao/dao_pipe"
+)
+
+// Diagnose runs pre-flight checks of pipeline against its mysql
+func Diagnose(name string) (d *replication.Diagnosis, err error) {
+	pipe, err := dao_pipe.GetPipeline(name)
+	if err != nil {
+		return
+	}
+	if pipe == nil {
+		err = errors.New("pipeline not found")
+		return
+	}
+	record, err := dao_pipe.GetRecord(name)
+	if err != nil {
+		return
+	}
+	pipes, err := dao_pipe.AllPipelines()
+	if err != nil {
+		return
+	}
+	d = replication.Diagnose(pipe, record, pipes)
+	return
+}
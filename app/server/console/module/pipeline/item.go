@@ -88,18 +88,19 @@ func CompletePipelineBind(i *Item, pb *scheduler.PipelineBind) (err error) {
 }
 
 func completeEmpty(i *Item) {
-	sender := i.Pipeline.Output.Sender
-	if sender.RocketMQ == nil {
-		sender.RocketMQ = &pipeline.RocketMQ{}
-	}
-	if sender.Redis == nil {
-		sender.Redis = &pipeline.Redis{}
-	}
-	if sender.Kafka == nil {
-		sender.Kafka = &pipeline.Kafka{}
-	}
-	if sender.Http == nil {
-		sender.Http = &pipeline.Http{}
+	for _, sender := range i.Pipeline.Output.SenderList() {
+		if sender.RocketMQ == nil {
+			sender.RocketMQ = &pipeline.RocketMQ{}
+		}
+		if sender.Redis == nil {
+			sender.Redis = &pipeline.Redis{}
+		}
+		if sender.Kafka == nil {
+			sender.Kafka = &pipeline.Kafka{}
+		}
+		if sender.Http == nil {
+			sender.Http = &pipeline.Http{}
+		}
 	}
 }
 
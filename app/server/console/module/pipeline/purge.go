@@ -0,0 +1,21 @@
+package pipeline
+
+import (
+	"github.com/jin06/binlogo/pkg/mysql/replication"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
+)
+
+// PurgeRisks returns pipelines whose binlog is purged or about to be purged by mysql
+func PurgeRisks() (risks []*replication.PurgeRisk, err error) {
+	all, err := tool.PurgeRisks()
+	if err != nil {
+		return
+	}
+	risks = []*replication.PurgeRisk{}
+	for _, v := range all {
+		if v.Level != replication.CHECK_OK {
+			risks = append(risks, v)
+		}
+	}
+	return
+}
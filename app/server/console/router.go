@@ -31,9 +31,12 @@ func router(g *gin.Engine) {
 	g.POST("/api/pipeline/update/status", pipeline.UpdateStatus)
 	g.POST("/api/pipeline/update/mode", pipeline.UpdateMode)
 	g.POST("/api/pipeline/delete", pipeline.Delete)
+	g.GET("/api/pipeline/diagnose", pipeline.Diagnose)
+	g.GET("/api/pipeline/purge_risk", pipeline.PurgeRisk)
 	g.GET("/api/pipeline/is_filter", pipeline.IsFilter)
 	g.POST("/api/pipeline/add_filter", pipeline.AddFilter)
 	g.POST("/api/pipeline/update_filter", pipeline.UpdateFilter)
+	g.POST("/api/pipeline/script/test", pipeline.TestScript)
 
 	g.GET("/api/node/list", node.List)
 
@@ -42,6 +45,7 @@ func router(g *gin.Engine) {
 
 	g.GET("/api/position/get", position.Get)
 	g.POST("/api/position/update", position.Update)
+	g.POST("/api/position/update/time", position.UpdateByTime)
 
 	g.GET("/api/cluster/get", cluster.Get)
 	g.GET("/api/cluster/list/register", cluster.RegisterList)
@@ -22,6 +22,7 @@ type instance struct {
 	pipeIns   *pipeline.Pipeline
 	pipeInfo  *pipeline2.Pipeline
 	pipeReg   *register.Register
+	insModel  *pipeline2.Instance
 	cancel    context.CancelFunc
 	status    status
 	mutex     sync.Mutex
@@ -81,6 +82,7 @@ func (i *instance) init() (err error) {
 	i.pipeInfo = pipeInfo
 	i.pipeIns = pipe
 	i.pipeReg = reg
+	i.insModel = insModel
 	return
 }
 
@@ -111,26 +113,47 @@ func (i *instance) start(c context.Context) (err error) {
 	logrus.Info("pipeline instance start: ", i.pipeName)
 	event.Event(event2.NewInfoPipeline(i.pipeName, "Pipeline instance start success"))
 
-	select {
-	case <-c.Done():
-		{
-			return
-		}
-	case <-ctx.Done():
-		{
-			return
-		}
-	case <-i.pipeIns.Context().Done():
-		{
-			return
-		}
-	case <-i.pipeReg.Context().Done():
-		{
-			return
+	ticker := time.NewTicker(pipeline.LagInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Done():
+			{
+				return
+			}
+		case <-ctx.Done():
+			{
+				return
+			}
+		case <-i.pipeIns.Context().Done():
+			{
+				return
+			}
+		case <-i.pipeReg.Context().Done():
+			{
+				return
+			}
+		case <-ticker.C:
+			{
+				i.updateLag()
+			}
 		}
 	}
 }
 
+// updateLag stores replication lag of pipeline with the registered instance, so that console shows it
+func (i *instance) updateLag() {
+	lag := i.pipeIns.Lag()
+	if lag == nil {
+		return
+	}
+	ins := *i.insModel
+	ins.Lag = lag
+	if err := i.pipeReg.Update(&ins); err != nil {
+		logrus.Errorln("Update pipeline instance lag error: ", err)
+	}
+}
+
 func (i *instance) stop() {
 	i.startTime = time.Time{}
 	if i.status == STATUS_STOP {
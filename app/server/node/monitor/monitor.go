@@ -85,6 +85,10 @@ func (m *Monitor) Run(ctx context.Context) (err error) {
 	if err != nil {
 		return
 	}
+	purgeCtx, err := m.monitorPurge(myCtx)
+	if err != nil {
+		return
+	}
 	go func() {
 		defer func() {
 			cancel()
@@ -103,6 +107,10 @@ func (m *Monitor) Run(ctx context.Context) (err error) {
 			{
 				return
 			}
+		case <-purgeCtx.Done():
+			{
+				return
+			}
 
 		}
 	}()
@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"context"
+	"time"
+
+	"github.com/jin06/binlogo/pkg/event"
+	"github.com/jin06/binlogo/pkg/mysql/replication"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
+	event2 "github.com/jin06/binlogo/pkg/store/model/event"
+	"github.com/sirupsen/logrus"
+)
+
+// PurgeInterval interval of checking binlog needed by pipelines is not purged
+const PurgeInterval = 5 * time.Minute
+
+func (m *Monitor) monitorPurge(ctx context.Context) (resCtx context.Context, err error) {
+	resCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logrus.Errorln("monitor purge panic", r)
+			}
+			cancel()
+		}()
+		ticker := time.NewTicker(PurgeInterval)
+		defer ticker.Stop()
+		sent := map[string]replication.CheckLevel{}
+		for {
+			checkAllPurge(sent)
+			select {
+			case <-ctx.Done():
+				{
+					return
+				}
+			case <-ticker.C:
+			}
+		}
+	}()
+	return
+}
+
+// checkAllPurge raises events of pipelines whose binlog is purged or about to be purged.
+// sent keeps level of risk sent by event for each pipeline, an event is raised again only when the level changes
+func checkAllPurge(sent map[string]replication.CheckLevel) {
+	risks, err := tool.PurgeRisks()
+	if err != nil {
+		logrus.Errorln(err)
+		return
+	}
+	checked := map[string]bool{}
+	for _, risk := range risks {
+		checked[risk.PipelineName] = true
+		if !purgeChanged(sent, risk.PipelineName, risk.Level) {
+			continue
+		}
+		switch risk.Level {
+		case replication.CHECK_WARNING:
+			{
+				event.Event(event2.NewWarnPipeline(risk.PipelineName, risk.Message))
+			}
+		case replication.CHECK_ERROR:
+			{
+				event.Event(event2.NewErrorPipeline(risk.PipelineName, risk.Message))
+			}
+		}
+	}
+	// pipelines deleted or not checked any more
+	for name := range sent {
+		if !checked[name] {
+			delete(sent, name)
+		}
+	}
+}
+
+// purgeChanged records level of pipeline, returns true if it differs from the level sent before
+func purgeChanged(sent map[string]replication.CheckLevel, name string, level replication.CheckLevel) bool {
+	last, ok := sent[name]
+	if !ok {
+		last = replication.CHECK_OK
+	}
+	if level == replication.CHECK_OK {
+		delete(sent, name)
+	} else {
+		sent[name] = level
+	}
+	return level != last
+}
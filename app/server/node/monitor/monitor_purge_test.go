@@ -0,0 +1,24 @@
+package monitor
+
+import (
+	"testing"
+
+	"github.com/jin06/binlogo/pkg/mysql/replication"
+)
+
+func TestPurgeChanged(t *testing.T) {
+	sent := map[string]replication.CheckLevel{}
+	if purgeChanged(sent, "p1", replication.CHECK_OK) {
+		t.Fail()
+	}
+	if !purgeChanged(sent, "p1", replication.CHECK_WARNING) || purgeChanged(sent, "p1", replication.CHECK_WARNING) {
+		t.Fail()
+	}
+	if !purgeChanged(sent, "p1", replication.CHECK_ERROR) {
+		t.Fail()
+	}
+	// warned again after it is ok
+	if !purgeChanged(sent, "p1", replication.CHECK_OK) || !purgeChanged(sent, "p1", replication.CHECK_WARNING) {
+		t.Fail()
+	}
+}
@@ -1,10 +1,14 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/jin06/binlogo/configs"
 	"github.com/jin06/binlogo/pkg/blog"
+	"github.com/jin06/binlogo/pkg/mysql/replication"
+	"github.com/jin06/binlogo/pkg/pipeline/tool"
 	store2 "github.com/jin06/binlogo/pkg/store"
 	"github.com/jin06/binlogo/pkg/store/model/pipeline"
 	"github.com/spf13/cobra"
@@ -88,5 +92,36 @@ func cmdPipeline() (cmd *cobra.Command) {
 		},
 	}
 	cmd.AddCommand(cmdCreatePipe())
+	cmd.AddCommand(cmdPipePosition())
+	return
+}
+
+func cmdPipePosition() (cmd *cobra.Command) {
+	cmd = &cobra.Command{
+		Use:   "position",
+		Short: "Set position of stopped pipeline to the first transaction at or after the time",
+		Example: "binctl pipe position --name test --time \"2021-11-22 03:00:00\"\n" +
+			"binctl pipe position --name test --time 2021-11-22T11:00:00+08:00",
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			name, _ := cmd.Flags().GetString("name")
+			timeStr, _ := cmd.Flags().GetString("time")
+			t, err := replication.ParseTime(timeStr)
+			if err != nil {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			pos, err := tool.UpdatePositionByTime(ctx, name, t)
+			if err != nil {
+				return
+			}
+			fmt.Printf("binlog file: %s, binlog position: %d, gtid set: %s\n", pos.BinlogFile, pos.BinlogPosition, pos.GTIDSet)
+			return
+		},
+	}
+	cmd.Flags().String("name", "", "pipeline name")
+	cmd.Flags().String("time", "", "start time, in RFC3339 or 2006-01-02 15:04:05 (UTC) format")
+	cmd.MarkFlagRequired("name")
+	cmd.MarkFlagRequired("time")
 	return
 }
@@ -66,6 +66,9 @@ func initViperFromEnv() {
 	if val, found := syscall.Getenv("ETCD_USERNAME"); found {
 		viper.Set("etcd.username", val)
 	}
+	if val, found := syscall.Getenv("SECRETS_FILE"); found {
+		viper.Set("secrets.file", val)
+	}
 }
 
 // initConst set global config
@@ -0,0 +1,82 @@
+package replication
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/jin06/binlogo/pkg/secret"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// Password returns password of mysql, the password reference is resolved if it is set
+func Password(m *pipeline.Mysql) (string, error) {
+	if m.PasswordRef != "" {
+		return secret.Resolve(m.PasswordRef)
+	}
+	return m.Password, nil
+}
+
+// TLSConfig returns tls config of connections to mysql at addr, nil if tls is not enabled
+func TLSConfig(m *pipeline.Mysql, addr string) (cfg *tls.Config, err error) {
+	if m.TLS == nil {
+		return
+	}
+	cfg = &tls.Config{
+		ServerName:         m.TLS.ServerName,
+		InsecureSkipVerify: m.TLS.SkipVerify,
+	}
+	if cfg.ServerName == "" {
+		if cfg.ServerName, _, err = net.SplitHostPort(addr); err != nil {
+			return nil, err
+		}
+	}
+	if m.TLS.CA != "" {
+		var pem []byte
+		if pem, err = os.ReadFile(m.TLS.CA); err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in ca file " + m.TLS.CA)
+		}
+	}
+	if m.TLS.Cert != "" || m.TLS.Key != "" {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(m.TLS.Cert, m.TLS.Key); err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+// Connect connects to mysql at addr with credentials and tls config of pipeline
+func Connect(m *pipeline.Mysql, addr string) (conn *client.Conn, err error) {
+	password, err := Password(m)
+	if err != nil {
+		return
+	}
+	tlsConfig, err := TLSConfig(m, addr)
+	if err != nil {
+		return
+	}
+	return client.Connect(addr, m.User, password, "", func(c *client.Conn) {
+		if tlsConfig != nil {
+			c.SetTLSConfig(tlsConfig)
+		}
+	})
+}
+
+// Candidates returns endpoints of mysql the pipeline connects to in order,
+// failover endpoints are ignored in position mode, because binlog file offsets are specific to a server
+func Candidates(m *pipeline.Mysql) []*pipeline.Endpoint {
+	list := m.Candidates()
+	if m.Mode != pipeline.MODE_GTID {
+		return list[:1]
+	}
+	return list
+}
@@ -0,0 +1,44 @@
+package replication
+
+import (
+	"testing"
+
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestPassword(t *testing.T) {
+	t.Setenv("BINLOGO_TEST_MYSQL_PASSWORD", "123456")
+	password, err := Password(&pipeline.Mysql{Password: "plain", PasswordRef: "env:BINLOGO_TEST_MYSQL_PASSWORD"})
+	if err != nil || password != "123456" {
+		t.Fail()
+	}
+	password, err = Password(&pipeline.Mysql{Password: "plain"})
+	if err != nil || password != "plain" {
+		t.Fail()
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	cfg, err := TLSConfig(&pipeline.Mysql{}, "127.0.0.1:3306")
+	if err != nil || cfg != nil {
+		t.Fail()
+	}
+	cfg, err = TLSConfig(&pipeline.Mysql{TLS: &pipeline.TLS{}}, "mysql.local:3306")
+	if err != nil || cfg.ServerName != "mysql.local" {
+		t.Fail()
+	}
+	if _, err = TLSConfig(&pipeline.Mysql{TLS: &pipeline.TLS{CA: "/not/exist/ca.pem"}}, "mysql.local:3306"); err == nil {
+		t.Fail()
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	m := &pipeline.Mysql{Address: "10.0.0.1", Port: 3306, Endpoints: []*pipeline.Endpoint{{Address: "10.0.0.2", Port: 3306}}}
+	if list := Candidates(m); len(list) != 1 || list[0].Addr() != "10.0.0.1:3306" {
+		t.Error(list)
+	}
+	m.Mode = pipeline.MODE_GTID
+	if list := Candidates(m); len(list) != 2 || list[1].Addr() != "10.0.0.2:3306" {
+		t.Error(list)
+	}
+}
@@ -0,0 +1,269 @@
+package replication
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// CheckLevel result level of a check
+type CheckLevel string
+
+const (
+	// CHECK_OK check passed
+	CHECK_OK CheckLevel = "ok"
+	// CHECK_WARNING pipeline can run, but something may be wrong
+	CHECK_WARNING CheckLevel = "warning"
+	// CHECK_ERROR pipeline can not run
+	CHECK_ERROR CheckLevel = "error"
+)
+
+// Check result of one check
+type Check struct {
+	Name    string     `json:"name"`
+	Level   CheckLevel `json:"level"`
+	Message string     `json:"message"`
+}
+
+// Diagnosis result of pre-flight checks of pipeline
+type Diagnosis struct {
+	PipelineName string   `json:"pipeline_name"`
+	Passed       bool     `json:"passed"`
+	Checks       []*Check `json:"checks"`
+}
+
+func (d *Diagnosis) add(name string, level CheckLevel, format string, a ...interface{}) {
+	d.Checks = append(d.Checks, &Check{Name: name, Level: level, Message: fmt.Sprintf(format, a...)})
+	if level == CHECK_ERROR {
+		d.Passed = false
+	}
+}
+
+// Message returns messages of failed checks
+func (d *Diagnosis) Message() string {
+	msgs := []string{}
+	for _, v := range d.Checks {
+		if v.Level == CHECK_ERROR {
+			msgs = append(msgs, v.Name+": "+v.Message)
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Diagnose checks whether mysql is ready for the pipeline.
+// record is the saved position of the pipeline, pipes are all pipelines for server id collision check
+func Diagnose(p *pipeline.Pipeline, record *pipeline.RecordPosition, pipes []*pipeline.Pipeline) (d *Diagnosis) {
+	d = &Diagnosis{PipelineName: p.Name, Passed: true, Checks: []*Check{}}
+	if p.Mysql == nil {
+		d.add("config", CHECK_ERROR, "mysql config is empty")
+		return
+	}
+	if p.Mysql.Source == pipeline.SOURCE_FILE {
+		diagnoseFiles(d, p.Mysql)
+		return
+	}
+	m := p.Mysql
+	if len(m.Endpoints) > 0 {
+		if m.Mode != pipeline.MODE_GTID {
+			d.add("failover", CHECK_WARNING, "failover endpoints are ignored in position mode, binlog file offsets are specific to a server")
+		} else {
+			d.add("failover", CHECK_OK, "%d failover endpoints", len(m.Endpoints))
+		}
+	}
+	conn := diagnoseConnect(d, m)
+	if conn == nil {
+		return
+	}
+	defer conn.Close()
+	flavor := m.Flavor.YaString()
+	status, err := GetMasterStatus(conn, flavor)
+	if err != nil {
+		d.add("binlog", CHECK_ERROR, "%v", err)
+		return
+	}
+	d.add("binlog", CHECK_OK, "current position %s:%d", status.File, status.Position)
+	diagnoseVariables(d, conn, m)
+	diagnosePrivileges(d, conn)
+	diagnoseServerID(d, conn, p, pipes)
+	diagnoseRecord(d, conn, m, record)
+	return
+}
+
+// diagnoseConnect connects to the first reachable candidate in order, the same as the pipeline does when it starts.
+// Unreachable candidates are warnings, nil is returned if none is reachable
+func diagnoseConnect(d *Diagnosis, m *pipeline.Mysql) (conn *client.Conn) {
+	candidates := Candidates(m)
+	failed := []string{}
+	for _, v := range candidates {
+		var err error
+		if conn, err = Connect(m, v.Addr()); err != nil {
+			failed = append(failed, fmt.Sprintf("connect %s error: %v", v.Addr(), err))
+			continue
+		}
+		for _, msg := range failed {
+			d.add("connection", CHECK_WARNING, "%s", msg)
+		}
+		d.add("connection", CHECK_OK, "connected to %s", v.Addr())
+		return
+	}
+	d.add("connection", CHECK_ERROR, "%s", strings.Join(failed, "; "))
+	return nil
+}
+
+// diagnoseFiles checks config of file source, files are not checked here, because they are local to the node
+// running the pipeline, which reports missing files by event when the pipeline starts
+func diagnoseFiles(d *Diagnosis, m *pipeline.Mysql) {
+	if len(m.BinlogFiles) == 0 && m.BinlogDir == "" {
+		d.add("binlog_files", CHECK_ERROR, "binlog directory and files are empty")
+		return
+	}
+	d.add("binlog_files", CHECK_OK, "binlog files are checked by the node running the pipeline")
+}
+
+// variable returns value of global variable, empty if it does not exist
+func variable(conn mysql.Executer, name string) (val string, err error) {
+	res, err := conn.Execute(fmt.Sprintf("SHOW GLOBAL VARIABLES LIKE '%s'", name))
+	if err != nil || res.RowNumber() == 0 {
+		return
+	}
+	val, err = res.GetString(0, 1)
+	return
+}
+
+func diagnoseVariables(d *Diagnosis, conn *client.Conn, m *pipeline.Mysql) {
+	if format, err := variable(conn, "binlog_format"); err != nil {
+		d.add("binlog_format", CHECK_ERROR, "%v", err)
+	} else if !strings.EqualFold(format, "ROW") {
+		d.add("binlog_format", CHECK_ERROR, "binlog_format is %s, ROW is required", format)
+	} else {
+		d.add("binlog_format", CHECK_OK, "ROW")
+	}
+
+	if image, err := variable(conn, "binlog_row_image"); err != nil {
+		d.add("binlog_row_image", CHECK_WARNING, "%v", err)
+	} else if image != "" && !strings.EqualFold(image, "FULL") {
+		d.add("binlog_row_image", CHECK_WARNING, "binlog_row_image is %s, columns absent in rows are marked missing", image)
+	} else {
+		d.add("binlog_row_image", CHECK_OK, "FULL")
+	}
+
+	if m.RowsQuery {
+		variableName := "binlog_rows_query_log_events"
+		if m.Flavor.YaString() == mysql.MariaDBFlavor {
+			variableName = "binlog_annotate_row_events"
+		}
+		if on, err := variable(conn, variableName); err != nil {
+			d.add("rows_query", CHECK_WARNING, "%v", err)
+		} else if !strings.EqualFold(on, "ON") {
+			d.add("rows_query", CHECK_WARNING, "%s is %s, statements are not added to rows", variableName, on)
+		} else {
+			d.add("rows_query", CHECK_OK, "ON")
+		}
+	}
+
+	if m.Mode != pipeline.MODE_GTID {
+		return
+	}
+	if m.Flavor.YaString() == mysql.MariaDBFlavor {
+		// gtid is always enabled in mariadb 10.0.2 and later
+		d.add("gtid_mode", CHECK_OK, "mariadb gtid")
+		return
+	}
+	if mode, err := variable(conn, "gtid_mode"); err != nil {
+		d.add("gtid_mode", CHECK_ERROR, "%v", err)
+	} else if !strings.EqualFold(mode, "ON") {
+		d.add("gtid_mode", CHECK_ERROR, "gtid_mode is %s, ON is required by pipeline mode gtid", mode)
+	} else {
+		d.add("gtid_mode", CHECK_OK, "ON")
+	}
+}
+
+func diagnosePrivileges(d *Diagnosis, conn *client.Conn) {
+	res, err := conn.Execute("SHOW GRANTS FOR CURRENT_USER()")
+	if err != nil {
+		d.add("privileges", CHECK_WARNING, "show grants error: %v", err)
+		return
+	}
+	grants := make([]string, res.RowNumber())
+	for i := range grants {
+		grants[i], _ = res.GetString(i, 0)
+	}
+	slave, replClient := grantedPrivileges(grants)
+	if !slave {
+		d.add("privileges", CHECK_ERROR, "REPLICATION SLAVE privilege is required")
+		return
+	}
+	if !replClient {
+		d.add("privileges", CHECK_ERROR, "REPLICATION CLIENT privilege is required")
+		return
+	}
+	d.add("privileges", CHECK_OK, "REPLICATION SLAVE, REPLICATION CLIENT")
+}
+
+// grantedPrivileges returns whether replication privileges are granted on *.* by grant statements.
+// BINLOG MONITOR is REPLICATION CLIENT of mariadb 10.5
+func grantedPrivileges(grants []string) (slave bool, replClient bool) {
+	for _, v := range grants {
+		grant := strings.ToUpper(v)
+		on := strings.Index(grant, " ON ")
+		if on < 0 || !strings.HasPrefix(strings.TrimSpace(grant[on+4:]), "*.*") {
+			continue
+		}
+		privileges := grant[:on]
+		if strings.Contains(privileges, "ALL PRIVILEGES") {
+			return true, true
+		}
+		if strings.Contains(privileges, "REPLICATION SLAVE") {
+			slave = true
+		}
+		if strings.Contains(privileges, "REPLICATION CLIENT") || strings.Contains(privileges, "BINLOG MONITOR") ||
+			strings.Contains(privileges, "SUPER") {
+			replClient = true
+		}
+	}
+	return
+}
+
+func diagnoseServerID(d *Diagnosis, conn *client.Conn, p *pipeline.Pipeline, pipes []*pipeline.Pipeline) {
+	id := p.Mysql.ServerId
+	for _, v := range pipes {
+		if v.Name == p.Name || v.Mysql == nil || v.Mysql.Source == pipeline.SOURCE_FILE {
+			continue
+		}
+		if Addr(v.Mysql) == Addr(p.Mysql) && v.Mysql.ServerId == id {
+			d.add("server_id", CHECK_ERROR, "server id %d is used by pipeline %s", id, v.Name)
+			return
+		}
+	}
+	if serverID, err := variable(conn, "server_id"); err == nil && serverID == fmt.Sprint(id) {
+		d.add("server_id", CHECK_ERROR, "server id %d is the same as mysql", id)
+		return
+	}
+	res, err := conn.Execute("SHOW SLAVE HOSTS")
+	if err != nil {
+		d.add("server_id", CHECK_WARNING, "show slave hosts error: %v", err)
+		return
+	}
+	for i := 0; i < res.RowNumber(); i++ {
+		replica, _ := res.GetUint(i, 0)
+		// the running pipeline itself is a replica
+		if uint32(replica) == id && p.Status != pipeline.STATUS_RUN {
+			d.add("server_id", CHECK_ERROR, "server id %d is used by a replica of mysql", id)
+			return
+		}
+	}
+	d.add("server_id", CHECK_OK, "server id %d", id)
+}
+
+// diagnoseRecord checks whether binlog of the saved position still exists
+func diagnoseRecord(d *Diagnosis, conn *client.Conn, m *pipeline.Mysql, record *pipeline.RecordPosition) {
+	risk, err := checkPurge(conn, m, record, nil)
+	if err != nil {
+		d.add("position", CHECK_WARNING, "%v", err)
+		return
+	}
+	d.add("position", risk.Level, "%s", risk.Message)
+}
@@ -0,0 +1,70 @@
+package replication
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestGrantedPrivileges(t *testing.T) {
+	slave, client := grantedPrivileges([]string{
+		"GRANT SELECT, REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO `binlogo`@`%`",
+	})
+	if !slave || !client {
+		t.Fail()
+	}
+	slave, client = grantedPrivileges([]string{
+		"GRANT USAGE ON *.* TO `binlogo`@`%`",
+		"GRANT ALL PRIVILEGES ON `test`.* TO `binlogo`@`%`",
+	})
+	if slave || client {
+		t.Fail()
+	}
+	slave, client = grantedPrivileges([]string{"GRANT ALL PRIVILEGES ON *.* TO 'root'@'localhost' WITH GRANT OPTION"})
+	if !slave || !client {
+		t.Fail()
+	}
+	slave, client = grantedPrivileges([]string{"GRANT REPLICATION SLAVE, BINLOG MONITOR ON *.* TO `binlogo`@`%`"})
+	if !slave || !client {
+		t.Fail()
+	}
+}
+
+func TestDiagnosis(t *testing.T) {
+	d := &Diagnosis{Passed: true}
+	d.add("binlog_row_image", CHECK_WARNING, "binlog_row_image is %s", "MINIMAL")
+	if !d.Passed || d.Message() != "" {
+		t.Fail()
+	}
+	d.add("binlog_format", CHECK_ERROR, "binlog_format is %s", "MIXED")
+	if d.Passed || d.Message() != "binlog_format: binlog_format is MIXED" {
+		t.Fail()
+	}
+}
+
+func TestDiagnoseFiles(t *testing.T) {
+	// files are local to the node running the pipeline, they are not checked by console
+	d := Diagnose(&pipeline.Pipeline{Mysql: &pipeline.Mysql{Source: pipeline.SOURCE_FILE, BinlogFiles: []string{"/not/exist/mysql-bin.000001"}}}, nil, nil)
+	if !d.Passed {
+		t.Error(d.Message())
+	}
+	d = Diagnose(&pipeline.Pipeline{Mysql: &pipeline.Mysql{Source: pipeline.SOURCE_FILE}}, nil, nil)
+	if d.Passed {
+		t.Fail()
+	}
+}
+
+func TestDiagnoseCandidates(t *testing.T) {
+	m := &pipeline.Mysql{Address: "127.0.0.1", Port: 1, Mode: pipeline.MODE_GTID, Endpoints: []*pipeline.Endpoint{{Address: "127.0.0.1", Port: 2}}}
+	d := Diagnose(&pipeline.Pipeline{Mysql: m}, nil, nil)
+	// every candidate is tried before the pipeline is taken as unreachable
+	if d.Passed || !strings.Contains(d.Message(), "127.0.0.1:1") || !strings.Contains(d.Message(), "127.0.0.1:2") {
+		t.Error(d.Message())
+	}
+	m.Mode = pipeline.MODE_POSITION
+	d = Diagnose(&pipeline.Pipeline{Mysql: m}, nil, nil)
+	if d.Passed || strings.Contains(d.Message(), "127.0.0.1:2") {
+		t.Error(d.Message())
+	}
+}
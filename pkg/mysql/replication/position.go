@@ -0,0 +1,256 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// binlogFile file listed by SHOW BINARY LOGS
+type binlogFile struct {
+	name string
+	size uint64
+}
+
+// PositionByTime finds position of the first transaction started at or after t.
+// Binlog files are searched by the time of their first event, then events of the matched file are scanned.
+// GTIDSet of the position is the gtid set executed before the transaction.
+// Binlog of the first reachable candidate of mysql is searched, files of file source are not searched.
+func PositionByTime(ctx context.Context, m *pipeline.Mysql, t time.Time) (pos *pipeline.Position, err error) {
+	if m.Source == pipeline.SOURCE_FILE {
+		err = errors.New("binlog of file source is local to the node running the pipeline, it can not be searched by time")
+		return
+	}
+	e, files, err := binlogFiles(m)
+	if err != nil {
+		return
+	}
+	if len(files) == 0 {
+		err = errors.New("no binlog file found")
+		return
+	}
+	ts := uint32(t.Unix())
+	// index of the last file started at or before t
+	idx := -1
+	low, high := 0, len(files)-1
+	for low <= high {
+		mid := (low + high) / 2
+		var start uint32
+		start, err = fileStartTime(ctx, m, e, files[mid].name)
+		if err != nil {
+			return
+		}
+		if start <= ts {
+			idx = mid
+			low = mid + 1
+		} else {
+			high = mid - 1
+		}
+	}
+	if idx < 0 {
+		err = fmt.Errorf("time is earlier than the oldest binlog file %s", files[0].name)
+		return
+	}
+	return scanFile(ctx, m, e, files, idx, ts)
+}
+
+// binlogFiles returns binlog files of the first reachable candidate of mysql, and the candidate
+func binlogFiles(m *pipeline.Mysql) (e *pipeline.Endpoint, files []binlogFile, err error) {
+	var conn *client.Conn
+	for _, v := range Candidates(m) {
+		if conn, err = Connect(m, v.Addr()); err == nil {
+			e = v
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	res, err := conn.Execute("SHOW BINARY LOGS")
+	if err != nil {
+		return
+	}
+	for i := 0; i < res.RowNumber(); i++ {
+		var f binlogFile
+		if f.name, err = res.GetString(i, 0); err != nil {
+			return
+		}
+		if f.size, err = res.GetUint(i, 1); err != nil {
+			return
+		}
+		files = append(files, f)
+	}
+	return
+}
+
+// Addr returns address of mysql
+func Addr(m *pipeline.Mysql) string {
+	return fmt.Sprintf("%s:%s", m.Address, strconv.Itoa(int(m.Port)))
+}
+
+// newSyncer returns binlog syncer of mysql at endpoint e
+func newSyncer(m *pipeline.Mysql, e *pipeline.Endpoint) (syncer *replication.BinlogSyncer, err error) {
+	password, err := Password(m)
+	if err != nil {
+		return
+	}
+	tlsConfig, err := TLSConfig(m, e.Addr())
+	if err != nil {
+		return
+	}
+	syncer = replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID:  m.ServerId,
+		Flavor:    m.Flavor.YaString(),
+		Host:      e.Address,
+		Port:      e.Port,
+		User:      m.User,
+		Password:  password,
+		TLSConfig: tlsConfig,
+	})
+	return
+}
+
+// fileStartTime returns time of the format description event of binlog file
+func fileStartTime(ctx context.Context, m *pipeline.Mysql, e *pipeline.Endpoint, name string) (ts uint32, err error) {
+	syncer, err := newSyncer(m, e)
+	if err != nil {
+		return
+	}
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(mysql.Position{Name: name, Pos: 4})
+	if err != nil {
+		return
+	}
+	for {
+		var ev *replication.BinlogEvent
+		ev, err = streamer.GetEvent(ctx)
+		if err != nil {
+			return
+		}
+		if _, ok := ev.Event.(*replication.FormatDescriptionEvent); ok {
+			ts = ev.Header.Timestamp
+			return
+		}
+	}
+}
+
+// scanFile scans events of files[idx], returns position of the first transaction started at or after ts,
+// or the start of next file if there is none
+func scanFile(ctx context.Context, m *pipeline.Mysql, e *pipeline.Endpoint, files []binlogFile, idx int, ts uint32) (pos *pipeline.Position, err error) {
+	file := files[idx]
+	last := idx == len(files)-1
+	flavor := m.Flavor.YaString()
+	syncer, err := newSyncer(m, e)
+	if err != nil {
+		return
+	}
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(mysql.Position{Name: file.name, Pos: 4})
+	if err != nil {
+		return
+	}
+	var gset mysql.GTIDSet
+	result := func(name string, p uint32) *pipeline.Position {
+		res := &pipeline.Position{BinlogFile: name, BinlogPosition: p}
+		if gset != nil {
+			res.GTIDSet = gset.String()
+		}
+		return res
+	}
+	// inTrx true if events of a transaction are being read, they are not transaction start
+	inTrx := false
+	for {
+		var ev *replication.BinlogEvent
+		ev, err = streamer.GetEvent(ctx)
+		if err != nil {
+			return
+		}
+		if ev.Header.LogPos == 0 {
+			// fake rotate event
+			continue
+		}
+		start := ev.Header.LogPos - ev.Header.EventSize
+		var gtid string
+		isStart := false
+		switch e := ev.Event.(type) {
+		case *replication.RotateEvent:
+			{
+				return result(string(e.NextLogName), uint32(e.Position)), nil
+			}
+		case *replication.PreviousGTIDsEvent:
+			{
+				if gset, err = mysql.ParseGTIDSet(flavor, e.GTIDSets); err != nil {
+					return
+				}
+			}
+		case *replication.MariadbGTIDListEvent:
+			{
+				list := make([]string, len(e.GTIDs))
+				for i, v := range e.GTIDs {
+					list[i] = v.String()
+				}
+				if gset, err = mysql.ParseGTIDSet(flavor, strings.Join(list, ",")); err != nil {
+					return
+				}
+			}
+		case *replication.GTIDEvent:
+			{
+				isStart = true
+				inTrx = true
+				if e.GNO > 0 {
+					sid := e.SID
+					gtid = fmt.Sprintf("%x-%x-%x-%x-%x:%d", sid[0:4], sid[4:6], sid[6:8], sid[8:10], sid[10:16], e.GNO)
+				}
+			}
+		case *replication.MariadbGTIDEvent:
+			{
+				isStart = true
+				inTrx = true
+				gtid = e.GTID.String()
+			}
+		case *replication.QueryEvent:
+			{
+				query := strings.ToUpper(strings.TrimSpace(string(e.Query)))
+				if !inTrx {
+					isStart = true
+				}
+				// BEGIN starts a multi-statement transaction, other statement such as ddl is a transaction itself
+				inTrx = query == "BEGIN"
+			}
+		case *replication.XIDEvent:
+			{
+				inTrx = false
+			}
+		}
+		if isStart && ev.Header.Timestamp >= ts {
+			return result(file.name, start), nil
+		}
+		if gtid != "" && gset != nil {
+			if err = gset.Update(gtid); err != nil {
+				return
+			}
+		}
+		if last && uint64(ev.Header.LogPos) >= file.size {
+			// reach the end of the newest file when it was listed
+			return result(file.name, ev.Header.LogPos), nil
+		}
+	}
+}
+
+// ParseTime parses time in RFC3339 or 2006-01-02 15:04:05 format, the later is in UTC
+func ParseTime(s string) (t time.Time, err error) {
+	t, err = time.Parse(time.RFC3339, s)
+	if err == nil {
+		return
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", s, time.UTC)
+}
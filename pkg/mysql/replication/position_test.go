@@ -0,0 +1,32 @@
+package replication
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(2021, 11, 22, 3, 0, 0, 0, time.UTC)
+	for _, v := range []string{"2021-11-22 03:00:00", "2021-11-22T11:00:00+08:00", "2021-11-22T03:00:00Z"} {
+		got, err := ParseTime(v)
+		if err != nil {
+			t.Error(err)
+		}
+		if !got.Equal(want) {
+			t.Error(v, got)
+		}
+	}
+	if _, err := ParseTime("2021/11/22"); err == nil {
+		t.Fail()
+	}
+}
+
+func TestPositionByTimeFile(t *testing.T) {
+	m := &pipeline.Mysql{Source: pipeline.SOURCE_FILE, BinlogDir: "/var/lib/mysql"}
+	if _, err := PositionByTime(context.Background(), m, time.Now()); err == nil {
+		t.Fail()
+	}
+}
@@ -0,0 +1,159 @@
+package replication
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// PURGE_WARN_PERCENT percent of binlog retention, lag of pipeline at or over it is warned
+const PURGE_WARN_PERCENT = 80
+
+// PurgeRisk risk of binlog needed by pipeline being purged by mysql
+type PurgeRisk struct {
+	PipelineName string     `json:"pipeline_name"`
+	Level        CheckLevel `json:"level"`
+	Message      string     `json:"message"`
+	// Address of mysql checked, the endpoint the pipeline replicates from
+	Address string `json:"address"`
+	// Position saved position the pipeline resumes from
+	Position *pipeline.Position `json:"position"`
+	// OldestFile oldest binlog file retained by mysql
+	OldestFile string `json:"oldest_file"`
+	// FilesBefore retained binlog files before the file the pipeline needs, -1 if the file is not retained
+	FilesBefore int `json:"files_before"`
+	// Retention seconds binlog is kept by mysql, 0 if binlog is not expired
+	Retention int64 `json:"retention"`
+}
+
+// CheckPurge checks whether binlog of the saved position of pipeline is retained by mysql.
+// lag is lag of the running pipeline, nil if unknown, mysql it replicates from is checked if it is known
+func CheckPurge(p *pipeline.Pipeline, record *pipeline.RecordPosition, lag *pipeline.Lag) (risk *PurgeRisk, err error) {
+	addr := Addr(p.Mysql)
+	if lag != nil && lag.Address != "" {
+		addr = lag.Address
+	}
+	conn, err := Connect(p.Mysql, addr)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	risk, err = checkPurge(conn, p.Mysql, record, lag)
+	if risk != nil {
+		risk.PipelineName = p.Name
+		risk.Address = addr
+	}
+	return
+}
+
+func checkPurge(conn mysql.Executer, m *pipeline.Mysql, record *pipeline.RecordPosition, lag *pipeline.Lag) (risk *PurgeRisk, err error) {
+	if record == nil || record.Pre == nil {
+		risk = &PurgeRisk{Level: CHECK_OK, Message: "no saved position, pipeline starts from current position", FilesBefore: -1}
+		return
+	}
+	res, err := conn.Execute("SHOW BINARY LOGS")
+	if err != nil {
+		return
+	}
+	files := make([]string, res.RowNumber())
+	for i := range files {
+		files[i], _ = res.GetString(i, 0)
+	}
+	purged := ""
+	if m.Mode == pipeline.MODE_GTID && m.Flavor.YaString() == mysql.MySQLFlavor {
+		// purged gtid is not tracked by mariadb
+		if res, err = conn.Execute("SELECT @@GLOBAL.gtid_purged"); err != nil {
+			return
+		}
+		purged, _ = res.GetString(0, 0)
+	}
+	retention, err := binlogRetention(conn)
+	if err != nil {
+		return
+	}
+	risk = purgeRisk(m, record.Pre, files, purged, retention, lag)
+	return
+}
+
+// binlogRetention returns seconds binlog is kept by mysql, binlog_expire_logs_seconds of mysql 8 is used if set,
+// otherwise expire_logs_days. 0 means binlog is not expired
+func binlogRetention(conn mysql.Executer) (seconds int64, err error) {
+	val, err := variable(conn, "binlog_expire_logs_seconds")
+	if err != nil {
+		return
+	}
+	if seconds, _ = strconv.ParseInt(val, 10, 64); seconds > 0 {
+		return
+	}
+	if val, err = variable(conn, "expire_logs_days"); err != nil {
+		return
+	}
+	days, _ := strconv.ParseFloat(val, 64)
+	seconds = int64(days * 86400)
+	return
+}
+
+// purgeRisk compares saved position with binlog files retained by mysql and purged gtid set.
+// Binlog the pipeline needs is newer than its oldest event not checkpointed, so it is retained for retention minus lag
+// at least. If lag is unknown, the needed file being the oldest retained one is warned
+func purgeRisk(m *pipeline.Mysql, pos *pipeline.Position, files []string, purged string, retention int64, lag *pipeline.Lag) (risk *PurgeRisk) {
+	risk = &PurgeRisk{Level: CHECK_OK, Position: pos, FilesBefore: -1, Retention: retention}
+	if len(files) > 0 {
+		risk.OldestFile = files[0]
+	}
+	gtid := m.Mode == pipeline.MODE_GTID && pos.GTIDSet != ""
+	if gtid && purged != "" {
+		saved, err := mysql.ParseGTIDSet(m.Flavor.YaString(), pos.GTIDSet)
+		if err != nil {
+			risk.Level = CHECK_ERROR
+			risk.Message = fmt.Sprintf("saved gtid set %s is invalid: %v", pos.GTIDSet, err)
+			return
+		}
+		purgedSet, err := mysql.ParseGTIDSet(m.Flavor.YaString(), purged)
+		if err == nil && !saved.Contain(purgedSet) {
+			risk.Level = CHECK_ERROR
+			risk.Message = fmt.Sprintf("binlog of saved gtid set %s has been purged, purged %s", pos.GTIDSet, purged)
+			return
+		}
+	}
+	for i, v := range files {
+		if v == pos.BinlogFile {
+			risk.FilesBefore = i
+			break
+		}
+	}
+	if risk.FilesBefore < 0 && !gtid {
+		if pos.BinlogFile == "" {
+			risk.Message = "no saved binlog file"
+			return
+		}
+		risk.Level = CHECK_ERROR
+		risk.Message = fmt.Sprintf("saved binlog file %s does not exist, it may have been purged, oldest retained file %s",
+			pos.BinlogFile, risk.OldestFile)
+		return
+	}
+	switch {
+	case lag != nil && retention > 0 && lag.Seconds*100 >= retention*PURGE_WARN_PERCENT:
+		{
+			risk.Level = CHECK_WARNING
+			risk.Message = fmt.Sprintf("lag of pipeline %d seconds is close to binlog retention %d seconds", lag.Seconds, retention)
+		}
+	case lag == nil && risk.FilesBefore == 0 && len(files) > 1:
+		{
+			risk.Level = CHECK_WARNING
+			risk.Message = fmt.Sprintf("binlog file %s needed by pipeline is the oldest retained file", pos.BinlogFile)
+		}
+	case risk.FilesBefore >= 0:
+		{
+			risk.Message = fmt.Sprintf("saved position %s:%d", pos.BinlogFile, pos.BinlogPosition)
+		}
+	default:
+		{
+			// binlog files differ between servers, the gtid set decides
+			risk.Message = fmt.Sprintf("saved gtid set %s", pos.GTIDSet)
+		}
+	}
+	return
+}
@@ -0,0 +1,54 @@
+package replication
+
+import (
+	"testing"
+
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestPurgeRisk(t *testing.T) {
+	m := &pipeline.Mysql{Flavor: pipeline.FLAVOR_MYSQL, Mode: pipeline.MODE_POSITION}
+	files := []string{"mysql-bin.000005", "mysql-bin.000006", "mysql-bin.000007", "mysql-bin.000008", "mysql-bin.000009"}
+	risk := purgeRisk(m, &pipeline.Position{BinlogFile: "mysql-bin.000009", BinlogPosition: 4}, files, "", 86400, nil)
+	if risk.Level != CHECK_OK || risk.FilesBefore != 4 || risk.OldestFile != "mysql-bin.000005" {
+		t.Error(risk)
+	}
+	// caught up pipeline has no risk, even if its file is old
+	risk = purgeRisk(m, &pipeline.Position{BinlogFile: "mysql-bin.000005", BinlogPosition: 4}, files, "", 86400, &pipeline.Lag{})
+	if risk.Level != CHECK_OK || risk.FilesBefore != 0 {
+		t.Error(risk)
+	}
+	risk = purgeRisk(m, &pipeline.Position{BinlogFile: "mysql-bin.000006", BinlogPosition: 4}, files, "", 86400, &pipeline.Lag{Seconds: 72000})
+	if risk.Level != CHECK_WARNING || risk.FilesBefore != 1 {
+		t.Error(risk)
+	}
+	// binlog is not expired
+	risk = purgeRisk(m, &pipeline.Position{BinlogFile: "mysql-bin.000006", BinlogPosition: 4}, files, "", 0, &pipeline.Lag{Seconds: 72000})
+	if risk.Level != CHECK_OK {
+		t.Error(risk)
+	}
+	// lag unknown, the oldest retained file is warned
+	risk = purgeRisk(m, &pipeline.Position{BinlogFile: "mysql-bin.000005", BinlogPosition: 4}, files, "", 86400, nil)
+	if risk.Level != CHECK_WARNING {
+		t.Error(risk)
+	}
+	risk = purgeRisk(m, &pipeline.Position{BinlogFile: "mysql-bin.000003", BinlogPosition: 4}, files, "", 86400, nil)
+	if risk.Level != CHECK_ERROR || risk.FilesBefore != -1 {
+		t.Error(risk)
+	}
+
+	m.Mode = pipeline.MODE_GTID
+	uuid := "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	risk = purgeRisk(m, &pipeline.Position{GTIDSet: uuid + ":1-100"}, files, uuid+":1-20", 86400, nil)
+	if risk.Level != CHECK_OK {
+		t.Error(risk)
+	}
+	risk = purgeRisk(m, &pipeline.Position{GTIDSet: uuid + ":1-10"}, files, uuid+":1-20", 86400, nil)
+	if risk.Level != CHECK_ERROR {
+		t.Error(risk)
+	}
+	risk = purgeRisk(m, &pipeline.Position{BinlogFile: "mysql-bin.000005", GTIDSet: uuid + ":1-100"}, files, uuid+":1-20", 86400, &pipeline.Lag{Seconds: 80000})
+	if risk.Level != CHECK_WARNING {
+		t.Error(risk)
+	}
+}
@@ -1,5 +1,45 @@
 package replication
 
-// GetMasterStatus todo
-func GetMasterStatus() {
+import (
+	"errors"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// MasterStatus current binlog position of mysql
+type MasterStatus struct {
+	File     string `json:"file"`
+	Position uint32 `json:"position"`
+	GTIDSet  string `json:"gtid_set"`
+}
+
+// GetMasterStatus returns current binlog file, position and executed gtid set of mysql.
+// GTIDSet is empty if gtid is not enabled
+func GetMasterStatus(conn mysql.Executer, flavor string) (status *MasterStatus, err error) {
+	res, err := conn.Execute("SHOW MASTER STATUS")
+	if err != nil {
+		return
+	}
+	if res.RowNumber() == 0 {
+		err = errors.New("binlog is not enabled")
+		return
+	}
+	status = &MasterStatus{}
+	if status.File, err = res.GetString(0, 0); err != nil {
+		return
+	}
+	pos, err := res.GetUint(0, 1)
+	if err != nil {
+		return
+	}
+	status.Position = uint32(pos)
+	query := "SELECT @@GLOBAL.GTID_EXECUTED"
+	if flavor == mysql.MariaDBFlavor {
+		query = "SELECT @@GLOBAL.gtid_current_pos"
+	}
+	if res, err = conn.Execute(query); err != nil {
+		return
+	}
+	status.GTIDSet, err = res.GetString(0, 0)
+	return
 }
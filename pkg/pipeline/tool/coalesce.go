@@ -0,0 +1,23 @@
+package tool
+
+import (
+	"errors"
+
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// CoalesceCheck returns error if coalescing of pipeline is illegal
+func CoalesceCheck(p *pipeline.Pipeline) (err error) {
+	c := p.Coalesce
+	if c == nil {
+		return
+	}
+	if p.Mysql == nil || !p.Mysql.HeadSchema {
+		return errors.New("coalesce needs head_schema of mysql, rows are keyed by primary key in head")
+	}
+	if c.Interval < 0 || c.Count < 0 {
+		return errors.New("interval and count of coalesce can not be negative")
+	}
+	_, err = NewTables(c.Rule, c.Syntax)
+	return
+}
@@ -0,0 +1,198 @@
+package tool
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jin06/binlogo/pkg/secret"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// ColumnRule compiled column rule
+type ColumnRule struct {
+	Rule    *pipeline.ColumnRule
+	tables  *matcher
+	columns map[string]bool
+	salt    string
+}
+
+// NewColumnRules returns compiled column rules in order, salt references are resolved.
+// Unlike filters, an illegal rule is an error, columns must never leave unmasked by mistake
+func NewColumnRules(rules []*pipeline.ColumnRule) (res []*ColumnRule, err error) {
+	for i, v := range rules {
+		var r *ColumnRule
+		if r, err = CompileColumnRule(v); err != nil {
+			err = fmt.Errorf("column rule %d: %v", i, err)
+			return
+		}
+		if v.SaltRef != "" {
+			if r.salt, err = secret.Resolve(v.SaltRef); err != nil {
+				err = fmt.Errorf("column rule %d: resolve salt: %v", i, err)
+				return
+			}
+		}
+		res = append(res, r)
+	}
+	return
+}
+
+// ColumnCheck returns error if column rule is illegal
+func ColumnCheck(r *pipeline.ColumnRule) (err error) {
+	_, err = CompileColumnRule(r)
+	return
+}
+
+// CompileColumnRule returns compiled column rule, salt reference is not resolved
+func CompileColumnRule(v *pipeline.ColumnRule) (r *ColumnRule, err error) {
+	if v.Rule == "" {
+		err = errors.New("rule is empty")
+		return
+	}
+	switch v.Action {
+	case pipeline.COLUMN_INCLUDE, pipeline.COLUMN_EXCLUDE, pipeline.COLUMN_HASH, pipeline.COLUMN_NULL:
+	case pipeline.COLUMN_MASK:
+		{
+			if v.KeepPrefix < 0 || v.KeepSuffix < 0 {
+				err = errors.New("keep_prefix and keep_suffix of mask can not be negative")
+				return
+			}
+		}
+	default:
+		{
+			err = fmt.Errorf("action %s is not supported, supported actions are include, exclude, hash, mask and null", v.Action)
+			return
+		}
+	}
+	if len(v.Columns) == 0 {
+		err = errors.New("columns are empty")
+		return
+	}
+	r = &ColumnRule{Rule: v, columns: map[string]bool{}, salt: v.Salt}
+	for _, c := range v.Columns {
+		if c == "" {
+			err = errors.New("column name is empty")
+			return
+		}
+		r.columns[strings.ToLower(c)] = true
+	}
+	r.tables, err = compileTables(v.Rule, v.Syntax)
+	return
+}
+
+// MatchTable returns true if rule applies to table
+func (r *ColumnRule) MatchTable(database string, table string) bool {
+	return r.tables.match(database, table)
+}
+
+// CheckTables returns error if no table matches rule or any column of rule is in none of the matched tables.
+// tables are columns of tables keyed by database.table
+func (r *ColumnRule) CheckTables(tables map[string][]string) (err error) {
+	found := map[string]bool{}
+	matched := false
+	for name, columns := range tables {
+		arr := strings.SplitN(name, ".", 2)
+		if len(arr) != 2 || !r.MatchTable(arr[0], arr[1]) {
+			continue
+		}
+		matched = true
+		for _, c := range columns {
+			found[strings.ToLower(c)] = true
+		}
+	}
+	if !matched {
+		return fmt.Errorf("no table matches %s", r.Rule.Rule)
+	}
+	var missing []string
+	for _, c := range r.Rule.Columns {
+		if !found[strings.ToLower(c)] {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("columns %s do not exist in tables matching %s", strings.Join(missing, ", "), r.Rule.Rule)
+	}
+	return
+}
+
+// Drop returns true if column is dropped by include or exclude rule
+func (r *ColumnRule) Drop(column string) bool {
+	listed := r.columns[strings.ToLower(column)]
+	switch r.Rule.Action {
+	case pipeline.COLUMN_INCLUDE:
+		{
+			return !listed
+		}
+	case pipeline.COLUMN_EXCLUDE:
+		{
+			return listed
+		}
+	}
+	return false
+}
+
+// Value returns value of column after hash, mask or null rule, NULL is kept as NULL
+func (r *ColumnRule) Value(column string, val interface{}) interface{} {
+	if val == nil || !r.columns[strings.ToLower(column)] {
+		return val
+	}
+	switch r.Rule.Action {
+	case pipeline.COLUMN_HASH:
+		{
+			sum := sha256.Sum256([]byte(r.salt + valueString(val)))
+			return hex.EncodeToString(sum[:])
+		}
+	case pipeline.COLUMN_MASK:
+		{
+			return mask(valueString(val), r.Rule.KeepPrefix, r.Rule.KeepSuffix)
+		}
+	case pipeline.COLUMN_NULL:
+		{
+			return nil
+		}
+	}
+	return val
+}
+
+// Apply returns copy of values after rule
+func (r *ColumnRule) Apply(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	res := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if !r.Drop(k) {
+			res[k] = r.Value(k, v)
+		}
+	}
+	return res
+}
+
+// Names returns names of columns not dropped by rule
+func (r *ColumnRule) Names(names []string) (res []string) {
+	if names == nil {
+		return nil
+	}
+	res = []string{}
+	for _, v := range names {
+		if !r.Drop(v) {
+			res = append(res, v)
+		}
+	}
+	return
+}
+
+// mask replaces characters of s with * except prefix and suffix, all characters are replaced
+// if s is not longer than prefix and suffix
+func mask(s string, prefix int, suffix int) string {
+	runes := []rune(s)
+	if prefix+suffix >= len(runes) {
+		prefix, suffix = 0, 0
+	}
+	for i := prefix; i < len(runes)-suffix; i++ {
+		runes[i] = '*'
+	}
+	return string(runes)
+}
@@ -0,0 +1,91 @@
+package tool
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestColumnRule(t *testing.T) {
+	rules, err := NewColumnRules([]*pipeline.ColumnRule{
+		{Rule: "user.*", Syntax: pipeline.FILTER_SYNTAX_GLOB, Action: pipeline.COLUMN_EXCLUDE, Columns: []string{"Password_Hash"}},
+		{Rule: "user.account", Action: pipeline.COLUMN_HASH, Columns: []string{"id_card"}, Salt: "salt"},
+		{Rule: "user", Action: pipeline.COLUMN_MASK, Columns: []string{"phone"}, KeepPrefix: 3, KeepSuffix: 2},
+		{Rule: "user.account", Action: pipeline.COLUMN_NULL, Columns: []string{"email"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]interface{}{
+		"id":            int64(1),
+		"password_hash": "x",
+		"id_card":       "110101",
+		"phone":         "13812345678",
+		"email":         "a@b.c",
+	}
+	for _, r := range rules {
+		if r.MatchTable("user", "account") {
+			values = r.Apply(values)
+		}
+	}
+	sum := sha256.Sum256([]byte("salt110101"))
+	if _, ok := values["password_hash"]; ok {
+		t.Fail()
+	}
+	if values["id_card"] != hex.EncodeToString(sum[:]) {
+		t.Error(values["id_card"])
+	}
+	if values["phone"] != "138******78" {
+		t.Error(values["phone"])
+	}
+	if v, ok := values["email"]; !ok || v != nil {
+		t.Error(v)
+	}
+	if values["id"] != int64(1) {
+		t.Fail()
+	}
+	if rules[1].MatchTable("user", "log") {
+		t.Fail()
+	}
+	if mask("abc", 2, 2) != "***" {
+		t.Fail()
+	}
+	include, _ := CompileColumnRule(&pipeline.ColumnRule{Rule: "user.account", Action: pipeline.COLUMN_INCLUDE, Columns: []string{"id"}})
+	if names := include.Names([]string{"id", "phone"}); len(names) != 1 || names[0] != "id" {
+		t.Error(names)
+	}
+}
+
+func TestColumnCheck(t *testing.T) {
+	if err := ColumnCheck(&pipeline.ColumnRule{Rule: "user.account", Action: "encrypt", Columns: []string{"phone"}}); err == nil {
+		t.Fail()
+	}
+	if err := ColumnCheck(&pipeline.ColumnRule{Rule: "user.account", Action: pipeline.COLUMN_NULL}); err == nil {
+		t.Fail()
+	}
+	if err := ColumnCheck(&pipeline.ColumnRule{Rule: "user.account", Action: pipeline.COLUMN_MASK, Columns: []string{"phone"}, KeepPrefix: -1}); err == nil {
+		t.Fail()
+	}
+	r, err := CompileColumnRule(&pipeline.ColumnRule{Rule: "tenant_*.user", Syntax: pipeline.FILTER_SYNTAX_GLOB, Action: pipeline.COLUMN_HASH, Columns: []string{"phone", "ID_CARD"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := map[string][]string{
+		"tenant_1.user": {"id", "phone"},
+		"tenant_2.user": {"id", "phone", "id_card"},
+		"mall.order":    {"id"},
+	}
+	if err = r.CheckTables(tables); err != nil {
+		t.Error(err)
+	}
+	r.Rule.Columns = append(r.Rule.Columns, "mobile")
+	if err = r.CheckTables(tables); err == nil || err.Error() != "columns mobile do not exist in tables matching tenant_*.user" {
+		t.Error(err)
+	}
+	r, _ = CompileColumnRule(&pipeline.ColumnRule{Rule: "shop.user", Action: pipeline.COLUMN_HASH, Columns: []string{"phone"}})
+	if err = r.CheckTables(tables); err == nil {
+		t.Fail()
+	}
+}
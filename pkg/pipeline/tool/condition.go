@@ -0,0 +1,654 @@
+package tool
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+// Condition compiled row predicate of filter, like status = 'paid' or tenant_id IN (3,7).
+// It supports comparisons = != <> < <= > >=, [NOT] IN, IS [NOT] NULL, AND, OR, NOT and parentheses.
+// Columns are values of the row, old.column and new.column are values before and after update.
+// Comparisons with NULL are unknown and unknown conditions do not match, as in mysql
+type Condition struct {
+	expr condExpr
+}
+
+// ParseCondition returns compiled condition, error describes where the condition is wrong
+func ParseCondition(s string) (c *Condition, err error) {
+	tokens, err := lexCondition(s)
+	if err != nil {
+		return
+	}
+	p := &condParser{tokens: tokens}
+	expr, err := p.or()
+	if err != nil {
+		return
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		err = p.unexpected(tok, "AND, OR or end of condition")
+		return
+	}
+	c = &Condition{expr: expr}
+	return
+}
+
+// Match returns true if condition is true for row. Unqualified columns are read from row,
+// old and new are before and after images, nil if the event has no such image
+func (c *Condition) Match(row map[string]interface{}, old map[string]interface{}, new map[string]interface{}) bool {
+	return c.expr.eval(&condRow{row: row, old: old, new: new}) == condTrue
+}
+
+// condValue three valued logic of sql
+type condValue int
+
+const (
+	condFalse condValue = iota
+	condTrue
+	condUnknown
+)
+
+func condBool(b bool) condValue {
+	if b {
+		return condTrue
+	}
+	return condFalse
+}
+
+type condRow struct {
+	row map[string]interface{}
+	old map[string]interface{}
+	new map[string]interface{}
+}
+
+type condExpr interface {
+	eval(r *condRow) condValue
+}
+
+// operand column or literal of comparison
+type operand struct {
+	// image is empty for row, "old" or "new"
+	image  string
+	column string
+	value  interface{}
+}
+
+func (o *operand) get(r *condRow) interface{} {
+	if o.column == "" {
+		return o.value
+	}
+	switch o.image {
+	case "old":
+		{
+			return r.old[o.column]
+		}
+	case "new":
+		{
+			return r.new[o.column]
+		}
+	}
+	return r.row[o.column]
+}
+
+type logicExpr struct {
+	and   bool
+	left  condExpr
+	right condExpr
+}
+
+func (e *logicExpr) eval(r *condRow) condValue {
+	left := e.left.eval(r)
+	if e.and && left == condFalse || !e.and && left == condTrue {
+		return left
+	}
+	right := e.right.eval(r)
+	if e.and && right == condFalse || !e.and && right == condTrue {
+		return right
+	}
+	if left == condUnknown || right == condUnknown {
+		return condUnknown
+	}
+	return left
+}
+
+type notExpr struct {
+	expr condExpr
+}
+
+func (e *notExpr) eval(r *condRow) condValue {
+	return negate(e.expr.eval(r))
+}
+
+func negate(v condValue) condValue {
+	switch v {
+	case condTrue:
+		{
+			return condFalse
+		}
+	case condFalse:
+		{
+			return condTrue
+		}
+	}
+	return v
+}
+
+type compareExpr struct {
+	op    string
+	left  *operand
+	right *operand
+}
+
+func (e *compareExpr) eval(r *condRow) condValue {
+	cmp, ok := compareValues(e.left.get(r), e.right.get(r))
+	if !ok {
+		return condUnknown
+	}
+	switch e.op {
+	case "=":
+		{
+			return condBool(cmp == 0)
+		}
+	case "!=", "<>":
+		{
+			return condBool(cmp != 0)
+		}
+	case "<":
+		{
+			return condBool(cmp < 0)
+		}
+	case "<=":
+		{
+			return condBool(cmp <= 0)
+		}
+	case ">":
+		{
+			return condBool(cmp > 0)
+		}
+	default:
+		{
+			return condBool(cmp >= 0)
+		}
+	}
+}
+
+type inExpr struct {
+	not    bool
+	left   *operand
+	values []*operand
+}
+
+func (e *inExpr) eval(r *condRow) condValue {
+	left := e.left.get(r)
+	res := condFalse
+	for _, v := range e.values {
+		cmp, ok := compareValues(left, v.get(r))
+		if !ok {
+			res = condUnknown
+			continue
+		}
+		if cmp == 0 {
+			res = condTrue
+			break
+		}
+	}
+	if e.not {
+		return negate(res)
+	}
+	return res
+}
+
+type nullExpr struct {
+	not  bool
+	left *operand
+}
+
+func (e *nullExpr) eval(r *condRow) condValue {
+	return condBool((e.left.get(r) == nil) != e.not)
+}
+
+// compareValues compares values as numbers if any of them is a number, otherwise as strings.
+// ok is false if any of them is NULL
+func compareValues(a interface{}, b interface{}) (cmp int, ok bool) {
+	if a == nil || b == nil {
+		return
+	}
+	ok = true
+	na, aNum := number(a)
+	nb, bNum := number(b)
+	if aNum || bNum {
+		if !aNum {
+			na, aNum = parseNumber(valueString(a))
+		}
+		if !bNum {
+			nb, bNum = parseNumber(valueString(b))
+		}
+		if aNum && bNum {
+			cmp = na.Cmp(nb)
+			return
+		}
+	}
+	cmp = strings.Compare(valueString(a), valueString(b))
+	return
+}
+
+// number returns exact value of integer, float and number literal of condition
+func number(v interface{}) (r *big.Rat, ok bool) {
+	r = new(big.Rat)
+	ok = true
+	switch val := v.(type) {
+	case *big.Rat:
+		{
+			r = val
+		}
+	case int:
+		{
+			r.SetInt64(int64(val))
+		}
+	case int8:
+		{
+			r.SetInt64(int64(val))
+		}
+	case int16:
+		{
+			r.SetInt64(int64(val))
+		}
+	case int32:
+		{
+			r.SetInt64(int64(val))
+		}
+	case int64:
+		{
+			r.SetInt64(val)
+		}
+	case uint:
+		{
+			r.SetInt(new(big.Int).SetUint64(uint64(val)))
+		}
+	case uint8:
+		{
+			r.SetInt64(int64(val))
+		}
+	case uint16:
+		{
+			r.SetInt64(int64(val))
+		}
+	case uint32:
+		{
+			r.SetInt64(int64(val))
+		}
+	case uint64:
+		{
+			r.SetInt(new(big.Int).SetUint64(val))
+		}
+	case float32:
+		{
+			r.SetFloat64(float64(val))
+		}
+	case float64:
+		{
+			r.SetFloat64(val)
+		}
+	case bool:
+		{
+			if val {
+				r.SetInt64(1)
+			}
+		}
+	default:
+		{
+			ok = false
+		}
+	}
+	return
+}
+
+// parseNumber parses decimal string, e.g. decimal column or numeric string compared with number
+func parseNumber(s string) (r *big.Rat, ok bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.ContainsAny(s, "/eE") {
+		// fractions and exponents are accepted by big.Rat, but they are not decimals
+		return
+	}
+	return new(big.Rat).SetString(s)
+}
+
+func valueString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		{
+			return val
+		}
+	case []byte:
+		{
+			return string(val)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	// quoted identifier, never a keyword
+	quoted bool
+}
+
+func (t token) keyword(k string) bool {
+	return t.kind == tokenIdent && !t.quoted && strings.EqualFold(t.text, k)
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of condition"
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+func lexCondition(s string) (tokens []token, err error) {
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			{
+				i++
+			}
+		case c == '\'' || c == '"':
+			{
+				start := i
+				var b strings.Builder
+				i++
+				closed := false
+				for i < len(runes) {
+					if runes[i] == '\\' && i+1 < len(runes) {
+						b.WriteRune(runes[i+1])
+						i += 2
+						continue
+					}
+					if runes[i] == c {
+						if i+1 < len(runes) && runes[i+1] == c {
+							b.WriteRune(c)
+							i += 2
+							continue
+						}
+						closed = true
+						i++
+						break
+					}
+					b.WriteRune(runes[i])
+					i++
+				}
+				if !closed {
+					err = fmt.Errorf("condition: string at position %d is not closed", start+1)
+					return
+				}
+				tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: start})
+			}
+		case c == '`':
+			{
+				start := i
+				i++
+				for i < len(runes) && runes[i] != '`' {
+					i++
+				}
+				if i == len(runes) {
+					err = fmt.Errorf("condition: identifier at position %d is not closed", start+1)
+					return
+				}
+				tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start+1 : i]), pos: start, quoted: true})
+				i++
+			}
+		case unicode.IsDigit(c) || (c == '-' || c == '.') && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			{
+				start := i
+				i++
+				for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+					i++
+				}
+				tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), pos: start})
+			}
+		case unicode.IsLetter(c) || c == '_':
+			{
+				start := i
+				for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$') {
+					i++
+				}
+				tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+			}
+		default:
+			{
+				op := string(c)
+				if i+1 < len(runes) {
+					switch two := string(runes[i : i+2]); two {
+					case "!=", "<>", "<=", ">=":
+						{
+							op = two
+						}
+					}
+				}
+				if !conditionOperators[op] && !punctuations[op] {
+					err = fmt.Errorf("condition: unexpected character '%s' at position %d", op, i+1)
+					return
+				}
+				tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+				i += len([]rune(op))
+			}
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes)})
+	return
+}
+
+var conditionOperators = map[string]bool{"=": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true}
+
+var punctuations = map[string]bool{"(": true, ")": true, ",": true, ".": true}
+
+type condParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *condParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *condParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *condParser) unexpected(t token, expected string) error {
+	return fmt.Errorf("condition: unexpected %s at position %d, expected %s", t, t.pos+1, expected)
+}
+
+func (p *condParser) or() (expr condExpr, err error) {
+	if expr, err = p.and(); err != nil {
+		return
+	}
+	for p.peek().keyword("OR") {
+		p.next()
+		var right condExpr
+		if right, err = p.and(); err != nil {
+			return
+		}
+		expr = &logicExpr{left: expr, right: right}
+	}
+	return
+}
+
+func (p *condParser) and() (expr condExpr, err error) {
+	if expr, err = p.not(); err != nil {
+		return
+	}
+	for p.peek().keyword("AND") {
+		p.next()
+		var right condExpr
+		if right, err = p.not(); err != nil {
+			return
+		}
+		expr = &logicExpr{and: true, left: expr, right: right}
+	}
+	return
+}
+
+func (p *condParser) not() (expr condExpr, err error) {
+	if p.peek().keyword("NOT") {
+		p.next()
+		if expr, err = p.not(); err != nil {
+			return
+		}
+		expr = &notExpr{expr: expr}
+		return
+	}
+	if t := p.peek(); t.kind == tokenOperator && t.text == "(" {
+		p.next()
+		if expr, err = p.or(); err != nil {
+			return
+		}
+		if t = p.next(); t.kind != tokenOperator || t.text != ")" {
+			err = p.unexpected(t, "')'")
+		}
+		return
+	}
+	return p.predicate()
+}
+
+func (p *condParser) predicate() (expr condExpr, err error) {
+	left, err := p.operand()
+	if err != nil {
+		return
+	}
+	t := p.next()
+	switch {
+	case t.keyword("IS"):
+		{
+			e := &nullExpr{left: left}
+			if p.peek().keyword("NOT") {
+				p.next()
+				e.not = true
+			}
+			if t = p.next(); !t.keyword("NULL") {
+				err = p.unexpected(t, "NULL")
+				return
+			}
+			expr = e
+		}
+	case t.keyword("IN"), t.keyword("NOT"):
+		{
+			e := &inExpr{left: left, not: t.keyword("NOT")}
+			if e.not {
+				if t = p.next(); !t.keyword("IN") {
+					err = p.unexpected(t, "IN")
+					return
+				}
+			}
+			if t = p.next(); t.kind != tokenOperator || t.text != "(" {
+				err = p.unexpected(t, "'('")
+				return
+			}
+			for {
+				var v *operand
+				if v, err = p.operand(); err != nil {
+					return
+				}
+				e.values = append(e.values, v)
+				t = p.next()
+				if t.kind == tokenOperator && t.text == ")" {
+					break
+				}
+				if t.kind != tokenOperator || t.text != "," {
+					err = p.unexpected(t, "',' or ')'")
+					return
+				}
+			}
+			expr = e
+		}
+	case t.kind == tokenOperator && conditionOperators[t.text]:
+		{
+			var right *operand
+			if right, err = p.operand(); err != nil {
+				return
+			}
+			expr = &compareExpr{op: t.text, left: left, right: right}
+		}
+	default:
+		{
+			err = p.unexpected(t, "comparison operator, IN or IS")
+		}
+	}
+	return
+}
+
+func (p *condParser) operand() (o *operand, err error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		{
+			o = &operand{value: t.text}
+		}
+	case tokenNumber:
+		{
+			val, ok := parseNumber(t.text)
+			if !ok {
+				err = fmt.Errorf("condition: invalid number '%s' at position %d", t.text, t.pos+1)
+				return
+			}
+			o = &operand{value: val}
+		}
+	case tokenIdent:
+		{
+			switch {
+			case t.keyword("NULL"):
+				{
+					o = &operand{}
+				}
+			case t.keyword("TRUE"), t.keyword("FALSE"):
+				{
+					o = &operand{value: t.keyword("TRUE")}
+				}
+			case t.keyword("AND"), t.keyword("OR"), t.keyword("NOT"), t.keyword("IN"), t.keyword("IS"):
+				{
+					err = p.unexpected(t, "column or value")
+				}
+			default:
+				{
+					o = &operand{column: t.text}
+					if dot := p.peek(); dot.kind == tokenOperator && dot.text == "." {
+						if !strings.EqualFold(t.text, "old") && !strings.EqualFold(t.text, "new") {
+							err = fmt.Errorf("condition: unknown row image '%s' at position %d, expected old or new", t.text, t.pos+1)
+							return
+						}
+						p.next()
+						column := p.next()
+						if column.kind != tokenIdent {
+							err = p.unexpected(column, "column")
+							return
+						}
+						o = &operand{image: strings.ToLower(t.text), column: column.text}
+					}
+				}
+			}
+		}
+	default:
+		{
+			err = p.unexpected(t, "column or value")
+		}
+	}
+	return
+}
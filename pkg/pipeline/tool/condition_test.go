@@ -0,0 +1,68 @@
+package tool
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCondition(t *testing.T) {
+	row := map[string]interface{}{
+		"status":    "paid",
+		"tenant_id": int64(7),
+		"amount":    "10.50",
+		"note":      nil,
+		"id":        uint64(18446744073709551615),
+	}
+	cases := map[string]bool{
+		"status = 'paid'":                            true,
+		"status != 'paid'":                           false,
+		"tenant_id IN (3, 7)":                        true,
+		"tenant_id NOT IN (3, 8)":                    true,
+		"tenant_id in (3, null)":                     false,
+		"tenant_id NOT IN (3, NULL)":                 false,
+		"amount > 10.2 AND amount <= 10.5":           true,
+		"note IS NULL":                               true,
+		"note IS NOT NULL OR status = 'paid'":        true,
+		"note = 'x'":                                 false,
+		"NOT note = 'x'":                             false,
+		"NOT (status = 'new' OR tenant_id < 7)":      true,
+		"`status` = \"paid\"":                        true,
+		"id = 18446744073709551615":                  true,
+		"tenant_id = '7'":                            true,
+		"status = 'it''s'":                           false,
+		"old.status = 'new' AND new.status = 'paid'": true,
+		"missing IS NULL":                            true,
+	}
+	old := map[string]interface{}{"status": "new"}
+	for s, expected := range cases {
+		c, err := ParseCondition(s)
+		if err != nil {
+			t.Error(s, err)
+			continue
+		}
+		if c.Match(row, old, row) != expected {
+			t.Error(s, !expected)
+		}
+	}
+}
+
+func TestParseConditionError(t *testing.T) {
+	cases := map[string]string{
+		"status = ":        "unexpected end of condition at position 10",
+		"status == 'paid'": "unexpected '=' at position 9",
+		"status = 'paid":   "string at position 10 is not closed",
+		"tenant_id IN 3":   "expected '('",
+		"a = 1 b = 2":      "unexpected 'b' at position 7",
+		"row.status = 'a'": "unknown row image 'row'",
+		"status ! 'paid'":  "unexpected character '!' at position 8",
+		"(status = 'paid'": "expected ')'",
+		"status IS NOT 1":  "expected NULL",
+		"status IN (1, 2":  "expected ',' or ')'",
+	}
+	for s, expected := range cases {
+		_, err := ParseCondition(s)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Error(s, err)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package tool
 import (
 	"errors"
 	"fmt"
+	"path"
+	"regexp"
 	"strings"
 
 	"github.com/jin06/binlogo/app/pipeline/message"
@@ -18,36 +20,76 @@ func NewFilter(filters []*pipeline.Filter) (f *Filter) {
 		TableWhite: map[string]bool{},
 	}
 	for _, v := range filters {
-		if !FilterVerify(v) {
+		if v.Kind != pipeline.FILTER_KIND_TABLE {
 			continue
 		}
-		arr := strings.Split(v.Rule, ".")
-		if len(arr) == 1 {
-			if v.Type == pipeline.FILTER_BLACK {
-				f.DBBlack[v.Rule] = true
-			} else {
-				f.DBWhite[v.Rule] = true
-			}
-		} else {
-			if v.Type == pipeline.FILTER_BLACK {
-				f.TableBlack[v.Rule] = true
-			} else {
-				f.TableWhite[v.Rule] = true
+		m, err := compileRule(v)
+		if err != nil {
+			continue
+		}
+		black := v.Type == pipeline.FILTER_BLACK
+		if m.exact {
+			table := strings.Contains(v.Rule, ".")
+			switch {
+			case table && black:
+				{
+					f.TableBlack[v.Rule] = true
+				}
+			case table:
+				{
+					f.TableWhite[v.Rule] = true
+				}
+			case black:
+				{
+					f.DBBlack[v.Rule] = true
+				}
+			default:
+				{
+					f.DBWhite[v.Rule] = true
+				}
+			}
+			continue
+		}
+		switch {
+		case m.isTable() && black:
+			{
+				f.tableBlackRules = append(f.tableBlackRules, m)
+			}
+		case m.isTable():
+			{
+				f.tableWhiteRules = append(f.tableWhiteRules, m)
+			}
+		case black:
+			{
+				f.dbBlackRules = append(f.dbBlackRules, m)
+			}
+		default:
+			{
+				f.dbWhiteRules = append(f.dbWhiteRules, m)
 			}
 		}
 	}
 	return
 }
 
-// Filter verify message, filter or pass message by blacklist and whitelist
+// Filter verify message, filter or pass message by blacklist and whitelist.
+// Rules are checked in order of precedence: database white list, table white list,
+// database black list, table black list. The first list matched decides, a name matched by
+// none of them passes. Exact rules are kept in maps, glob and regex rules are compiled to matchers
 type Filter struct {
 	DBBlack    map[string]bool
 	TableBlack map[string]bool
 	DBWhite    map[string]bool
 	TableWhite map[string]bool
+
+	dbBlackRules    []*matcher
+	tableBlackRules []*matcher
+	dbWhiteRules    []*matcher
+	tableWhiteRules []*matcher
 }
 
 // IsFilterWithName filter message by database name and table name.
+// A database name is checked with database rules only.
 // return true if not pass
 func (t *Filter) IsFilterWithName(name string) (bool, error) {
 	res := strings.Split(name, ".")
@@ -56,29 +98,16 @@ func (t *Filter) IsFilterWithName(name string) (bool, error) {
 	case 1:
 		{
 			database := res[0]
-			if _, ok := t.DBWhite[database]; ok {
+			if t.DBWhite[database] || matchAny(t.dbWhiteRules, database, "") {
 				return false, nil
 			}
-			if _, ok := t.DBBlack[database]; ok {
+			if t.DBBlack[database] || matchAny(t.dbBlackRules, database, "") {
 				return true, nil
 			}
 		}
 	case 2:
 		{
-			database := res[0]
-			if _, ok := t.DBWhite[database]; ok {
-				return false, nil
-			}
-			if _, ok := t.TableWhite[name]; ok {
-				return false, nil
-			}
-			if _, ok := t.DBBlack[database]; ok {
-				return true, nil
-			}
-			if _, ok := t.TableBlack[name]; ok {
-				return true, nil
-			}
-			return false, nil
+			return t.IsFilterTable(res[0], res[1]), nil
 		}
 	default:
 		return false, errors.New("wrong rule")
@@ -89,22 +118,170 @@ func (t *Filter) IsFilterWithName(name string) (bool, error) {
 // IsFilter filter message by message object
 // return true if not pass
 func (t *Filter) IsFilter(msg *message.Message) bool {
-	if _, ok := t.DBWhite[msg.Content.Head.Database]; ok {
+	return t.IsFilterTable(msg.Content.Head.Database, msg.Content.Head.Table)
+}
+
+// IsFilterTable filter by database name and table name
+// return true if not pass
+func (t *Filter) IsFilterTable(database string, table string) bool {
+	name := fmt.Sprintf("%s.%s", database, table)
+	if t.DBWhite[database] || matchAny(t.dbWhiteRules, database, table) {
 		return false
 	}
-	table := fmt.Sprintf("%s.%s", msg.Content.Head.Database, msg.Content.Head.Table)
-	if _, ok := t.TableWhite[table]; ok {
+	if t.TableWhite[name] || matchAny(t.tableWhiteRules, database, table) {
 		return false
 	}
-	if _, ok := t.DBBlack[msg.Content.Head.Database]; ok {
+	if t.DBBlack[database] || matchAny(t.dbBlackRules, database, table) {
 		return true
 	}
-	if _, ok := t.TableBlack[table]; ok {
+	if t.TableBlack[name] || matchAny(t.tableBlackRules, database, table) {
+		return true
+	}
+	return false
+}
+
+// matcher compiled glob or regex rule
+type matcher struct {
+	// exact database and table are names, not patterns
+	exact bool
+	// database glob pattern of database
+	database string
+	// table glob pattern of table, empty for database rule
+	table string
+	// regex is matched against database.table
+	regex *regexp.Regexp
+}
+
+// isTable returns true if rule matches tables, regex rules always match tables
+func (m *matcher) isTable() bool {
+	return m.regex != nil || m.table != ""
+}
+
+func (m *matcher) match(database string, table string) bool {
+	if m.regex != nil {
+		return m.regex.MatchString(database + "." + table)
+	}
+	if m.exact {
+		return m.database == database && (m.table == "" || m.table == table)
+	}
+	if ok, _ := path.Match(m.database, database); !ok {
+		return false
+	}
+	if m.table == "" {
 		return true
 	}
+	ok, _ := path.Match(m.table, table)
+	return ok
+}
+
+func matchAny(matchers []*matcher, database string, table string) bool {
+	for _, m := range matchers {
+		if m.match(database, table) {
+			return true
+		}
+	}
 	return false
 }
 
+// compileRule returns matcher of tables of filter, nil if filter applies to all tables
+func compileRule(f *pipeline.Filter) (m *matcher, err error) {
+	if f.Type != pipeline.FILTER_BLACK && f.Type != pipeline.FILTER_WHITE {
+		err = fmt.Errorf("filter type %s is not supported", f.Type)
+		return
+	}
+	switch f.Kind {
+	case pipeline.FILTER_KIND_TABLE:
+		{
+			if f.Rule == "" {
+				err = errors.New("filter rule is empty")
+				return
+			}
+		}
+	case pipeline.FILTER_KIND_EVENT:
+		{
+			if len(f.Events) == 0 {
+				err = errors.New("events of event filter are empty")
+				return
+			}
+			for _, v := range f.Events {
+				if !filterEvents[v] {
+					err = fmt.Errorf("event %s is not supported, supported events are insert, update, delete, ddl and snapshot", v)
+					return
+				}
+			}
+		}
+	case pipeline.FILTER_KIND_ROW:
+		{
+			if strings.TrimSpace(f.Condition) == "" {
+				err = errors.New("condition of row filter is empty")
+				return
+			}
+			if _, err = ParseCondition(f.Condition); err != nil {
+				return
+			}
+		}
+	default:
+		{
+			err = fmt.Errorf("filter kind %s is not supported", f.Kind)
+			return
+		}
+	}
+	if f.Rule == "" {
+		// event and row filters of all tables
+		return
+	}
+	m, err = compileTables(f.Rule, f.Syntax)
+	return
+}
+
+// compileTables returns matcher of databases or tables of rule
+func compileTables(rule string, syntax pipeline.FilterSyntax) (m *matcher, err error) {
+	m = &matcher{}
+	switch syntax {
+	case pipeline.FILTER_SYNTAX_EXACT, pipeline.FILTER_SYNTAX_GLOB:
+		{
+			if !FilterVerifyStr(rule) {
+				err = errors.New("filter rule error, only support the format like database.table or database")
+				return
+			}
+			arr := strings.Split(rule, ".")
+			m.database = arr[0]
+			if len(arr) == 2 {
+				m.table = arr[1]
+			}
+			if m.exact = syntax == pipeline.FILTER_SYNTAX_EXACT; m.exact {
+				return
+			}
+			for _, v := range arr {
+				if _, err = path.Match(v, ""); err != nil {
+					err = fmt.Errorf("glob %s is invalid: %v", rule, err)
+					return
+				}
+			}
+		}
+	case pipeline.FILTER_SYNTAX_REGEX:
+		{
+			// anchored, so the rule must match the whole name
+			if m.regex, err = regexp.Compile("^(?:" + rule + ")$"); err != nil {
+				err = fmt.Errorf("regex %s is invalid: %v", rule, err)
+			}
+		}
+	default:
+		{
+			err = fmt.Errorf("filter syntax %s is not supported", syntax)
+		}
+	}
+	return
+}
+
+var filterEvents = map[string]bool{
+	pipeline.FILTER_EVENT_INSERT:   true,
+	pipeline.FILTER_EVENT_UPDATE:   true,
+	pipeline.FILTER_EVENT_DELETE:   true,
+	pipeline.FILTER_EVENT_DDL:      true,
+	pipeline.FILTER_EVENT_SNAPSHOT: true,
+}
+
 // FilterVerifyStr verify sting correct
 // return false if illegal
 func FilterVerifyStr(s string) bool {
@@ -121,18 +298,34 @@ func FilterVerifyStr(s string) bool {
 	return true
 }
 
-// FilterVerify verify filter's type
+// FilterVerify verify filter's type, syntax and rule
 // return false is illegal
 func FilterVerify(f *pipeline.Filter) bool {
-	if f.Type != pipeline.FILTER_BLACK && f.Type != pipeline.FILTER_WHITE {
-		return false
-	}
-	if f.Rule == "" {
-		return false
-	}
-	res := strings.Split(f.Rule, ".")
-	if len(res) != 2 && len(res) != 1 {
-		return false
+	return FilterCheck(f) == nil
+}
+
+// FilterCheck returns error if filter is illegal
+func FilterCheck(f *pipeline.Filter) (err error) {
+	_, err = compileRule(f)
+	return
+}
+
+// Tables matches tables by rule, the syntax is the same as filters
+type Tables struct {
+	m *matcher
+}
+
+// NewTables returns Tables of rule, empty rule matches all tables
+func NewTables(rule string, syntax pipeline.FilterSyntax) (t *Tables, err error) {
+	t = &Tables{}
+	if rule == "" {
+		return
 	}
-	return true
+	t.m, err = compileTables(rule, syntax)
+	return
+}
+
+// Match returns true if table matches rule
+func (t *Tables) Match(database string, table string) bool {
+	return t.m == nil || t.m.match(database, table)
 }
@@ -9,10 +9,10 @@ import (
 
 func TestIsFilterWithName(t *testing.T) {
 	filters := []*pipeline.Filter{
-		{pipeline.FILTER_BLACK, "mysql"},
-		{pipeline.FILTER_BLACK, "base1"},
-		{pipeline.FILTER_WHITE, "base2"},
-		{pipeline.FILTER_WHITE, "base1.tbl1"},
+		{Type: pipeline.FILTER_BLACK, Rule: "mysql"},
+		{Type: pipeline.FILTER_BLACK, Rule: "base1"},
+		{Type: pipeline.FILTER_WHITE, Rule: "base2"},
+		{Type: pipeline.FILTER_WHITE, Rule: "base1.tbl1"},
 	}
 	f := NewFilter(filters)
 	ok, err := f.IsFilterWithName("mysql.user")
@@ -47,10 +47,10 @@ func TestIsFilterWithName(t *testing.T) {
 
 func TestIsFilter(t *testing.T) {
 	filters := []*pipeline.Filter{
-		{pipeline.FILTER_BLACK, "mysql"},
-		{pipeline.FILTER_BLACK, "base1"},
-		{pipeline.FILTER_WHITE, "base2"},
-		{pipeline.FILTER_WHITE, "base1.tbl1"},
+		{Type: pipeline.FILTER_BLACK, Rule: "mysql"},
+		{Type: pipeline.FILTER_BLACK, Rule: "base1"},
+		{Type: pipeline.FILTER_WHITE, Rule: "base2"},
+		{Type: pipeline.FILTER_WHITE, Rule: "base1.tbl1"},
 	}
 	f := NewFilter(filters)
 	msg := message.New()
@@ -109,3 +109,66 @@ func TestFilterVerify(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestFilterSyntax(t *testing.T) {
+	filters := []*pipeline.Filter{
+		{Type: pipeline.FILTER_BLACK, Rule: "tenant_*", Syntax: pipeline.FILTER_SYNTAX_GLOB},
+		{Type: pipeline.FILTER_WHITE, Rule: "tenant_1.order_?", Syntax: pipeline.FILTER_SYNTAX_GLOB},
+		{Type: pipeline.FILTER_BLACK, Rule: `order_\d{3}\.log_.*`, Syntax: pipeline.FILTER_SYNTAX_REGEX},
+		{Type: pipeline.FILTER_WHITE, Rule: "order_000", Syntax: pipeline.FILTER_SYNTAX_GLOB},
+		{Type: pipeline.FILTER_BLACK, Rule: "[", Syntax: pipeline.FILTER_SYNTAX_GLOB},
+	}
+	f := NewFilter(filters)
+	cases := map[string]bool{
+		"tenant_2.user":      true,
+		"tenant_1.order_a":   false,
+		"tenant_1.order_ab":  true,
+		"order_001.log_2024": true,
+		"order_001.orders":   false,
+		"order_000.log_2024": false,
+		"order_1.log_2024":   false,
+		"tenant_2":           true,
+		"order_000":          false,
+		"[":                  false,
+	}
+	for name, expected := range cases {
+		if ok, err := f.IsFilterWithName(name); err != nil || ok != expected {
+			t.Error(name, ok, err)
+		}
+	}
+}
+
+func TestFilterCheck(t *testing.T) {
+	if err := FilterCheck(&pipeline.Filter{Type: pipeline.FILTER_BLACK, Rule: "order_[0-9", Syntax: pipeline.FILTER_SYNTAX_GLOB}); err == nil {
+		t.Fail()
+	}
+	if err := FilterCheck(&pipeline.Filter{Type: pipeline.FILTER_BLACK, Rule: "order_(", Syntax: pipeline.FILTER_SYNTAX_REGEX}); err == nil {
+		t.Fail()
+	}
+	if err := FilterCheck(&pipeline.Filter{Type: pipeline.FILTER_BLACK, Rule: "a.b", Syntax: "like"}); err == nil {
+		t.Fail()
+	}
+	if err := FilterCheck(&pipeline.Filter{Type: pipeline.FILTER_WHITE, Rule: `db\..*`, Syntax: pipeline.FILTER_SYNTAX_REGEX}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFilterCheckKind(t *testing.T) {
+	if err := FilterCheck(&pipeline.Filter{Type: pipeline.FILTER_BLACK, Kind: pipeline.FILTER_KIND_EVENT, Events: []string{"delete", "ddl"}}); err != nil {
+		t.Error(err)
+	}
+	if err := FilterCheck(&pipeline.Filter{Type: pipeline.FILTER_BLACK, Kind: pipeline.FILTER_KIND_EVENT, Events: []string{"replace"}}); err == nil {
+		t.Fail()
+	}
+	if err := FilterCheck(&pipeline.Filter{Type: pipeline.FILTER_WHITE, Kind: pipeline.FILTER_KIND_ROW, Rule: "mall.order", Condition: "status = 'paid'"}); err != nil {
+		t.Error(err)
+	}
+	if err := FilterCheck(&pipeline.Filter{Type: pipeline.FILTER_WHITE, Kind: pipeline.FILTER_KIND_ROW, Condition: "status ="}); err == nil {
+		t.Fail()
+	}
+	// event and row filters do not change table filters
+	f := NewFilter([]*pipeline.Filter{{Type: pipeline.FILTER_BLACK, Kind: pipeline.FILTER_KIND_EVENT, Rule: "mall", Events: []string{"delete"}}})
+	if ok, _ := f.IsFilterWithName("mall.order"); ok {
+		t.Fail()
+	}
+}
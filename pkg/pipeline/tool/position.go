@@ -0,0 +1,44 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jin06/binlogo/pkg/mysql/replication"
+	"github.com/jin06/binlogo/pkg/store/dao/dao_pipe"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// UpdatePositionByTime sets position of the stopped pipeline to the first transaction at or after t
+func UpdatePositionByTime(ctx context.Context, name string, t time.Time) (pos *pipeline.Position, err error) {
+	pipe, err := dao_pipe.GetPipeline(name)
+	if err != nil {
+		return
+	}
+	if pipe == nil {
+		err = errors.New("pipeline not found")
+		return
+	}
+	if pipe.Status == pipeline.STATUS_RUN {
+		err = errors.New("only stopped pipeline can be updated")
+		return
+	}
+	if pos, err = replication.PositionByTime(ctx, pipe.Mysql, t); err != nil {
+		err = errors.New("find position failed: " + err.Error())
+		return
+	}
+	pos.PipelineName = name
+	if pipe.Mysql.Mode == pipeline.MODE_GTID && pos.GTIDSet == "" {
+		err = errors.New("gtid set not found in binlog, check gtid mode of mysql")
+		return
+	}
+	ok, err := dao_pipe.UpdateRecordSafe(name, pipeline.WithPre(pos), pipeline.WithNow(nil))
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = errors.New("update position failed")
+	}
+	return
+}
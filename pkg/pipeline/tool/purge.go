@@ -0,0 +1,51 @@
+package tool
+
+import (
+	"github.com/jin06/binlogo/pkg/mysql/replication"
+	"github.com/jin06/binlogo/pkg/store/dao/dao_pipe"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+	"github.com/sirupsen/logrus"
+)
+
+// PurgeRisks checks binlog needed by pipelines replicating from mysql server is retained,
+// risks of all levels are returned, pipelines failed to be checked are skipped
+func PurgeRisks() (risks []*replication.PurgeRisk, err error) {
+	pipes, err := dao_pipe.AllPipelines()
+	if err != nil {
+		return
+	}
+	risks = []*replication.PurgeRisk{}
+	for _, p := range pipes {
+		if !PurgeCheckable(p) {
+			continue
+		}
+		record, er := dao_pipe.GetRecord(p.Name)
+		if er != nil {
+			err = er
+			return
+		}
+		var lag *pipeline.Lag
+		if p.Status == pipeline.STATUS_RUN {
+			ins, er := dao_pipe.GetInstance(p.Name)
+			if er != nil {
+				err = er
+				return
+			}
+			if ins != nil {
+				lag = ins.Lag
+			}
+		}
+		risk, er := replication.CheckPurge(p, record, lag)
+		if er != nil {
+			logrus.Errorln("Check binlog purge error: ", p.Name, er)
+			continue
+		}
+		risks = append(risks, risk)
+	}
+	return
+}
+
+// PurgeCheckable returns true if pipeline replicates from mysql server
+func PurgeCheckable(p *pipeline.Pipeline) bool {
+	return p != nil && !p.IsDelete && p.Mysql != nil && p.Mysql.Source == pipeline.SOURCE_SERVER
+}
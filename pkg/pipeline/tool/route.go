@@ -0,0 +1,70 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// Router routes physical tables to logical tables
+type Router struct {
+	routes []*route
+}
+
+type route struct {
+	conf   *pipeline.TableRoute
+	tables *matcher
+}
+
+// NewRouter returns router of routes in order
+func NewRouter(routes []*pipeline.TableRoute) (r *Router, err error) {
+	r = &Router{}
+	for i, v := range routes {
+		var rt *route
+		if rt, err = compileRoute(v); err != nil {
+			err = fmt.Errorf("route %d: %v", i, err)
+			return
+		}
+		r.routes = append(r.routes, rt)
+	}
+	return
+}
+
+// RouteCheck returns error if route is illegal
+func RouteCheck(r *pipeline.TableRoute) (err error) {
+	_, err = compileRoute(r)
+	return
+}
+
+func compileRoute(v *pipeline.TableRoute) (r *route, err error) {
+	if v.Rule == "" {
+		err = errors.New("rule is empty")
+		return
+	}
+	if v.Database == "" && v.Table == "" {
+		err = errors.New("database and table are empty")
+		return
+	}
+	r = &route{conf: v}
+	r.tables, err = compileTables(v.Rule, v.Syntax)
+	return
+}
+
+// Route returns logical table of physical table by the first matched route, ok is false if no route matches
+func (r *Router) Route(database string, table string) (logicalDatabase string, logicalTable string, ok bool) {
+	for _, v := range r.routes {
+		if !v.tables.match(database, table) {
+			continue
+		}
+		logicalDatabase, logicalTable, ok = database, table, true
+		if v.conf.Database != "" {
+			logicalDatabase = v.conf.Database
+		}
+		if v.conf.Table != "" {
+			logicalTable = v.conf.Table
+		}
+		return
+	}
+	return
+}
@@ -0,0 +1,41 @@
+package tool
+
+import (
+	"testing"
+
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestRouter(t *testing.T) {
+	r, err := NewRouter([]*pipeline.TableRoute{
+		{Rule: "mall_*.order_*", Syntax: pipeline.FILTER_SYNTAX_GLOB, Database: "mall", Table: "order"},
+		{Rule: `user_\d+\.profile`, Syntax: pipeline.FILTER_SYNTAX_REGEX, Database: "user"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		database, table string
+		logical         string
+		ok              bool
+	}{
+		{"mall_01", "order_63", "mall.order", true},
+		{"user_7", "profile", "user.profile", true},
+		{"mall_01", "item", "", false},
+	}
+	for _, c := range cases {
+		database, table, ok := r.Route(c.database, c.table)
+		if ok != c.ok || (ok && database+"."+table != c.logical) {
+			t.Error(c, database, table, ok)
+		}
+	}
+	for _, v := range []*pipeline.TableRoute{
+		{Rule: "", Table: "order"},
+		{Rule: "mall.order_*", Syntax: pipeline.FILTER_SYNTAX_GLOB},
+		{Rule: "(", Syntax: pipeline.FILTER_SYNTAX_REGEX, Table: "order"},
+	} {
+		if RouteCheck(v) == nil {
+			t.Error(v)
+		}
+	}
+}
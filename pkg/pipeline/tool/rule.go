@@ -0,0 +1,94 @@
+package tool
+
+import (
+	"github.com/jin06/binlogo/app/pipeline/message"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// RowRule compiled event or row filter, applied to events of tables passing table filters
+type RowRule struct {
+	// Index of filter in filters of pipeline
+	Index  int
+	Filter *pipeline.Filter
+	// tables nil matches all tables
+	tables    *matcher
+	events    map[string]bool
+	condition *Condition
+}
+
+// NewRowRules returns compiled event and row filters in order of filters, illegal filters are skipped
+func NewRowRules(filters []*pipeline.Filter) (rules []*RowRule) {
+	for i, v := range filters {
+		if v.Kind == pipeline.FILTER_KIND_TABLE {
+			continue
+		}
+		m, err := compileRule(v)
+		if err != nil {
+			continue
+		}
+		rule := &RowRule{Index: i, Filter: v, tables: m}
+		if v.Kind == pipeline.FILTER_KIND_EVENT {
+			rule.events = map[string]bool{}
+			for _, e := range v.Events {
+				rule.events[e] = true
+			}
+		} else {
+			rule.condition, _ = ParseCondition(v.Condition)
+		}
+		rules = append(rules, rule)
+	}
+	return
+}
+
+// Black returns true if matched events or rows are dropped, otherwise only matched ones are kept
+func (r *RowRule) Black() bool {
+	return r.Filter.Type == pipeline.FILTER_BLACK
+}
+
+// IsEvent returns true for event filter, false for row filter
+func (r *RowRule) IsEvent() bool {
+	return r.events != nil
+}
+
+// MatchTable returns true if rule applies to table
+func (r *RowRule) MatchTable(database string, table string) bool {
+	return r.tables == nil || r.tables.match(database, table)
+}
+
+// MatchEvent returns true if event is one of events of event filter
+func (r *RowRule) MatchEvent(event string) bool {
+	return r.events[event]
+}
+
+// MatchRow returns true if row matches condition of row filter
+func (r *RowRule) MatchRow(row map[string]interface{}, old map[string]interface{}, new map[string]interface{}) bool {
+	return r.condition.Match(row, old, new)
+}
+
+// MessageEvent returns event of message type for event filters and sender routes, empty for types without event
+func MessageEvent(messageType string) string {
+	switch messageType {
+	case message.TYPE_INSERT.String():
+		{
+			return pipeline.FILTER_EVENT_INSERT
+		}
+	case message.TYPE_UPDATE.String():
+		{
+			return pipeline.FILTER_EVENT_UPDATE
+		}
+	case message.TYPE_DELETE.String():
+		{
+			return pipeline.FILTER_EVENT_DELETE
+		}
+	case message.TYPE_SNAPSHOT.String():
+		{
+			return pipeline.FILTER_EVENT_SNAPSHOT
+		}
+	case message.TYPE_CREATE_TABLE.String(), message.TYPE_ALTER_TABLE.String(), message.TYPE_DROP_TABLE.String(),
+		message.TYPE_RENAME_TABLE.String(), message.TYPE_TRUNCATE_TABLE.String(), message.TYPE_DDL.String():
+		{
+			return pipeline.FILTER_EVENT_DDL
+		}
+	}
+	return ""
+}
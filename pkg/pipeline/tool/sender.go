@@ -0,0 +1,66 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// SenderRoute compiled route of sender
+type SenderRoute struct {
+	tables *Tables
+	events map[string]bool
+}
+
+// NewSenderRoutes returns compiled routes of sender
+func NewSenderRoutes(routes []*pipeline.SenderRoute) (res []*SenderRoute, err error) {
+	for i, v := range routes {
+		r := &SenderRoute{}
+		if r.tables, err = NewTables(v.Rule, v.Syntax); err != nil {
+			err = fmt.Errorf("route %d: %v", i, err)
+			return
+		}
+		if len(v.Events) > 0 {
+			r.events = map[string]bool{}
+		}
+		for _, e := range v.Events {
+			if !filterEvents[e] {
+				err = fmt.Errorf("route %d: event %s is not supported, supported events are insert, update, delete, ddl and snapshot", i, e)
+				return
+			}
+			r.events[e] = true
+		}
+		res = append(res, r)
+	}
+	return
+}
+
+// Match returns true if event of table is routed, event is the same as event filters
+func (r *SenderRoute) Match(database string, table string, event string) bool {
+	if r.events != nil && !r.events[event] {
+		return false
+	}
+	return r.tables.Match(database, table)
+}
+
+// OutputCheck returns error if senders of output are illegal, senders must have unique names
+func OutputCheck(o *pipeline.Output) (err error) {
+	if o == nil || len(o.SenderList()) == 0 {
+		return errors.New("output has no sender")
+	}
+	names := map[string]bool{}
+	for _, v := range o.Senders {
+		if v.Name == "" {
+			return errors.New("name of sender is empty")
+		}
+		if names[v.Name] {
+			return fmt.Errorf("name of sender %s is duplicated", v.Name)
+		}
+		names[v.Name] = true
+		if _, err = NewSenderRoutes(v.Routes); err != nil {
+			return fmt.Errorf("sender %s: %v", v.Name, err)
+		}
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package tool
+
+import (
+	"testing"
+
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+func TestOutputCheck(t *testing.T) {
+	sender := func(name string, routes ...*pipeline.SenderRoute) *pipeline.Sender {
+		return &pipeline.Sender{Name: name, Type: pipeline.SNEDER_TYPE_STDOUT, Routes: routes}
+	}
+	ok := []*pipeline.Output{
+		{Sender: sender("")},
+		{Senders: []*pipeline.Sender{sender("user", &pipeline.SenderRoute{Rule: "user"}), sender("all")}},
+	}
+	for _, v := range ok {
+		if err := OutputCheck(v); err != nil {
+			t.Error(err)
+		}
+	}
+	bad := []*pipeline.Output{
+		nil,
+		{},
+		{Senders: []*pipeline.Sender{sender("")}},
+		{Senders: []*pipeline.Sender{sender("a"), sender("a")}},
+		{Senders: []*pipeline.Sender{sender("a", &pipeline.SenderRoute{Rule: "(", Syntax: pipeline.FILTER_SYNTAX_REGEX})}},
+		{Senders: []*pipeline.Sender{sender("a", &pipeline.SenderRoute{Events: []string{"merge"}})}},
+	}
+	for i, v := range bad {
+		if OutputCheck(v) == nil {
+			t.Error(i)
+		}
+	}
+}
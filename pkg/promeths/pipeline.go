@@ -13,6 +13,17 @@ var (
 	MessageFilterCounter  *prometheus.CounterVec
 	MessageSendHistogram  *prometheus.HistogramVec
 	MessagePoolGauge      *prometheus.GaugeVec
+	// InputBufferGauge rows of the current transaction buffered in memory
+	InputBufferGauge *prometheus.GaugeVec
+	// InputSpillCounter transactions spilled to disk, InputSpillRowsCounter rows spilled to disk
+	InputSpillCounter     *prometheus.CounterVec
+	InputSpillRowsCounter *prometheus.CounterVec
+	// LagGauge seconds of replication lag
+	LagGauge *prometheus.GaugeVec
+	// FilterRuleCounter events and rows dropped by each event or row filter, rule is index of the filter
+	FilterRuleCounter *prometheus.CounterVec
+	// TransformErrorCounter failures of each transform step, step is index of the step
+	TransformErrorCounter *prometheus.CounterVec
 )
 
 func Init() {
@@ -76,4 +87,58 @@ func Init() {
 		pipelineLabels,
 	)
 	prometheus.Register(MessagePoolGauge)
+	InputBufferGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: nameSpace,
+			Subsystem: subSystem,
+			Name:      "input_buffer_rows",
+		},
+		pipelineLabels,
+	)
+	prometheus.Register(InputBufferGauge)
+	InputSpillCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: nameSpace,
+			Subsystem: subSystem,
+			Name:      "input_spill",
+		},
+		pipelineLabels,
+	)
+	prometheus.Register(InputSpillCounter)
+	InputSpillRowsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: nameSpace,
+			Subsystem: subSystem,
+			Name:      "input_spill_rows",
+		},
+		pipelineLabels,
+	)
+	prometheus.Register(InputSpillRowsCounter)
+	LagGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: nameSpace,
+			Subsystem: subSystem,
+			Name:      "replication_lag_seconds",
+		},
+		pipelineLabels,
+	)
+	prometheus.Register(LagGauge)
+	FilterRuleCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: nameSpace,
+			Subsystem: subSystem,
+			Name:      "filter_rule_drop",
+		},
+		append(pipelineLabels, "rule", "kind"),
+	)
+	prometheus.Register(FilterRuleCounter)
+	TransformErrorCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: nameSpace,
+			Subsystem: subSystem,
+			Name:      "transform_error",
+		},
+		append(pipelineLabels, "step", "type"),
+	)
+	prometheus.Register(TransformErrorCounter)
 }
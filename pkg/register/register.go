@@ -3,6 +3,7 @@ package register
 import (
 	"context"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/jin06/binlogo/pkg/etcdclient"
@@ -33,6 +34,8 @@ type Register struct {
 	registerData           interface{}
 	registerCreateRevision int64
 	ctx                    context.Context
+	// mutex guards lease and data between register and Update
+	mutex sync.Mutex
 }
 
 func (r *Register) initClient() (err error) {
@@ -114,6 +117,8 @@ func (r *Register) Run(ctx context.Context) {
 
 func (r *Register) reg() (err error) {
 	//r.client, _ = etcdclient.New()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 	r.lease = clientv3.NewLease(r.client)
 	rep, err := r.lease.Grant(r.ctx, r.ttl)
 	if err != nil {
@@ -171,6 +176,25 @@ func (r *Register) watch() (ok bool, err error) {
 	return
 }
 
+// Update replaces registered data, the key keeps the lease
+func (r *Register) Update(data interface{}) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.registerData = data
+	if r.client == nil || r.leaseID == 0 {
+		return
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	txn := r.client.Txn(r.ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(r.registerKey), "=", r.registerCreateRevision)).
+		Then(clientv3.OpPut(r.registerKey, string(b), clientv3.WithLease(r.leaseID)))
+	_, err = txn.Commit()
+	return
+}
+
 // Context returns register's context
 func (r *Register) Context() context.Context {
 	return r.ctx
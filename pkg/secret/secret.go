@@ -0,0 +1,68 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Resolve returns the secret referenced by ref, the secret is read at runtime and never stored.
+// supported references:
+//
+//	env:NAME      environment variable NAME of the node
+//	file:/path    content of a mounted file, trailing newline is trimmed
+//	secret:key    value of key in the node-local secrets file configured by secrets.file
+func Resolve(ref string) (val string, err error) {
+	scheme, name, found := strings.Cut(ref, ":")
+	if !found || name == "" {
+		err = fmt.Errorf("invalid secret reference %s", ref)
+		return
+	}
+	switch scheme {
+	case "env":
+		{
+			var ok bool
+			if val, ok = os.LookupEnv(name); !ok {
+				err = fmt.Errorf("environment variable %s not found", name)
+			}
+			return
+		}
+	case "file":
+		{
+			var b []byte
+			if b, err = os.ReadFile(name); err != nil {
+				return
+			}
+			val = strings.TrimRight(string(b), "\r\n")
+			return
+		}
+	case "secret":
+		{
+			return fromFile(viper.GetString("secrets.file"), name)
+		}
+	}
+	err = fmt.Errorf("unknown secret reference scheme %s", scheme)
+	return
+}
+
+// fromFile reads key of secrets file in yaml, json or other format supported by viper
+func fromFile(file string, key string) (val string, err error) {
+	if file == "" {
+		err = errors.New("secrets file of node is not configured")
+		return
+	}
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err = v.ReadInConfig(); err != nil {
+		return
+	}
+	if !v.IsSet(key) {
+		err = fmt.Errorf("secret %s not found in secrets file", key)
+		return
+	}
+	val = v.GetString(key)
+	return
+}
@@ -0,0 +1,51 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("BINLOGO_TEST_PASSWORD", "env_password")
+	val, err := Resolve("env:BINLOGO_TEST_PASSWORD")
+	if err != nil || val != "env_password" {
+		t.Fail()
+	}
+	if _, err = Resolve("env:BINLOGO_TEST_NOT_EXIST"); err == nil {
+		t.Fail()
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "password")
+	if err = os.WriteFile(file, []byte("file_password\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	val, err = Resolve("file:" + file)
+	if err != nil || val != "file_password" {
+		t.Fail()
+	}
+
+	secrets := filepath.Join(dir, "secrets.yaml")
+	if err = os.WriteFile(secrets, []byte("mysql:\n  prod: secret_password\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("secrets.file", secrets)
+	defer viper.Set("secrets.file", "")
+	val, err = Resolve("secret:mysql.prod")
+	if err != nil || val != "secret_password" {
+		t.Fail()
+	}
+	if _, err = Resolve("secret:mysql.dev"); err == nil {
+		t.Fail()
+	}
+
+	if _, err = Resolve("password"); err == nil {
+		t.Fail()
+	}
+	if _, err = Resolve("vault:mysql"); err == nil {
+		t.Fail()
+	}
+}
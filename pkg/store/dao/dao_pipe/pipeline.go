@@ -184,7 +184,7 @@ func DeletePipeline(name string) (ok bool, err error) {
 	return
 }
 
-// DeleteCompletePipeline delete pipeline, contains pipeline info, pipeline position
+// DeleteCompletePipeline delete pipeline, contains pipeline info, pipeline position, pipeline snapshot, schema history
 func DeleteCompletePipeline(name string) (ok bool, err error) {
 	if name == "" {
 		err = errors.New("empty name")
@@ -198,5 +198,13 @@ func DeleteCompletePipeline(name string) (ok bool, err error) {
 	if err != nil {
 		return
 	}
+	_, err = DeleteSnapshot(name)
+	if err != nil {
+		return
+	}
+	_, err = DeleteSchemaHistory(name)
+	if err != nil {
+		return
+	}
 	return
 }
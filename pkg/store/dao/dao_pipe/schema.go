@@ -0,0 +1,64 @@
+package dao_pipe
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jin06/binlogo/pkg/etcdclient"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// SchemaPrefix returns etcd prefix of pipeline schema history
+func SchemaPrefix() string {
+	return etcdclient.Prefix() + "/pipeline/schema"
+}
+
+func schemaKey(pipeName string, database string, table string) string {
+	return SchemaPrefix() + "/" + pipeName + "/" + database + "." + table
+}
+
+// UpdateSchemaHistory update schema history of table in etcd
+func UpdateSchemaHistory(h *pipeline.SchemaHistory) (err error) {
+	if h.PipelineName == "" {
+		err = errors.New("empty pipeline name")
+		return
+	}
+	key := schemaKey(h.PipelineName, h.Database, h.Table)
+	_, err = etcdclient.Default().Put(context.Background(), key, h.Val())
+	return
+}
+
+// GetSchemaHistory get schema history of table from etcd, nil if table has no history
+func GetSchemaHistory(pipeName string, database string, table string) (h *pipeline.SchemaHistory, err error) {
+	key := schemaKey(pipeName, database, table)
+	res, err := etcdclient.Default().Get(context.Background(), key)
+	if err != nil {
+		return
+	}
+	if len(res.Kvs) == 0 {
+		return
+	}
+	h = &pipeline.SchemaHistory{}
+	if err = h.Unmarshal(res.Kvs[0].Value); err != nil {
+		return
+	}
+	return
+}
+
+// DeleteSchemaHistory delete schema history of all tables of pipeline in etcd
+func DeleteSchemaHistory(pipeName string) (ok bool, err error) {
+	if pipeName == "" {
+		err = errors.New("empty name")
+		return
+	}
+	key := SchemaPrefix() + "/" + pipeName + "/"
+	res, err := etcdclient.Default().Delete(context.Background(), key, clientv3.WithPrefix())
+	if err != nil {
+		return
+	}
+	if res.Deleted > 0 {
+		ok = true
+	}
+	return
+}
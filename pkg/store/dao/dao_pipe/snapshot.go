@@ -0,0 +1,59 @@
+package dao_pipe
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jin06/binlogo/pkg/etcdclient"
+	"github.com/jin06/binlogo/pkg/store/model/pipeline"
+)
+
+// SnapshotPrefix returns etcd prefix of pipeline snapshot
+func SnapshotPrefix() string {
+	return etcdclient.Prefix() + "/pipeline/snapshot"
+}
+
+// UpdateSnapshot update pipeline snapshot progress in etcd
+func UpdateSnapshot(s *pipeline.Snapshot) (err error) {
+	if s.PipelineName == "" {
+		err = errors.New("empty pipeline name")
+		return
+	}
+	key := SnapshotPrefix() + "/" + s.PipelineName
+	_, err = etcdclient.Default().Put(context.Background(), key, s.Val())
+	return
+}
+
+// GetSnapshot get pipeline snapshot progress from etcd
+func GetSnapshot(pipeName string) (s *pipeline.Snapshot, err error) {
+	key := SnapshotPrefix() + "/" + pipeName
+	res, err := etcdclient.Default().Get(context.Background(), key)
+	if err != nil {
+		return
+	}
+	if len(res.Kvs) == 0 {
+		return
+	}
+	s = &pipeline.Snapshot{}
+	if err = s.Unmarshal(res.Kvs[0].Value); err != nil {
+		return
+	}
+	return
+}
+
+// DeleteSnapshot delete pipeline snapshot progress by pipeline name in etcd
+func DeleteSnapshot(name string) (ok bool, err error) {
+	if name == "" {
+		err = errors.New("empty name")
+		return
+	}
+	key := SnapshotPrefix() + "/" + name
+	res, err := etcdclient.Default().Delete(context.Background(), key)
+	if err != nil {
+		return
+	}
+	if res.Deleted > 0 {
+		ok = true
+	}
+	return
+}
@@ -0,0 +1,17 @@
+package pipeline
+
+// COALESCE_INTERVAL default milliseconds of coalescing window
+const COALESCE_INTERVAL = 1000
+
+// Coalesce collapses changes of the same row within a window into one net change before output.
+// Rows are keyed by physical table and primary key in message head before column rules, so head_schema of mysql
+// must be enabled. Updates changing the primary key and rows split by script are not coalesced
+type Coalesce struct {
+	// Rule tables coalesced, syntax is the same as filters, empty matches all tables
+	Rule   string       `json:"rule"`
+	Syntax FilterSyntax `json:"syntax"`
+	// Interval milliseconds of window, COALESCE_INTERVAL if it is 0
+	Interval int `json:"interval"`
+	// Count changes of window, the window is closed early once it is reached, 0 means no limit
+	Count int `json:"count"`
+}
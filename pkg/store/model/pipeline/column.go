@@ -0,0 +1,34 @@
+package pipeline
+
+// ColumnRule rule of columns of tables, applied to old and new images of rows before messages are sent
+type ColumnRule struct {
+	// Rule database or table the rule applies to, syntax is the same as filters
+	Rule   string       `json:"rule"`
+	Syntax FilterSyntax `json:"syntax"`
+	Action ColumnAction `json:"action"`
+	// Columns names of columns, case insensitive
+	Columns []string `json:"columns"`
+	// Salt of hash action, SaltRef reference of salt resolved at runtime instead of Salt,
+	// e.g. env:BINLOGO_SALT, file:/run/secrets/salt or secret:salt
+	Salt    string `json:"salt"`
+	SaltRef string `json:"salt_ref"`
+	// KeepPrefix and KeepSuffix characters kept by mask action, the others are replaced by *
+	KeepPrefix int `json:"keep_prefix"`
+	KeepSuffix int `json:"keep_suffix"`
+}
+
+// ColumnAction what column rule does to columns
+type ColumnAction string
+
+const (
+	// COLUMN_INCLUDE keeps only the columns, the others are dropped
+	COLUMN_INCLUDE ColumnAction = "include"
+	// COLUMN_EXCLUDE drops the columns
+	COLUMN_EXCLUDE ColumnAction = "exclude"
+	// COLUMN_HASH replaces values with hex of salted SHA-256
+	COLUMN_HASH ColumnAction = "hash"
+	// COLUMN_MASK replaces characters of values with * except prefix and suffix
+	COLUMN_MASK ColumnAction = "mask"
+	// COLUMN_NULL replaces values with null
+	COLUMN_NULL ColumnAction = "null"
+)
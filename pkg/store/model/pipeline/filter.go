@@ -3,7 +3,16 @@ package pipeline
 // Filter of pipeline
 type Filter struct {
 	Type FilterType `json:"type"`
-	Rule string     `json:"rule"`
+	// Rule database or table the filter applies to, empty matches all tables for event and row filters
+	Rule string `json:"rule"`
+	// Syntax of rule, exact names by default
+	Syntax FilterSyntax `json:"syntax"`
+	// Kind of filter, tables by default
+	Kind FilterKind `json:"kind"`
+	// Events kept by white or dropped by black event filter
+	Events []string `json:"events,omitempty"`
+	// Condition of row filter, rows matching it are kept by white or dropped by black filter
+	Condition string `json:"condition,omitempty"`
 }
 
 // FilterType types of filter
@@ -16,6 +25,43 @@ const (
 	FILTER_BLACK FilterType = "black"
 )
 
+// FilterSyntax syntax of filter rule
+type FilterSyntax string
+
+const (
+	// FILTER_SYNTAX_EXACT rule is database or database.table
+	FILTER_SYNTAX_EXACT FilterSyntax = ""
+	// FILTER_SYNTAX_GLOB rule is database or database.table, each part is a glob pattern like tenant_* or order_[0-9]*
+	FILTER_SYNTAX_GLOB FilterSyntax = "glob"
+	// FILTER_SYNTAX_REGEX rule is a regular expression matched against the whole database.table name
+	FILTER_SYNTAX_REGEX FilterSyntax = "regex"
+)
+
+// FilterKind what filter keeps or drops
+type FilterKind string
+
+const (
+	// FILTER_KIND_TABLE keeps or drops databases and tables
+	FILTER_KIND_TABLE FilterKind = ""
+	// FILTER_KIND_EVENT keeps or drops events of tables, like insert or ddl
+	FILTER_KIND_EVENT FilterKind = "event"
+	// FILTER_KIND_ROW keeps or drops rows of tables by condition, like status = 'paid' or tenant_id IN (3,7)
+	FILTER_KIND_ROW FilterKind = "row"
+)
+
+const (
+	// FILTER_EVENT_INSERT insert rows
+	FILTER_EVENT_INSERT = "insert"
+	// FILTER_EVENT_UPDATE update rows
+	FILTER_EVENT_UPDATE = "update"
+	// FILTER_EVENT_DELETE delete rows
+	FILTER_EVENT_DELETE = "delete"
+	// FILTER_EVENT_DDL ddl of tables
+	FILTER_EVENT_DDL = "ddl"
+	// FILTER_EVENT_SNAPSHOT rows read by initial snapshot
+	FILTER_EVENT_SNAPSHOT = "snapshot"
+)
+
 // BlackFilter returns a black filter
 func BlackFilter(rule string) (f *Filter) {
 	f = &Filter{
@@ -7,4 +7,17 @@ type Instance struct {
 	PipelineName string    `json:"pipeline_name"`
 	NodeName     string    `json:"node_name"`
 	CreateTime   time.Time `json:"create_time"`
+	// Lag replication lag of the running pipeline, nil if unknown
+	Lag *Lag `json:"lag"`
+}
+
+// Lag replication lag of pipeline, position of mysql master minus checkpointed position
+type Lag struct {
+	// Seconds age of the oldest event not checkpointed, 0 if checkpoint has caught up with master
+	Seconds int64 `json:"seconds"`
+	// Address of mysql the pipeline replicates from
+	Address    string    `json:"address"`
+	Master     *Position `json:"master"`
+	Checkpoint *Position `json:"checkpoint"`
+	UpdateTime time.Time `json:"update_time"`
 }
@@ -1,6 +1,10 @@
 package pipeline
 
-import "github.com/go-mysql-org/go-mysql/mysql"
+import (
+	"fmt"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
 
 // Mysql store struct
 type Mysql struct {
@@ -11,8 +15,104 @@ type Mysql struct {
 	ServerId uint32 `json:"server_id"`
 	Flavor   Flavor `json:"flavor"`
 	Mode     Mode   `json:"mode"`
+	// Snapshot read existing rows of selected tables before streaming binlog,
+	// only works when the pipeline has no position
+	Snapshot bool `json:"snapshot"`
+	// HeadSchema add column schema and primary key of rows to message head
+	HeadSchema bool `json:"head_schema"`
+	// TimeZone time zone of datetime and timestamp values in message, e.g. Asia/Shanghai, default UTC
+	TimeZone string `json:"time_zone"`
+	// Transaction how transaction boundaries are delivered
+	Transaction TransactionMode `json:"transaction"`
+	// Source where binlog is read from, mysql server by default
+	Source Source `json:"source"`
+	// BinlogDir directory of binlog files read by file source
+	BinlogDir string `json:"binlog_dir"`
+	// BinlogFiles binlog files read by file source in order, binlog files in BinlogDir are read if empty
+	BinlogFiles []string `json:"binlog_files"`
+	// Endpoints candidates mysql fails over to in order when Address is not available, gtid mode only
+	Endpoints []*Endpoint `json:"endpoints"`
+	// PasswordRef reference of password resolved at runtime instead of Password,
+	// e.g. env:MYSQL_PASSWORD, file:/run/secrets/mysql, secret:mysql.prod
+	PasswordRef string `json:"password_ref"`
+	// TLS encrypts connections to mysql if not nil
+	TLS *TLS `json:"tls"`
+	// SpillRows rows of a transaction buffered in memory, rows after them are spilled to a temporary file,
+	// 0 means never spill. It can not be set with TRANSACTION_GROUP, grouped transaction is one message in memory
+	SpillRows int `json:"spill_rows"`
+	// RowsQuery add the statement causing rows to message head, requires binlog_rows_query_log_events of mysql
+	RowsQuery bool `json:"rows_query"`
+	// RowsQueryLength max bytes of the statement in message head, DEFAULT_ROWS_QUERY_LENGTH if 0
+	RowsQueryLength int `json:"rows_query_length"`
+	// ChangedColumnsOnly update messages contain changed columns and primary key only
+	ChangedColumnsOnly bool `json:"changed_columns_only"`
+}
+
+// DEFAULT_ROWS_QUERY_LENGTH default max bytes of the statement in message head
+const DEFAULT_ROWS_QUERY_LENGTH = 1024
+
+// TLS tls config of mysql connections, files are paths on nodes
+type TLS struct {
+	// CA file of certificates verifying mysql, system roots are used if empty
+	CA string `json:"ca"`
+	// Cert and Key files of the optional client certificate
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+	// ServerName name verified against the certificate of mysql, address of endpoint by default
+	ServerName string `json:"server_name"`
+	// SkipVerify does not verify certificate of mysql
+	SkipVerify bool `json:"skip_verify"`
+}
+
+// Endpoint address of a mysql server
+type Endpoint struct {
+	Address string `json:"address"`
+	Port    uint16 `json:"port"`
+}
+
+// Addr returns address in host:port format
+func (e *Endpoint) Addr() string {
+	return fmt.Sprintf("%s:%d", e.Address, e.Port)
+}
+
+// Candidates returns the primary endpoint followed by failover endpoints
+func (s *Mysql) Candidates() (list []*Endpoint) {
+	list = []*Endpoint{{Address: s.Address, Port: s.Port}}
+	for _, v := range s.Endpoints {
+		if v != nil {
+			list = append(list, v)
+		}
+	}
+	return
+}
+
+// Failover returns true if the pipeline fails over to endpoints, it requires gtid mode and at least one endpoint
+func (s *Mysql) Failover() bool {
+	return s.Mode == MODE_GTID && s.Source != SOURCE_FILE && len(s.Candidates()) > 1
 }
 
+// Source where binlog is read from
+type Source string
+
+const (
+	// SOURCE_SERVER replicate binlog from mysql server
+	SOURCE_SERVER Source = ""
+	// SOURCE_FILE read binlog files from local directory, e.g. archived binlog of purged server
+	SOURCE_FILE Source = "file"
+)
+
+// TransactionMode how transaction boundaries are delivered
+type TransactionMode string
+
+const (
+	// TRANSACTION_NONE rows are sent one by one without transaction boundary
+	TRANSACTION_NONE TransactionMode = ""
+	// TRANSACTION_MARKER begin and commit messages are sent around rows of transaction
+	TRANSACTION_MARKER TransactionMode = "marker"
+	// TRANSACTION_GROUP the whole transaction is sent as one message
+	TRANSACTION_GROUP TransactionMode = "group"
+)
+
 // Mode mysql replication mode
 type Mode string
 
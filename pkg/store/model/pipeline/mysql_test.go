@@ -0,0 +1,33 @@
+package pipeline
+
+import "testing"
+
+func TestCandidates(t *testing.T) {
+	m := &Mysql{
+		Address:   "10.0.0.1",
+		Port:      3306,
+		Endpoints: []*Endpoint{{Address: "10.0.0.2", Port: 3307}, nil},
+	}
+	list := m.Candidates()
+	if len(list) != 2 {
+		t.Fatal(list)
+	}
+	if list[0].Addr() != "10.0.0.1:3306" || list[1].Addr() != "10.0.0.2:3307" {
+		t.Fail()
+	}
+}
+
+func TestFailover(t *testing.T) {
+	m := &Mysql{Address: "10.0.0.1", Port: 3306, Mode: MODE_GTID, Endpoints: []*Endpoint{nil}}
+	if m.Failover() {
+		t.Fail()
+	}
+	m.Endpoints = append(m.Endpoints, &Endpoint{Address: "10.0.0.2", Port: 3306})
+	if !m.Failover() {
+		t.Fail()
+	}
+	m.Mode = MODE_POSITION
+	if m.Failover() {
+		t.Fail()
+	}
+}
@@ -3,4 +3,27 @@ package pipeline
 // Output pipeline output
 type Output struct {
 	Sender *Sender `json:"sender"`
+	// Senders named senders, a message is sent to every sender whose routes match it.
+	// Sender is used if Senders is empty
+	Senders []*Sender `json:"senders,omitempty"`
+}
+
+// SenderList returns senders of output, it is Sender if Senders is empty
+func (o *Output) SenderList() []*Sender {
+	if len(o.Senders) > 0 {
+		return o.Senders
+	}
+	if o.Sender == nil {
+		return nil
+	}
+	return []*Sender{o.Sender}
+}
+
+// SenderRoute messages routed to sender
+type SenderRoute struct {
+	// Rule tables of messages, syntax is the same as filters, empty matches all tables
+	Rule   string       `json:"rule"`
+	Syntax FilterSyntax `json:"syntax"`
+	// Events events of messages, values are the same as event filters, empty matches all events
+	Events []string `json:"events,omitempty"`
 }
@@ -17,6 +17,34 @@ type Pipeline struct {
 	CreateTime time.Time `json:"create_time"`
 	Remark     string    `json:"remark"`
 	IsDelete   bool      `json:"is_delete"`
+	// End end condition of bounded replay, nil means running forever
+	End *End `json:"end"`
+	// LagThreshold seconds of replication lag raising a warn event, 0 means no warning
+	LagThreshold int `json:"lag_threshold"`
+	// Columns rules of columns like dropping or masking, applied in order
+	Columns []*ColumnRule `json:"columns"`
+	// Transforms steps of transform stage, run in order
+	Transforms []*Transform `json:"transforms"`
+	// Script script run after transforms, nil means no script
+	Script *Script `json:"script"`
+	// Routes routes of physical tables to logical tables
+	Routes []*TableRoute `json:"routes"`
+	// Coalesce coalescing of changes before output, nil means no coalescing
+	Coalesce *Coalesce `json:"coalesce"`
+}
+
+// End end condition of pipeline, the pipeline is finished once any condition is reached.
+// Events after binlog position, events after gtid set is executed, or events at or after time are not sent
+type End struct {
+	BinlogFile     string    `json:"binlog_file"`
+	BinlogPosition uint32    `json:"binlog_position"`
+	GTIDSet        string    `json:"gtid_set"`
+	Time           time.Time `json:"time"`
+}
+
+// IsEmpty returns true if no condition is set
+func (e *End) IsEmpty() bool {
+	return e == nil || (e.BinlogFile == "" && e.GTIDSet == "" && e.Time.IsZero())
 }
 
 // NewPipeline returns a new pipeline with default values
@@ -53,6 +81,8 @@ const (
 	STATUS_RUN Status = "run"
 	// STATUS_STOP stop
 	STATUS_STOP Status = "stop"
+	// STATUS_FINISHED end condition of pipeline is reached
+	STATUS_FINISHED Status = "finished"
 )
 
 // Key generate etcd key
@@ -103,8 +133,15 @@ func WithPipeSafe(uPipe *Pipeline) OptionPipeline {
 		p.Mysql = uPipe.Mysql
 		p.AliasName = uPipe.AliasName
 		p.Filters = uPipe.Filters
+		p.Columns = uPipe.Columns
+		p.Transforms = uPipe.Transforms
+		p.Script = uPipe.Script
+		p.Routes = uPipe.Routes
+		p.Coalesce = uPipe.Coalesce
 		p.Output = uPipe.Output
 		p.Remark = uPipe.Remark
+		p.End = uPipe.End
+		p.LagThreshold = uPipe.LagThreshold
 	}
 }
 
@@ -0,0 +1,12 @@
+package pipeline
+
+// TableRoute routes physical tables matching rule to a logical table, e.g. shards mall_*.order_* to mall.order.
+// Routes are applied in order before transforms, the first matched route is used
+type TableRoute struct {
+	// Rule physical tables of the logical table, syntax is the same as filters
+	Rule   string       `json:"rule"`
+	Syntax FilterSyntax `json:"syntax"`
+	// Database and Table logical name, empty keeps the physical name
+	Database string `json:"database"`
+	Table    string `json:"table"`
+}
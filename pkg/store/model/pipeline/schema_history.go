@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SchemaHistory versions of a table schema seen by pipeline.
+// Rows of binlog replayed after ddl are mapped to columns of the version valid at their position
+type SchemaHistory struct {
+	PipelineName string           `json:"pipeline_name"`
+	Database     string           `json:"database"`
+	Table        string           `json:"table"`
+	Versions     []*SchemaVersion `json:"versions"`
+}
+
+// SchemaVersion table schema valid from position of ddl
+type SchemaVersion struct {
+	// Position of the ddl or where the table is first seen, schema before it is unknown.
+	// nil means valid from the beginning
+	Position   *Position       `json:"position"`
+	Columns    []*SchemaColumn `json:"columns"`
+	PKColumns  []int           `json:"pk_columns"`
+	CreateTime time.Time       `json:"create_time"`
+}
+
+// SchemaColumn column of table, Type is the column type of mysql, e.g. int(10) unsigned
+type SchemaColumn struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Collation string `json:"collation"`
+	Extra     string `json:"extra"`
+}
+
+// Val get schema history json data
+func (s *SchemaHistory) Val() (val string) {
+	b, _ := json.Marshal(s)
+	val = string(b)
+	return
+}
+
+// Unmarshal unmarshal json data to object
+func (s *SchemaHistory) Unmarshal(val []byte) (err error) {
+	err = json.Unmarshal(val, s)
+	return
+}
+
+// Latest returns the last version, nil if there is no version
+func (s *SchemaHistory) Latest() *SchemaVersion {
+	if len(s.Versions) == 0 {
+		return nil
+	}
+	return s.Versions[len(s.Versions)-1]
+}
@@ -0,0 +1,24 @@
+package pipeline
+
+import "testing"
+
+func TestSchemaHistory(t *testing.T) {
+	h := &SchemaHistory{PipelineName: "go_test_pipeline", Database: "db", Table: "users"}
+	if h.Latest() != nil {
+		t.Fail()
+	}
+	h.Versions = []*SchemaVersion{
+		{Columns: []*SchemaColumn{{Name: "id", Type: "int(10) unsigned", Extra: "auto_increment"}}, PKColumns: []int{0}},
+		{
+			Position: &Position{BinlogFile: "mysql-bin.000004", BinlogPosition: 17561},
+			Columns:  []*SchemaColumn{{Name: "id", Type: "bigint(20) unsigned"}},
+		},
+	}
+	h2 := &SchemaHistory{}
+	if err := h2.Unmarshal([]byte(h.Val())); err != nil {
+		t.Error(err)
+	}
+	if len(h2.Versions) != 2 || h2.Versions[0].Position != nil || h2.Latest().Position.BinlogPosition != 17561 {
+		t.Fail()
+	}
+}
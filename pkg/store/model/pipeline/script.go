@@ -0,0 +1,15 @@
+package pipeline
+
+// SCRIPT_MAX_STEPS default execution budget of script for each message
+const SCRIPT_MAX_STEPS uint64 = 100000
+
+// Script starlark script run on each message after transform steps.
+// Source defines function transform(content), content is a dict of head and data of the message,
+// it returns the content, a list of contents to split the message, or None to drop it
+type Script struct {
+	Source string `json:"source"`
+	// MaxSteps execution steps allowed for each message, SCRIPT_MAX_STEPS if it is 0
+	MaxSteps uint64 `json:"max_steps"`
+	// OnError what is done with a message the script fails on, ON_ERROR_DROP if empty
+	OnError OnError `json:"on_error,omitempty"`
+}
@@ -21,6 +21,8 @@ type Sender struct {
 	RabbitMQ *RabbitMQ `json:"rabbitMQ"`
 	Redis    *Redis    `json:"redis"`
 	RocketMQ *RocketMQ `json:"rocketMQ"`
+	// Routes messages sent to the sender when it is one of senders of output, empty routes match all messages
+	Routes []*SenderRoute `json:"routes,omitempty"`
 }
 
 // Kafka output configuration
@@ -57,6 +59,8 @@ type Redis struct {
 	Password string `json:"password"`
 	DB       int    `json:"db"`
 	List     string `json:"list"`
+	// ListPerTable sends messages of each table to its own list named list:database.table
+	ListPerTable bool `json:"list_per_table"`
 }
 
 // RocketMQ aliyun rocketmq configuration
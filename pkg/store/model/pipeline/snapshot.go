@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// Snapshot initial consistent snapshot of pipeline.
+// Progress is stored so that a failover resumes the snapshot instead of restarting it
+type Snapshot struct {
+	PipelineName string `json:"pipeline_name"`
+	// Position binlog position captured at snapshot start, binlog streaming continues from here
+	Position   *Position        `json:"position"`
+	Tables     []*SnapshotTable `json:"tables"`
+	Done       bool             `json:"done"`
+	CreateTime time.Time        `json:"create_time"`
+}
+
+// SnapshotTable snapshot progress of a table
+type SnapshotTable struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	// LastPK primary key values of the last read row
+	LastPK []interface{} `json:"last_pk"`
+	Rows   int64         `json:"rows"`
+	Done   bool          `json:"done"`
+}
+
+// Val get snapshot json data
+func (s *Snapshot) Val() (val string) {
+	b, _ := json.Marshal(s)
+	val = string(b)
+	return
+}
+
+// Unmarshal unmarshal json data to object.
+// numbers are kept as json.Number, so that big primary keys are not lost
+func (s *Snapshot) Unmarshal(val []byte) (err error) {
+	decoder := json.NewDecoder(bytes.NewReader(val))
+	decoder.UseNumber()
+	err = decoder.Decode(s)
+	return
+}
+
+// Clone returns a deep copy of snapshot
+func (s *Snapshot) Clone() *Snapshot {
+	c := &Snapshot{
+		PipelineName: s.PipelineName,
+		Tables:       make([]*SnapshotTable, len(s.Tables)),
+		Done:         s.Done,
+		CreateTime:   s.CreateTime,
+	}
+	if s.Position != nil {
+		c.Position = &Position{}
+		*c.Position = *s.Position
+	}
+	for i, v := range s.Tables {
+		t := *v
+		t.LastPK = append([]interface{}{}, v.LastPK...)
+		c.Tables[i] = &t
+	}
+	return c
+}
@@ -0,0 +1,36 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	s := &Snapshot{
+		PipelineName: "go_test_pipeline",
+		Position: &Position{
+			BinlogFile:     "mysql-bin.000004",
+			BinlogPosition: 17561,
+		},
+		Tables: []*SnapshotTable{
+			{Database: "db", Table: "users", LastPK: []interface{}{uint64(18446744073709551615)}, Rows: 10},
+		},
+	}
+	s2 := &Snapshot{}
+	if err := s2.Unmarshal([]byte(s.Val())); err != nil {
+		t.Error(err)
+	}
+	if s2.Position.BinlogPosition != s.Position.BinlogPosition {
+		t.Fail()
+	}
+	if n, ok := s2.Tables[0].LastPK[0].(json.Number); !ok || n.String() != "18446744073709551615" {
+		t.Fail()
+	}
+	c := s.Clone()
+	c.Position.BinlogPosition = 1
+	c.Tables[0].Rows = 1
+	c.Tables[0].LastPK[0] = 1
+	if s.Position.BinlogPosition == 1 || s.Tables[0].Rows == 1 || s.Tables[0].LastPK[0] == 1 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+// Transform step of transform chain, steps run in order between filter and output
+type Transform struct {
+	Type TransformType `json:"type"`
+	// Rule database or table the step applies to, syntax is the same as filters, empty matches all tables
+	Rule   string       `json:"rule"`
+	Syntax FilterSyntax `json:"syntax"`
+	// Rename old and new names of columns of rename_columns
+	Rename map[string]string `json:"rename,omitempty"`
+	// Database and Table new name of tables of rename_table, empty keeps the name.
+	// Tables matched by rule are merged into one table if it is renamed to a fixed name
+	Database string `json:"database,omitempty"`
+	Table    string `json:"table,omitempty"`
+	// Fields of add_fields
+	Fields []*TransformField `json:"fields,omitempty"`
+	// Columns of drop_fields
+	Columns []string `json:"columns,omitempty"`
+	// Casts columns and types of cast
+	Casts map[string]CastType `json:"casts,omitempty"`
+	// Prefix of columns of old image of flatten, old_ by default
+	Prefix string `json:"prefix,omitempty"`
+	// OnError what is done with a message the step fails on, ON_ERROR_DROP if empty.
+	// It applies to each change of a transaction message
+	OnError OnError `json:"on_error,omitempty"`
+}
+
+// OnError what is done with a message a transform step fails on
+type OnError string
+
+const (
+	// ON_ERROR_DROP drops the message, its position is still recorded
+	ON_ERROR_DROP OnError = "drop"
+	// ON_ERROR_STOP stops the pipeline before the message is recorded, it is handled again after restart
+	ON_ERROR_STOP OnError = "stop"
+	// ON_ERROR_SKIP skips the step, the message goes on as it was before the step
+	ON_ERROR_SKIP OnError = "skip"
+)
+
+// TransformField field added to rows, static Value or Template computed from the row
+type TransformField struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value,omitempty"`
+	// Template text/template executed with .Database, .Table, .Type, .Time and .Row,
+	// e.g. {{.Database}}:{{.Row.id}}
+	Template string `json:"template,omitempty"`
+}
+
+// TransformType type of transform step
+type TransformType string
+
+const (
+	// TRANSFORM_RENAME_COLUMNS renames columns
+	TRANSFORM_RENAME_COLUMNS TransformType = "rename_columns"
+	// TRANSFORM_RENAME_TABLE renames or merges tables
+	TRANSFORM_RENAME_TABLE TransformType = "rename_table"
+	// TRANSFORM_ADD_FIELDS adds static or computed fields
+	TRANSFORM_ADD_FIELDS TransformType = "add_fields"
+	// TRANSFORM_DROP_FIELDS drops fields
+	TRANSFORM_DROP_FIELDS TransformType = "drop_fields"
+	// TRANSFORM_CAST casts types of columns
+	TRANSFORM_CAST TransformType = "cast"
+	// TRANSFORM_FLATTEN flattens old and new images into one map
+	TRANSFORM_FLATTEN TransformType = "flatten"
+)
+
+// CastType type columns are cast to
+type CastType string
+
+const (
+	CAST_INT    CastType = "int"
+	CAST_FLOAT  CastType = "float"
+	CAST_STRING CastType = "string"
+	CAST_BOOL   CastType = "bool"
+)